@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/adelowo/gotils/bag"
 	"github.com/adelowo/gotils/hasher"
+	"github.com/adelowo/reblog/models"
 	"github.com/adelowo/reblog/utils"
 	"github.com/pressly/chi/render"
 	"net/http"
+	"strconv"
 )
 
 type errorMessages struct {
@@ -21,7 +24,7 @@ type authError struct {
 	Errors  errorMessages `json:"errors"`
 }
 
-//Admin and collaborators login
+//Admin and collaborators login. Suspended users can't log in until they are reactivated
 func PostLogin(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 	type login struct {
 		Email    string `json:"email"`
@@ -66,7 +69,7 @@ func PostLogin(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 
 		//Check if the user exists in the database
 
-		user, err := h.DB.FindByEmail(data.Email)
+		user, err := h.DB.FindByEmail(r.Context(), data.Email)
 
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
@@ -79,15 +82,14 @@ func PostLogin(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if valid := hasher.NewBcryptHasher(12).Verify(user.Password, data.Password); valid {
-			claims := make(map[string]interface{}, 4)
 
-			claims["userID"] = user.ID
-			claims["moniker"] = user.Moniker
-			claims["type"] = user.Type
-
-			h.JWT.Claims(claims)
+			if user.Suspended() {
+				w.WriteHeader(http.StatusUnauthorized)
+				render.JSON(w, r, &authError{false, "Your account has been suspended", errorMessages{}})
+				return
+			}
 
-			token, err := h.JWT.Generate()
+			token, err := accessToken(r.Context(), h, user)
 
 			if err != nil {
 
@@ -98,19 +100,23 @@ func PostLogin(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			//Every login starts a new family of refresh tokens
+			refreshToken, err := h.DB.CreateRefreshToken(r.Context(), user.ID, "")
+
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
 			w.WriteHeader(200)
 
 			type d struct {
 				Status  bool   `json:"status"`
 				Message string `json:"message"`
-				Data    struct {
-					Token string `json:"token"`
-				} `json:"data"`
+				Data    tokens `json:"data"`
 			}
 
-			render.JSON(w, r, &d{true, "You have been authenticated", struct {
-				Token string `json:"token"`
-			}{token}})
+			render.JSON(w, r, &d{true, "You have been authenticated", tokens{token, refreshToken}})
 
 			return
 
@@ -120,3 +126,30 @@ func PostLogin(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 		render.JSON(w, r, &authError{false, "Authentication failed", errorMessages{Email: "Invalid email/password"}})
 	}
 }
+
+//tokens are handed out on login and every time the refresh token is used
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+//accessToken generates a short lived JWT for the user.
+//It carries the permissions of the user's role along with their version, the token is refused once they change.
+func accessToken(ctx context.Context, h *Handler, user models.User) (string, error) {
+
+	role, err := h.DB.FindRole(ctx, user.Role)
+
+	if err != nil {
+		return "", err
+	}
+
+	claims := make(map[string]interface{}, 5)
+
+	claims["userID"] = user.ID
+	claims["moniker"] = user.Moniker
+	claims["role"] = role.Name
+	claims["permissions"] = role.Permissions
+	claims["pv"] = user.PermissionsVersion
+
+	return h.JWT.Generate(strconv.Itoa(user.ID), claims)
+}
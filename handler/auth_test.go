@@ -2,12 +2,15 @@ package handler
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/adelowo/reblog/models"
 	"github.com/adelowo/reblog/models/mocks"
 	"github.com/adelowo/reblog/utils"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -15,14 +18,19 @@ import (
 
 var _ models.DataStore = &mocks.DataStore{}
 
+//newTestJWT signs tokens with a HS256 secret, like reblog does when only JWT is set
+func newTestJWT() *utils.JWTTokenGenerator {
+	return utils.MustNewJWTGenerator(utils.SigningKey{ID: "test", Method: jwt.SigningMethodHS256, Key: []byte("secret")})
+}
+
 func TestPostLogin(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindByEmail", "adelowo@me.com").
+	db.On("FindByEmail", mock.Anything, "adelowo@me.com").
 		Return(models.User{}, errors.New("User does not exists"))
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	testInvalidPostBody(h, t)
 	testDataFailsValidation(h, t)
@@ -114,10 +122,16 @@ func testInvalidUser(h *Handler, t *testing.T) {
 func testSuccess(t *testing.T) {
 	db := new(mocks.DataStore)
 
-	db.On("FindByEmail", "adelowo@me.com").
-		Return(models.User{ID: 1, Password: "$2a$12$Xc6ArM465UaZVW/bbZorSec/dgkSApoC0Ac7Zfi6MajZlSnerqMAW", Moniker: "adelowo", Type: 0}, nil)
+	db.On("FindByEmail", mock.Anything, "adelowo@me.com").
+		Return(models.User{ID: 1, Password: "$2a$12$Xc6ArM465UaZVW/bbZorSec/dgkSApoC0Ac7Zfi6MajZlSnerqMAW", Moniker: "adelowo", Role: models.AUTHOR}, nil)
+
+	db.On("FindRole", mock.Anything, models.AUTHOR).
+		Return(models.Role{Name: models.AUTHOR, Permissions: []string{models.POST_CREATE, models.POST_PUBLISH}}, nil)
+
+	db.On("CreateRefreshToken", mock.Anything, 1, "").
+		Return("refresh-token", nil)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	data := []byte(`{"email" : "adelowo@me.com", "password" : "badpassword"}`)
 
@@ -139,5 +153,25 @@ func testSuccess(t *testing.T) {
 		t.Log("Passing")
 	}
 
+	var res struct {
+		Data tokens `json:"data"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "refresh-token", res.Data.RefreshToken)
+
+	//The token carries the permissions of the user's role
+	token, err := h.JWT.Decode(res.Data.Token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "author", token.Claims["role"])
+	assert.Equal(t, []interface{}{"post:create", "post:publish"}, token.Claims["permissions"])
+
 	db.AssertExpectations(t)
 }
@@ -3,30 +3,70 @@ package handler
 import (
 	"encoding/json"
 	"github.com/adelowo/gotils/bag"
+	"github.com/adelowo/reblog/mailer"
+	"github.com/adelowo/reblog/middleware"
 	"github.com/adelowo/reblog/models"
 	"github.com/adelowo/reblog/utils"
 	"github.com/pressly/chi"
 	"github.com/pressly/chi/render"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-const tokenTTL = 20 * time.Minute
+const (
+	//How long an invitation can be used to sign up when the admin doesn't say
+	DEFAULT_INVITATION_TTL = 20 * time.Minute
+
+	//The longest an invitation can be valid for
+	MAX_INVITATION_TTL = 30 * 24 * time.Hour
+)
+
+//invitation is a pending invitation as admins see it. The token is only ever sent to the invited collaborator
+type invitation struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newInvitation(c models.Collaborator, now time.Time) invitation {
+	status := "pending"
+
+	if c.Expired(now) {
+		status = "expired"
+	}
+
+	return invitation{c.ID, c.Email, c.Role, status, c.ExpiresAt, c.CreatedAt}
+}
 
 //This is used to create a token to be sent to the user
 //After which the user would be authenticated with the token.
+//
+//The invitation is valid for DEFAULT_INVITATION_TTL unless expires_in (e.g "72h") says otherwise,
+//and the collaborator signs up with the given role, a contributor by default.
+//Nobody can be invited as the owner, nor with a permission the user inviting them doesn't have.
 func CreateCollaborator(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 
 	type d struct {
-		Email string `json:"email"`
+		Email     string `json:"email"`
+		Role      string `json:"role"`
+		ExpiresIn string `json:"expires_in"`
+	}
+
+	type errorMessages struct {
+		Email     string `json:"email"`
+		Role      string `json:"role,omitempty"`
+		ExpiresIn string `json:"expires_in,omitempty"`
 	}
 
 	type res struct {
-		Status  bool   `json:"status"`
-		Message string `json:"message"`
-		Data    struct {
-			Email string `json:"email"`
-		} `json:"data"`
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Data    errorMessages `json:"data"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		var data d
@@ -36,51 +76,251 @@ func CreateCollaborator(h *Handler) func(w http.ResponseWriter, r *http.Request)
 		if err := decoder.Decode(&data); err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 
-			render.JSON(w, r, &res{false, "Bad request", struct {
-				Email string `json:"email"`
-			}{"Invalid Email"}})
+			render.JSON(w, r, &res{false, "Bad request", errorMessages{Email: "Invalid Email"}})
 			return
 		}
 
 		if !utils.IsEmail(data.Email) {
 			w.WriteHeader(http.StatusBadRequest)
-			render.JSON(w, r, &res{false, "Please provide a valid email address", struct {
-				Email string `json:"email"`
-			}{"Please provide a valid email address"}})
+			render.JSON(w, r, &res{false, "Please provide a valid email address", errorMessages{Email: "Please provide a valid email address"}})
 			return
 		}
 
+		if data.Role == "" {
+			data.Role = models.CONTRIBUTOR
+		}
+
+		role, err := h.DB.FindRole(r.Context(), data.Role)
+
+		if err != nil || role.Name == models.OWNER {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Validation failed", errorMessages{Role: "The role does not exist or cannot be given to collaborators"}})
+			return
+		}
+
+		for _, p := range role.Permissions {
+			if !middleware.Can(r, p) {
+				w.WriteHeader(http.StatusUnauthorized)
+				render.JSON(w, r, &res{false, "You cannot invite a collaborator with more permissions than you have", errorMessages{Role: "The " + role.Name + " role can " + p}})
+				return
+			}
+		}
+
+		ttl := DEFAULT_INVITATION_TTL
+
+		if data.ExpiresIn != "" {
+			if ttl, err = time.ParseDuration(data.ExpiresIn); err != nil || ttl <= 0 || ttl > MAX_INVITATION_TTL {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, &res{false, "Validation failed", errorMessages{ExpiresIn: "The invitation should expire within 30 days, e.g 72h"}})
+				return
+			}
+		}
+
 		//check if the user already exists as a user
 
-		if _, err := h.DB.FindByEmail(data.Email); err == nil {
+		if _, err := h.DB.FindByEmail(r.Context(), data.Email); err == nil {
 			w.WriteHeader(http.StatusBadRequest)
 
-			render.JSON(w, r, &res{false, "Collaborator exists", struct {
-				Email string `json:"email"`
-			}{"Email already identifies a collaborator"}})
+			render.JSON(w, r, &res{false, "Collaborator exists", errorMessages{Email: "Email already identifies a collaborator"}})
 			return
 		}
 
-		if err := h.DB.CreateCollaborator(data.Email); err == nil {
-			w.WriteHeader(http.StatusOK)
+		//The invitation is only kept if its email could be sent or queued
+		err = h.DB.WithTx(r.Context(), func(tx models.DataStore) error {
+
+			c, err := tx.CreateCollaborator(r.Context(), data.Email, role.Name, time.Now().Add(ttl))
+
+			if err != nil {
+				return err
+			}
+
+			return sendInvitation(h, tx, r, c)
+		})
 
-			//				defer sendEmailHere()
-			render.JSON(w, r, &res{true, "A email has been sent to the collaborator", struct {
-				Email string `json:"email"`
-			}{""}})
+		if err == nil {
+			w.WriteHeader(http.StatusOK)
+			render.JSON(w, r, &res{true, "A email has been sent to the collaborator", errorMessages{}})
 			return
 		}
 
+		log.Printf("Could not invite %s: %v", data.Email, err)
+
 		w.WriteHeader(http.StatusInternalServerError)
-		render.JSON(w, r, &res{false, "An error occured while we tried adding a new collaborator", struct {
-			Email string `json:"email"`
-		}{""}})
+		render.JSON(w, r, &res{false, "An error occured while we tried adding a new collaborator", errorMessages{}})
+	}
+}
+
+//mailerIn returns the mailer to send emails with from within tx.
+//Emails queued in the outbox are then only sent if tx is committed.
+func mailerIn(h *Handler, tx models.DataStore) mailer.Mailer {
+	if _, ok := h.Mailer.(*mailer.Outbox); ok {
+		return mailer.NewOutbox(tx)
+	}
+
+	return h.Mailer
+}
+
+//sendInvitation emails the link to sign up with to the invited collaborator
+func sendInvitation(h *Handler, tx models.DataStore, r *http.Request, c models.Collaborator) error {
+
+	m, err := mailer.Render(c.Email, "You have been invited to write on "+h.Site.Title, "invitation", struct {
+		Site      string
+		Link      string
+		ExpiresIn time.Duration
+	}{h.Site.Title, h.Site.baseURL() + "/signup/" + c.Token, c.ExpiresAt.Sub(c.CreatedAt).Round(time.Minute)})
+
+	if err != nil {
+		return err
+	}
+
+	return mailerIn(h, tx).Send(r.Context(), m)
+}
+
+//ListInvitations lists the invitations nobody has signed up with yet, expired ones included
+func ListInvitations(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool         `json:"status"`
+		Message string       `json:"message"`
+		Data    []invitation `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		collaborators, err := h.DB.FindCollaborators(r.Context())
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching invitations", []invitation{}})
+			return
+		}
+
+		now := time.Now()
+
+		invitations := make([]invitation, 0, len(collaborators))
+
+		for _, c := range collaborators {
+			invitations = append(invitations, newInvitation(c, now))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Invitations were fetched", invitations})
+	}
+}
+
+//ResendInvitation emails a new link to sign up with to the invited collaborator.
+//The previous link stops working, the new one is valid for as long as the invitation originally was.
+func ResendInvitation(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool        `json:"status"`
+		Message string      `json:"message"`
+		Data    *invitation `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		c, ok := findInvitation(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		ttl := c.ExpiresAt.Sub(c.CreatedAt).Round(time.Minute)
+
+		if ttl <= 0 {
+			ttl = DEFAULT_INVITATION_TTL
+		}
+
+		var renewed models.Collaborator
+
+		//The previous link keeps working if the new one could not be sent or queued
+		err := h.DB.WithTx(r.Context(), func(tx models.DataStore) error {
+
+			var err error
+
+			if renewed, err = tx.RotateCollaboratorToken(r.Context(), c.ID, time.Now().Add(ttl)); err != nil {
+				return err
+			}
+
+			return sendInvitation(h, tx, r, renewed)
+		})
+
+		if err != nil {
+			log.Printf("Could not resend invitation %d: %v", c.ID, err)
+
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried resending the invitation", nil})
+			return
+		}
+
+		i := newInvitation(renewed, time.Now())
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "A new email has been sent to the collaborator", &i})
+	}
+}
+
+//RevokeInvitation deletes an invitation, so it can't be used to sign up anymore
+func RevokeInvitation(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		c, ok := findInvitation(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		if err := h.DB.DeleteCollaborator(r.Context(), c); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried revoking the invitation"})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "The invitation was revoked"})
 	}
 }
 
+//findInvitation fetches the invitation whose id is in the url. It writes the response itself when it can't
+func findInvitation(h *Handler, w http.ResponseWriter, r *http.Request) (models.Collaborator, bool) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, &res{false, "Invalid invitation id"})
+		return models.Collaborator{}, false
+	}
+
+	c, err := h.DB.FindCollaboratorByID(r.Context(), id)
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, &res{false, "Invitation not found"})
+		return models.Collaborator{}, false
+	}
+
+	return c, true
+}
+
+//DeleteCollaborator deletes the user with the given email the same way DeleteUser does,
+//their posts being reassigned or deleted as the request says.
 func DeleteCollaborator(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 	type d struct {
 		Email string `json:"email"`
+		userDeletion
 	}
 
 	type res struct {
@@ -101,18 +341,15 @@ func DeleteCollaborator(h *Handler) func(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
-		if user, err := h.DB.FindByEmail(data.Email); err == nil {
-			defer h.DB.DeleteUser(user)
-
-			w.WriteHeader(http.StatusOK)
-			render.JSON(w, r, &res{true, "User was successfully deleted"})
+		user, err := h.DB.FindByEmail(r.Context(), data.Email)
 
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Could not delete non-existent user"})
 			return
 		}
 
-		w.WriteHeader(http.StatusBadRequest)
-		render.JSON(w, r, &res{false, "Could not delete non-existent user"})
-
+		deleteUser(h, w, r, user, data.userDeletion)
 	}
 }
 
@@ -152,14 +389,14 @@ func PostSignUp(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 
 		token := chi.URLParam(r, "token")
 
-		collaborator, err := h.DB.FindCollaboratorByToken(token)
+		collaborator, err := h.DB.FindCollaboratorByToken(r.Context(), token)
 
 		if err != nil {
 			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 			return
 		}
 
-		if time.Now().Sub(collaborator.CreatedAt) > tokenTTL {
+		if collaborator.Expired(time.Now()) {
 			w.WriteHeader(http.StatusBadRequest)
 			render.JSON(w, r, &res{false, "Token is expired, Please contact the admin to resend a new token", errorMessages{}})
 			return
@@ -190,13 +427,18 @@ func PostSignUp(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		//ALl went successfully, we can add the user as a collaborator now
+		//ALl went successfully, we can add the user as a collaborator now.
+		//The invitation is used up at the same time, so it can't be used to sign up twice
+		err = h.DB.WithTx(r.Context(), func(tx models.DataStore) error {
 
-		err = h.DB.CreateUser(&models.User{Moniker: data.Moniker, Email: collaborator.Email, Name: data.Name, Password: data.Password})
+			if err := tx.CreateUser(r.Context(), &models.User{Moniker: data.Moniker, Email: collaborator.Email, Name: data.Name, Password: data.Password, Role: collaborator.Role}); err != nil {
+				return err
+			}
 
-		if err == nil {
-			defer h.DB.DeleteCollaborator(collaborator)
+			return tx.DeleteCollaborator(r.Context(), collaborator)
+		})
 
+		if err == nil {
 			w.WriteHeader(http.StatusOK)
 			render.JSON(w, r, &res{true, "You have been added as a contributor to Reblog. Please login in other to get started", errorMessages{}})
 			return
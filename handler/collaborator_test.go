@@ -2,14 +2,20 @@ package handler
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"github.com/adelowo/reblog/mailer"
 	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
 	"github.com/adelowo/reblog/models/mocks"
-	"github.com/adelowo/reblog/utils"
 	"github.com/pressly/chi"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -17,7 +23,7 @@ import (
 func TestInvalidEmailAddress(t *testing.T) {
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	data := []byte(`{"email" : "adelowo"}`)
 
@@ -45,10 +51,13 @@ func TestCannotAddACollaboratorTwice(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
-	db.On("FindByEmail", "me@lanre.me").
-		Return(models.User{ID: 1, Moniker: "adelowo", Type: 0}, nil)
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
+
+	db.On("FindByEmail", mock.Anything, "me@lanre.me").
+		Return(models.User{ID: 1, Moniker: "adelowo", Role: models.CONTRIBUTOR}, nil)
 
 	data := []byte(`{"email" : "me@lanre.me"}`)
 
@@ -58,6 +67,8 @@ func TestCannotAddACollaboratorTwice(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
 	rr := httptest.NewRecorder()
 
 	http.HandlerFunc(CreateCollaborator(h)).
@@ -76,13 +87,18 @@ func TestCannotAddACollaboratorTwice(t *testing.T) {
 func TestAnErrorOccurredWhileAddingACollaborator(t *testing.T) {
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
 
-	db.On("FindByEmail", "me@lanre.me").
+	db.On("FindByEmail", mock.Anything, "me@lanre.me").
 		Return(models.User{}, errors.New("could not find user"))
 
-	db.On("CreateCollaborator", "me@lanre.me").
-		Return(errors.New("Something bad happened"))
+	db.On("CreateCollaborator", mock.Anything, "me@lanre.me", models.CONTRIBUTOR, mock.Anything).
+		Return(models.Collaborator{}, errors.New("Something bad happened"))
+
+	runTxOn(db)
 
 	data := []byte(`{"email" : "me@lanre.me"}`)
 
@@ -92,6 +108,8 @@ func TestAnErrorOccurredWhileAddingACollaborator(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
 	rr := httptest.NewRecorder()
 
 	http.HandlerFunc(CreateCollaborator(h)).
@@ -116,7 +134,7 @@ func TestAnInvalidTokenCannotBeUsedToSignUpAsAWriter(t *testing.T) {
 
 	token := "invalidtokenhere"
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("POST", "/signup/"+token, bytes.NewBuffer(data))
 
@@ -128,7 +146,7 @@ func TestAnInvalidTokenCannotBeUsedToSignUpAsAWriter(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	db.On("FindCollaboratorByToken", token).
+	db.On("FindCollaboratorByToken", mock.Anything, token).
 		Return(models.Collaborator{}, errors.New("Collaborator not found"))
 
 	r.Post("/signup/:token", PostSignUp(h))
@@ -155,7 +173,7 @@ func TestAnExpiredTokenCannotBeUsedToSignUpAsAUser(t *testing.T) {
 
 	token := "expiredtoken"
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("POST", "/signup/"+token, bytes.NewBuffer(data))
 
@@ -167,8 +185,8 @@ func TestAnExpiredTokenCannotBeUsedToSignUpAsAUser(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	db.On("FindCollaboratorByToken", token).
-		Return(models.Collaborator{2, token, "me@lanre.com", time.Now().Add(-21 * time.Minute)}, nil)
+	db.On("FindCollaboratorByToken", mock.Anything, token).
+		Return(models.Collaborator{ID: 2, Token: token, Email: "me@lanre.com", ExpiresAt: time.Now().Add(-time.Minute), CreatedAt: time.Now().Add(-21 * time.Minute)}, nil)
 
 	r.Post("/signup/:token", PostSignUp(h))
 
@@ -195,7 +213,7 @@ func TestCannotSignUserUpWithInvalidData(t *testing.T) {
 
 	token := "token"
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("POST", "/signup/"+token, bytes.NewBuffer(data))
 
@@ -207,8 +225,8 @@ func TestCannotSignUserUpWithInvalidData(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	db.On("FindCollaboratorByToken", token).
-		Return(models.Collaborator{2, token, "me@lanre.com", time.Now().Add(15 * time.Minute)}, nil)
+	db.On("FindCollaboratorByToken", mock.Anything, token).
+		Return(models.Collaborator{ID: 2, Token: token, Email: "me@lanre.com", ExpiresAt: time.Now().Add(15 * time.Minute), CreatedAt: time.Now().Add(-5 * time.Minute)}, nil)
 
 	r.Post("/signup/:token", PostSignUp(h))
 
@@ -224,6 +242,14 @@ func TestCannotSignUserUpWithInvalidData(t *testing.T) {
 
 }
 
+//runTxOn makes db run the functions passed to its WithTx against itself
+func runTxOn(db *mocks.DataStore) {
+	db.On("WithTx", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, fn func(tx models.DataStore) error) error {
+			return fn(db)
+		})
+}
+
 func TestCollaboratorIsSuccessfullyCreated(t *testing.T) {
 
 	data := []byte(`{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`)
@@ -232,7 +258,7 @@ func TestCollaboratorIsSuccessfullyCreated(t *testing.T) {
 
 	token := "token"
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("POST", "/signup/"+token, bytes.NewBuffer(data))
 
@@ -244,17 +270,19 @@ func TestCollaboratorIsSuccessfullyCreated(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	c := models.Collaborator{2, token, "me@lanre.com", time.Now().Add(15 * time.Minute)}
+	c := models.Collaborator{ID: 2, Token: token, Email: "me@lanre.com", ExpiresAt: time.Now().Add(15 * time.Minute), CreatedAt: time.Now().Add(-5 * time.Minute)}
 
-	db.On("DeleteCollaborator", c).
+	db.On("DeleteCollaborator", mock.Anything, c).
 		Return(nil)
 
-	db.On("FindCollaboratorByToken", token).
+	db.On("FindCollaboratorByToken", mock.Anything, token).
 		Return(c, nil)
 
-	db.On("CreateUser", &models.User{Moniker: "hades", Email: c.Email, Name: "Lanre Adelowo", Password: "yetanotherbadpassword"}).
+	db.On("CreateUser", mock.Anything, &models.User{Moniker: "hades", Email: c.Email, Name: "Lanre Adelowo", Password: "yetanotherbadpassword"}).
 		Return(nil)
 
+	runTxOn(db)
+
 	r.Post("/signup/:token", PostSignUp(h))
 
 	r.ServeHTTP(rr, req)
@@ -267,6 +295,8 @@ func TestCollaboratorIsSuccessfullyCreated(t *testing.T) {
 
 	assert.JSONEq(t, expectedText, rr.Body.String())
 
+	db.AssertCalled(t, "DeleteCollaborator", mock.Anything, c)
+
 }
 
 func TestAnUnknownErrorOccurredWhileCollaboratorTriedSigningUp(t *testing.T) {
@@ -277,7 +307,7 @@ func TestAnUnknownErrorOccurredWhileCollaboratorTriedSigningUp(t *testing.T) {
 
 	token := "token"
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("POST", "/signup/"+token, bytes.NewBuffer(data))
 
@@ -289,17 +319,66 @@ func TestAnUnknownErrorOccurredWhileCollaboratorTriedSigningUp(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	c := models.Collaborator{2, token, "me@lanre.com", time.Now().Add(15 * time.Minute)}
+	c := models.Collaborator{ID: 2, Token: token, Email: "me@lanre.com", ExpiresAt: time.Now().Add(15 * time.Minute), CreatedAt: time.Now().Add(-5 * time.Minute)}
 
-	db.On("DeleteCollaborator", c).
+	db.On("DeleteCollaborator", mock.Anything, c).
 		Return(nil)
 
-	db.On("FindCollaboratorByToken", token).
+	db.On("FindCollaboratorByToken", mock.Anything, token).
 		Return(c, nil)
 
-	db.On("CreateUser", &models.User{Moniker: "hades", Email: c.Email, Name: "Lanre Adelowo", Password: "yetanotherbadpassword"}).
+	db.On("CreateUser", mock.Anything, &models.User{Moniker: "hades", Email: c.Email, Name: "Lanre Adelowo", Password: "yetanotherbadpassword"}).
 		Return(errors.New("An error occured"))
 
+	runTxOn(db)
+
+	r.Post("/signup/:token", PostSignUp(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatal(status)
+	}
+
+	expectedText := string(`{"status" : false, "message" : "An error occured while we tried adding you as a collaborator to Reblog. Please try again", "errors":{"moniker":"", "full_name":"", "password":""}}`)
+
+	assert.JSONEq(t, expectedText, rr.Body.String())
+
+}
+
+func TestASignUpFailsIfTheInvitationCannotBeUsedUp(t *testing.T) {
+
+	data := []byte(`{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`)
+
+	db := new(mocks.DataStore)
+
+	token := "token"
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/signup/"+token, bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	c := models.Collaborator{ID: 2, Token: token, Email: "me@lanre.com", ExpiresAt: time.Now().Add(15 * time.Minute), CreatedAt: time.Now().Add(-5 * time.Minute)}
+
+	db.On("DeleteCollaborator", mock.Anything, c).
+		Return(errors.New("An error occured"))
+
+	db.On("FindCollaboratorByToken", mock.Anything, token).
+		Return(c, nil)
+
+	db.On("CreateUser", mock.Anything, &models.User{Moniker: "hades", Email: c.Email, Name: "Lanre Adelowo", Password: "yetanotherbadpassword"}).
+		Return(nil)
+
+	runTxOn(db)
+
 	r.Post("/signup/:token", PostSignUp(h))
 
 	r.ServeHTTP(rr, req)
@@ -316,17 +395,24 @@ func TestAnUnknownErrorOccurredWhileCollaboratorTriedSigningUp(t *testing.T) {
 
 func TestCanDeleteACollaborator(t *testing.T) {
 
-	data := []byte(`{"email" : "assholeuser@app.live"}`)
+	data := []byte(`{"email" : "assholeuser@app.live", "posts" : "delete"}`)
 
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	u := models.User{ID: 5, Moniker: "asshole", Role: models.CONTRIBUTOR, Email: "assholeuser@app.live"}
+
+	db.On("FindByEmail", mock.Anything, "assholeuser@app.live").Return(u, nil)
 
-	u := models.User{Moniker: "asshole", Type: 0, Email: "assholeuser@app.live"}
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
 
-	db.On("FindByEmail", "assholeuser@app.live").Return(u, nil)
+	db.On("DeleteUserPosts", mock.Anything, 5).Return(nil)
+	db.On("RevokeUserRefreshTokens", mock.Anything, 5).Return(nil)
+	db.On("DeleteUser", mock.Anything, u).Return(nil)
 
-	db.On("DeleteUser", u).Return(nil)
+	runTxOn(db)
 
 	req, err := http.NewRequest("POST", "/reblog/collaborator/delete", bytes.NewBuffer(data))
 
@@ -334,6 +420,8 @@ func TestCanDeleteACollaborator(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
 	rr := httptest.NewRecorder()
 
 	http.HandlerFunc(DeleteCollaborator(h)).
@@ -343,10 +431,47 @@ func TestCanDeleteACollaborator(t *testing.T) {
 		t.Fatal(status)
 	}
 
-	expected := string(`{"status":true,"message":"User was successfully deleted"}`)
+	expected := string(`{"status":true,"message":"User was successfully deleted","errors":{}}`)
 
 	assert.JSONEq(t, expected, rr.Body.String(), "The response body differs")
 
+	db.AssertExpectations(t)
+}
+
+//The user is only deleted once the request has said what happens to their posts
+func TestCannotDeleteACollaboratorWithoutSayingWhatHappensToTheirPosts(t *testing.T) {
+
+	data := []byte(`{"email" : "assholeuser@app.live"}`)
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	u := models.User{ID: 5, Moniker: "asshole", Role: models.CONTRIBUTOR, Email: "assholeuser@app.live"}
+
+	db.On("FindByEmail", mock.Anything, "assholeuser@app.live").Return(u, nil)
+
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
+
+	req, err := http.NewRequest("POST", "/reblog/collaborator/delete", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(DeleteCollaborator(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatal(status)
+	}
+
+	db.AssertNotCalled(t, "DeleteUser", mock.Anything, mock.Anything)
 }
 
 func TestCannotDeleteANonExistentUser(t *testing.T) {
@@ -355,9 +480,9 @@ func TestCannotDeleteANonExistentUser(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
-	db.On("FindByEmail", "unknownuser@app.live").Return(models.User{}, errors.New("User doesn't exist"))
+	db.On("FindByEmail", mock.Anything, "unknownuser@app.live").Return(models.User{}, errors.New("User doesn't exist"))
 
 	req, err := http.NewRequest("POST", "/reblog/collaborator/delete", bytes.NewBuffer(data))
 
@@ -379,3 +504,367 @@ func TestCannotDeleteANonExistentUser(t *testing.T) {
 	assert.JSONEq(t, expected, rr.Body.String(), "The response body differs")
 
 }
+
+func TestAnInvitedCollaboratorCanSignUpWithTheEmailedLink(t *testing.T) {
+
+	db := memory.New()
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := chi.NewRouter()
+
+	r.With(h.JWT.Verifier).Post("/reblog/collaborator/create", CreateCollaborator(h))
+	r.Post("/signup/:token", PostSignUp(h))
+
+	rr := postJSON(t, r, "/reblog/collaborator/create", `{"email" : "me@lanre.me"}`, testToken(t, h, 1, models.ADMIN))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	sent := m.messages()
+
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 email. Got %d", len(sent))
+	}
+
+	assert.Equal(t, "me@lanre.me", sent[0].To)
+	assert.Equal(t, "You have been invited to write on Reblog", sent[0].Subject)
+
+	prefix := "https://blog.example.com/signup/"
+
+	i := strings.Index(sent[0].Text, prefix)
+
+	if i == -1 {
+		t.Fatalf("The email does not contain a sign up link: %s", sent[0].Text)
+	}
+
+	link := strings.Fields(sent[0].Text[i:])[0]
+
+	assert.Contains(t, sent[0].HTML, `href="`+link+`"`)
+
+	rr = postJSON(t, r, strings.TrimPrefix(link, "https://blog.example.com"), `{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	user, err := db.FindByEmail(context.Background(), "me@lanre.me")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hades", user.Moniker)
+}
+
+func TestAnErrorOccurredWhileSendingAnInvitation(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: failingMailer{}, Site: testSite}
+
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
+
+	db.On("FindByEmail", mock.Anything, "me@lanre.me").
+		Return(models.User{}, errors.New("could not find user"))
+
+	db.On("CreateCollaborator", mock.Anything, "me@lanre.me", models.CONTRIBUTOR, mock.Anything).
+		Return(models.Collaborator{Email: "me@lanre.me", Token: "token"}, nil)
+
+	runTxOn(db)
+
+	rr := postJSON(t, h.JWT.Verifier(http.HandlerFunc(CreateCollaborator(h))), "/reblog/collaborator/create", `{"email" : "me@lanre.me"}`, testToken(t, h, 1, models.ADMIN))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"An error occured while we tried adding a new collaborator","data":{"email":""}}`, rr.Body.String())
+}
+
+func TestAnInvitationIsQueuedInTheSameTransactionAsItIsCreated(t *testing.T) {
+
+	db, tx := new(mocks.DataStore), new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: mailer.NewOutbox(db), Site: testSite}
+
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
+
+	db.On("FindByEmail", mock.Anything, "me@lanre.me").
+		Return(models.User{}, errors.New("could not find user"))
+
+	db.On("WithTx", mock.Anything, mock.Anything).
+		Return(func(ctx context.Context, fn func(tx models.DataStore) error) error {
+			return fn(tx)
+		})
+
+	tx.On("CreateCollaborator", mock.Anything, "me@lanre.me", models.CONTRIBUTOR, mock.Anything).
+		Return(models.Collaborator{Email: "me@lanre.me", Token: "token"}, nil)
+
+	tx.On("QueueEmail", mock.Anything, mock.AnythingOfType("*models.Email")).Once().Return(nil)
+
+	rr := postJSON(t, h.JWT.Verifier(http.HandlerFunc(CreateCollaborator(h))), "/reblog/collaborator/create", `{"email" : "me@lanre.me"}`, testToken(t, h, 1, models.ADMIN))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	tx.AssertExpectations(t)
+	db.AssertNotCalled(t, "CreateCollaborator", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	db.AssertNotCalled(t, "QueueEmail", mock.Anything, mock.Anything)
+}
+
+func invitationRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(h.JWT.Verifier)
+
+	r.Post("/reblog/collaborator/create", CreateCollaborator(h))
+	r.Get("/reblog/collaborator/invites", ListInvitations(h))
+	r.Post("/reblog/collaborator/invites/:id/resend", ResendInvitation(h))
+	r.Delete("/reblog/collaborator/invites/:id", RevokeInvitation(h))
+	r.Post("/signup/:token", PostSignUp(h))
+
+	return r
+}
+
+func serveInvitationRequest(t *testing.T, r http.Handler, method, path string) *httptest.ResponseRecorder {
+
+	req, err := http.NewRequest(method, path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	return rr
+}
+
+//signUpLink returns the path of the sign up link in an invitation email
+func signUpLink(t *testing.T, m mailer.Message) string {
+
+	prefix := "https://blog.example.com/signup/"
+
+	i := strings.Index(m.Text, prefix)
+
+	if i == -1 {
+		t.Fatalf("The email does not contain a sign up link: %s", m.Text)
+	}
+
+	return strings.TrimPrefix(strings.Fields(m.Text[i:])[0], "https://blog.example.com")
+}
+
+func TestInvitationsCanBeListed(t *testing.T) {
+
+	db := memory.New()
+
+	expired, _ := db.CreateCollaborator(context.Background(), "zeus@reblog.io", models.CONTRIBUTOR, time.Now().Add(-time.Minute))
+	pending, _ := db.CreateCollaborator(context.Background(), "hades@reblog.io", models.ADMIN, time.Now().Add(time.Hour))
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: &fakeMailer{}, Site: testSite}
+
+	rr := serveInvitationRequest(t, invitationRouter(h), "GET", "/reblog/collaborator/invites")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var res struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Data) != 2 {
+		t.Fatalf("Expected 2 invitations. Got %d", len(res.Data))
+	}
+
+	assert.Equal(t, float64(pending.ID), res.Data[0]["id"])
+	assert.Equal(t, "hades@reblog.io", res.Data[0]["email"])
+	assert.Equal(t, "admin", res.Data[0]["role"])
+	assert.Equal(t, "pending", res.Data[0]["status"])
+
+	assert.Equal(t, float64(expired.ID), res.Data[1]["id"])
+	assert.Equal(t, "contributor", res.Data[1]["role"])
+	assert.Equal(t, "expired", res.Data[1]["status"])
+
+	//Tokens are only ever sent to the invited collaborators
+	assert.NotContains(t, rr.Body.String(), pending.Token)
+	assert.NotContains(t, res.Data[0], "token")
+}
+
+func TestAnInvitationCanBeCreatedWithARoleAndExpiry(t *testing.T) {
+
+	db := memory.New()
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := invitationRouter(h)
+
+	rr := postJSON(t, r, "/reblog/collaborator/create", `{"email" : "me@lanre.me", "role" : "admin", "expires_in" : "72h"}`, testToken(t, h, 1, models.OWNER))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	invitations, _ := db.FindCollaborators(context.Background())
+
+	if len(invitations) != 1 {
+		t.Fatalf("Expected 1 invitation. Got %d", len(invitations))
+	}
+
+	assert.Equal(t, models.ADMIN, invitations[0].Role)
+	assert.WithinDuration(t, time.Now().Add(72*time.Hour), invitations[0].ExpiresAt, time.Minute)
+
+	sent := m.messages()
+
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 email. Got %d", len(sent))
+	}
+
+	assert.Contains(t, sent[0].Text, "within 3 days")
+
+	//The collaborator signs up with the role they were invited with
+	rr = postJSON(t, r, signUpLink(t, sent[0]), `{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	user, err := db.FindByEmail(context.Background(), "me@lanre.me")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.ADMIN, user.Role)
+}
+
+func TestInvitationsWithAnInvalidRoleOrExpiryAreRefused(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: &fakeMailer{}, Site: testSite}
+
+	r := invitationRouter(h)
+
+	token := testToken(t, h, 1, models.OWNER)
+
+	db.On("FindRole", mock.Anything, models.OWNER).
+		Return(models.Role{Name: models.OWNER, Permissions: permissionsOf(models.OWNER)}, nil)
+
+	db.On("FindRole", mock.Anything, "janitor").
+		Return(models.Role{}, errors.New("Role not found"))
+
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).
+		Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
+
+	//There is only one owner
+	for _, role := range []string{"owner", "janitor"} {
+		rr := postJSON(t, r, "/reblog/collaborator/create", `{"email" : "me@lanre.me", "role" : "`+role+`"}`, token)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"status":false,"message":"Validation failed","data":{"email":"","role":"The role does not exist or cannot be given to collaborators"}}`, rr.Body.String())
+	}
+
+	for _, expiresIn := range []string{"tomorrow", "-1h", "0s", "721h"} {
+		rr := postJSON(t, r, "/reblog/collaborator/create", `{"email" : "me@lanre.me", "expires_in" : "`+expiresIn+`"}`, token)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"status":false,"message":"Validation failed","data":{"email":"","expires_in":"The invitation should expire within 30 days, e.g 72h"}}`, rr.Body.String())
+	}
+
+	db.AssertNotCalled(t, "CreateCollaborator", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNobodyCanInviteACollaboratorWithMorePermissionsThanTheyHave(t *testing.T) {
+
+	db := memory.New()
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	//Editors are given a permission admins do not have
+	db.UpdateRolePermissions(context.Background(), models.EDITOR, append(permissionsOf(models.EDITOR), models.ROLE_MANAGE))
+
+	rr := postJSON(t, invitationRouter(h), "/reblog/collaborator/create", `{"email" : "me@lanre.me", "role" : "editor"}`, testToken(t, h, 1, models.ADMIN))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"You cannot invite a collaborator with more permissions than you have","data":{"email":"","role":"The editor role can role:manage"}}`, rr.Body.String())
+
+	invitations, _ := db.FindCollaborators(context.Background())
+
+	assert.Empty(t, invitations)
+	assert.Empty(t, m.messages())
+}
+
+func TestResendingAnInvitationRotatesItsToken(t *testing.T) {
+
+	db := memory.New()
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := invitationRouter(h)
+
+	rr := postJSON(t, r, "/reblog/collaborator/create", `{"email" : "me@lanre.me", "expires_in" : "2h"}`, testToken(t, h, 1, models.ADMIN))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	invitations, _ := db.FindCollaborators(context.Background())
+
+	id := strconv.Itoa(invitations[0].ID)
+
+	rr = postJSON(t, r, "/reblog/collaborator/invites/"+id+"/resend", "", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	sent := m.messages()
+
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 emails. Got %d", len(sent))
+	}
+
+	first, second := signUpLink(t, sent[0]), signUpLink(t, sent[1])
+
+	assert.NotEqual(t, first, second)
+
+	//The new link is valid for as long as the first one was
+	assert.Contains(t, sent[1].Text, "within 2 hours")
+
+	rr = postJSON(t, r, first, `{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`, "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = postJSON(t, r, second, `{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAnInvitationCanBeRevoked(t *testing.T) {
+
+	db := memory.New()
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := invitationRouter(h)
+
+	c, _ := db.CreateCollaborator(context.Background(), "me@lanre.me", models.CONTRIBUTOR, time.Now().Add(time.Hour))
+
+	rr := serveInvitationRequest(t, r, "DELETE", "/reblog/collaborator/invites/"+strconv.Itoa(c.ID))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":true,"message":"The invitation was revoked"}`, rr.Body.String())
+
+	rr = postJSON(t, r, "/signup/"+c.Token, `{"full_name" : "Lanre Adelowo", "moniker" : "hades", "password" : "yetanotherbadpassword"}`, "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serveInvitationRequest(t, r, "DELETE", "/reblog/collaborator/invites/"+strconv.Itoa(c.ID))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = postJSON(t, r, "/reblog/collaborator/invites/"+strconv.Itoa(c.ID)+"/resend", "", "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = serveInvitationRequest(t, r, "DELETE", "/reblog/collaborator/invites/abc")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Empty(t, m.messages())
+}
@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/adelowo/reblog/models"
+	"github.com/pressly/chi"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//Number of posts included in a feed
+const FEED_SIZE = 20
+
+//feed is what every feed format is built from
+type feed struct {
+	Title       string
+	Description string
+	//Link is the page the feed describes, URL is where the feed itself lives
+	Link    string
+	URL     string
+	Updated time.Time
+	Posts   []models.Post
+}
+
+//Site wide feeds of published posts in RSS 2.0.
+//Mounted under /authors/:moniker, only that author's posts are included.
+func RSSFeed(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return serveFeed(h, "application/rss+xml; charset=utf-8", encodeRSS)
+}
+
+func AtomFeed(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return serveFeed(h, "application/atom+xml; charset=utf-8", encodeAtom)
+}
+
+//JSONFeed serves the feed as a JSON Feed (version 1.1)
+func JSONFeed(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return serveFeed(h, "application/feed+json; charset=utf-8", encodeJSONFeed)
+}
+
+//serveFeed fetches the posts, encodes them and lets http.ServeContent answer conditional requests
+//using an ETag of the encoded feed and the time the newest post was last updated
+func serveFeed(h *Handler, contentType string, encode func(site Site, f feed) ([]byte, error)) func(w http.ResponseWriter, r *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		base := h.Site.baseURL()
+
+		f := feed{Title: h.Site.Title, Description: h.Site.Description, Link: base + "/posts", URL: base + r.URL.Path}
+
+		filter := models.PostFilter{PerPage: FEED_SIZE}
+
+		if moniker := chi.URLParam(r, "moniker"); moniker != "" {
+			u, err := h.DB.FindByMoniker(r.Context(), moniker)
+
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+
+			filter.Author = u.Moniker
+			f.Title = h.Site.Title + " - " + u.Name
+			f.Description = u.About
+			f.Link = base + "/posts?author=" + url.QueryEscape(u.Moniker)
+		}
+
+		posts, err := h.DB.FindPublishedPosts(r.Context(), filter)
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		f.Posts = posts
+
+		for _, p := range posts {
+			if p.UpdatedAt.After(f.Updated) {
+				f.Updated = p.UpdatedAt
+			}
+		}
+
+		body, err := encode(h.Site, f)
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		sum := sha1.Sum(body)
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+		http.ServeContent(w, r, "", f.Updated, bytes.NewReader(body))
+	}
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string    `xml:"title"`
+	Link           string    `xml:"link"`
+	Description    string    `xml:"description"`
+	ManagingEditor string    `xml:"managingEditor,omitempty"`
+	LastBuildDate  string    `xml:"lastBuildDate,omitempty"`
+	Items          []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func encodeRSS(site Site, f feed) ([]byte, error) {
+
+	channel := rssChannel{Title: f.Title, Link: f.Link, Description: f.Description, Items: []rssItem{}}
+
+	if site.AuthorEmail != "" {
+		channel.ManagingEditor = site.AuthorEmail + " (" + site.AuthorName + ")"
+	}
+
+	if !f.Updated.IsZero() {
+		channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, p := range f.Posts {
+		item := rssItem{
+			Title:       p.Title,
+			Link:        site.postURL(p),
+			Description: p.ContentHTML,
+			GUID:        rssGUID{false, site.postGUID(p)},
+			PubDate:     p.CreatedAt.UTC().Format(time.RFC1123Z),
+		}
+
+		if p.Category.Name != "" {
+			item.Categories = []string{p.Category.Name}
+		}
+
+		channel.Items = append(channel.Items, item)
+	}
+
+	return encodeXML(rss{Version: "2.0", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+	URI   string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomPerson     `xml:"author"`
+	Categories []atomCategory `xml:"category,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+}
+
+func encodeAtom(site Site, f feed) ([]byte, error) {
+
+	a := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.URL,
+		Updated:  f.Updated.UTC().Format(time.RFC3339),
+		Links:    []atomLink{{Rel: "alternate", Type: "text/html", Href: f.Link}, {Rel: "self", Type: "application/atom+xml", Href: f.URL}},
+	}
+
+	if site.AuthorName != "" {
+		a.Author = &atomPerson{Name: site.AuthorName, Email: site.AuthorEmail}
+	}
+
+	for _, p := range f.Posts {
+		entry := atomEntry{
+			Title:     p.Title,
+			ID:        site.postGUID(p),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: site.postURL(p)},
+			Published: p.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   p.UpdatedAt.UTC().Format(time.RFC3339),
+			Author:    atomPerson{Name: authorName(p), URI: site.authorURL(p)},
+			Content:   atomText{"html", p.ContentHTML},
+		}
+
+		if p.Category.Name != "" {
+			entry.Categories = []atomCategory{{p.Category.Name}}
+		}
+
+		if p.Excerpt != "" {
+			entry.Summary = &atomText{"text", p.Excerpt}
+		}
+
+		a.Entries = append(a.Entries, entry)
+	}
+
+	return encodeXML(a)
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	DatePublished time.Time        `json:"date_published"`
+	DateModified  time.Time        `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedDocument struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+func encodeJSONFeed(site Site, f feed) ([]byte, error) {
+
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.URL,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+
+	if site.AuthorName != "" {
+		doc.Authors = []jsonFeedAuthor{{Name: site.AuthorName}}
+	}
+
+	for _, p := range f.Posts {
+		item := jsonFeedItem{
+			ID:            site.postGUID(p),
+			URL:           site.postURL(p),
+			Title:         p.Title,
+			ContentHTML:   p.ContentHTML,
+			Summary:       p.Excerpt,
+			DatePublished: p.CreatedAt.UTC(),
+			DateModified:  p.UpdatedAt.UTC(),
+			Authors:       []jsonFeedAuthor{{authorName(p), site.authorURL(p)}},
+		}
+
+		if p.Category.Name != "" {
+			item.Tags = []string{p.Category.Name}
+		}
+
+		doc.Items = append(doc.Items, item)
+	}
+
+	return json.Marshal(doc)
+}
+
+func encodeXML(v interface{}) ([]byte, error) {
+	body, err := xml.Marshal(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+//Authors without a full name are credited by their moniker
+func authorName(p models.Post) string {
+	if p.User.Name != "" {
+		return p.User.Name
+	}
+
+	return p.User.Moniker
+}
+
+func (s Site) baseURL() string {
+	return strings.TrimSuffix(s.URL, "/")
+}
+
+func (s Site) postURL(p models.Post) string {
+	return s.baseURL() + "/posts/" + url.PathEscape(p.Slug)
+}
+
+func (s Site) authorURL(p models.Post) string {
+	if p.User.Moniker == "" {
+		return ""
+	}
+
+	return s.baseURL() + "/posts?author=" + url.QueryEscape(p.User.Moniker)
+}
+
+//postGUID identifies a post across feeds with a tag URI (RFC 4151) built from its id.
+//Unlike its url, it doesn't change when the post's title does.
+func (s Site) postGUID(p models.Post) string {
+	host := "reblog"
+
+	if u, err := url.Parse(s.URL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	return fmt.Sprintf("tag:%s,%s:posts/%d", host, p.CreatedAt.UTC().Format("2006-01-02"), p.ID)
+}
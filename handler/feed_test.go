@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testSite = Site{Title: "Reblog", Description: "Writing about Go", URL: "https://blog.example.com/", AuthorName: "Lanre Adelowo", AuthorEmail: "me@lanre.me"}
+
+func feedPosts() []models.Post {
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	return []models.Post{
+		{ID: 3, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test *all* the things", ContentHTML: "<p>Test <em>all</em> the things</p>\n",
+			Excerpt: "Test all the things", Status: PUBLISHED, CreatedAt: createdAt, UpdatedAt: createdAt.Add(48 * time.Hour),
+			User: models.User{Moniker: "hades", Name: "Lanre Adelowo"}, Category: models.Category{ID: 1, Name: "Go", Slug: "Go"}},
+		{ID: 1, Title: "Hello world", Slug: "Hello-world", Content: "Hi", ContentHTML: "<p>Hi</p>\n", Excerpt: "Hi", Status: PUBLISHED,
+			CreatedAt: createdAt.Add(-24 * time.Hour), UpdatedAt: createdAt.Add(-24 * time.Hour), User: models.User{Moniker: "horus"}},
+	}
+}
+
+//Serves a feed through a router so that url params are available
+func serveFeedRequest(t *testing.T, h *Handler, pattern, path string, handler func(w http.ResponseWriter, r *http.Request), headers map[string]string) *httptest.ResponseRecorder {
+
+	req, err := http.NewRequest("GET", path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Get(pattern, handler)
+
+	r.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestRSSFeedOfPublishedPosts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE}).Once().Return(feedPosts(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/feed.rss", "/feed.rss", RSSFeed(h), nil)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Equal(t, "application/rss+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "Sun, 22 Jan 2017 10:00:00 GMT", rr.Header().Get("Last-Modified"))
+	assert.NotEmpty(t, rr.Header().Get("ETag"))
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<rss version="2.0"><channel><title>Reblog</title><link>https://blog.example.com/posts</link><description>Writing about Go</description>` +
+		`<managingEditor>me@lanre.me (Lanre Adelowo)</managingEditor><lastBuildDate>Sun, 22 Jan 2017 10:00:00 +0000</lastBuildDate>` +
+		`<item><title>Testing is key</title><link>https://blog.example.com/posts/Testing-is-key</link><description>&lt;p&gt;Test &lt;em&gt;all&lt;/em&gt; the things&lt;/p&gt;&#xA;</description>` +
+		`<guid isPermaLink="false">tag:blog.example.com,2017-01-20:posts/3</guid><pubDate>Fri, 20 Jan 2017 10:00:00 +0000</pubDate><category>Go</category></item>` +
+		`<item><title>Hello world</title><link>https://blog.example.com/posts/Hello-world</link><description>&lt;p&gt;Hi&lt;/p&gt;&#xA;</description>` +
+		`<guid isPermaLink="false">tag:blog.example.com,2017-01-19:posts/1</guid><pubDate>Thu, 19 Jan 2017 10:00:00 +0000</pubDate></item>` +
+		`</channel></rss>`
+
+	assert.Equal(t, expected, rr.Body.String())
+}
+
+func TestAtomFeedOfPublishedPosts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE}).Once().Return(feedPosts(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/feed.atom", "/feed.atom", AtomFeed(h), nil)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<feed xmlns="http://www.w3.org/2005/Atom"><title>Reblog</title><subtitle>Writing about Go</subtitle><id>https://blog.example.com/feed.atom</id><updated>2017-01-22T10:00:00Z</updated>` +
+		`<link rel="alternate" type="text/html" href="https://blog.example.com/posts"></link><link rel="self" type="application/atom+xml" href="https://blog.example.com/feed.atom"></link>` +
+		`<author><name>Lanre Adelowo</name><email>me@lanre.me</email></author>` +
+		`<entry><title>Testing is key</title><id>tag:blog.example.com,2017-01-20:posts/3</id><link rel="alternate" type="text/html" href="https://blog.example.com/posts/Testing-is-key"></link>` +
+		`<published>2017-01-20T10:00:00Z</published><updated>2017-01-22T10:00:00Z</updated><author><name>Lanre Adelowo</name><uri>https://blog.example.com/posts?author=hades</uri></author>` +
+		`<category term="Go"></category><summary type="text">Test all the things</summary><content type="html">&lt;p&gt;Test &lt;em&gt;all&lt;/em&gt; the things&lt;/p&gt;&#xA;</content></entry>` +
+		`<entry><title>Hello world</title><id>tag:blog.example.com,2017-01-19:posts/1</id><link rel="alternate" type="text/html" href="https://blog.example.com/posts/Hello-world"></link>` +
+		`<published>2017-01-19T10:00:00Z</published><updated>2017-01-19T10:00:00Z</updated><author><name>horus</name><uri>https://blog.example.com/posts?author=horus</uri></author>` +
+		`<summary type="text">Hi</summary><content type="html">&lt;p&gt;Hi&lt;/p&gt;&#xA;</content></entry></feed>`
+
+	assert.Equal(t, expected, rr.Body.String())
+}
+
+func TestJSONFeedOfPublishedPosts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE}).Once().Return(feedPosts()[:1], nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/feed.json", "/feed.json", JSONFeed(h), nil)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Equal(t, "application/feed+json; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	expected := `{"version":"https://jsonfeed.org/version/1.1","title":"Reblog","home_page_url":"https://blog.example.com/posts","feed_url":"https://blog.example.com/feed.json",
+"description":"Writing about Go","authors":[{"name":"Lanre Adelowo"}],"items":[{"id":"tag:blog.example.com,2017-01-20:posts/3","url":"https://blog.example.com/posts/Testing-is-key",
+"title":"Testing is key","content_html":"<p>Test <em>all</em> the things</p>\n","summary":"Test all the things","date_published":"2017-01-20T10:00:00Z",
+"date_modified":"2017-01-22T10:00:00Z","authors":[{"name":"Lanre Adelowo","url":"https://blog.example.com/posts?author=hades"}],"tags":["Go"]}]}`
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAnEmptyFeed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE}).Once().Return([]models.Post{}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: Site{Title: "Reblog", URL: "https://blog.example.com"}}
+
+	rr := serveFeedRequest(t, h, "/feed.json", "/feed.json", JSONFeed(h), nil)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Empty(t, rr.Header().Get("Last-Modified"))
+
+	expected := `{"version":"https://jsonfeed.org/version/1.1","title":"Reblog","home_page_url":"https://blog.example.com/posts","feed_url":"https://blog.example.com/feed.json","items":[]}`
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestFeedsSupportConditionalRequests(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE}).Times(3).Return(feedPosts(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/feed.atom", "/feed.atom", AtomFeed(h), nil)
+
+	etag := rr.Header().Get("ETag")
+
+	rr = serveFeedRequest(t, h, "/feed.atom", "/feed.atom", AtomFeed(h), map[string]string{"If-None-Match": etag})
+
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotModified, status)
+	}
+
+	assert.Empty(t, rr.Body.String())
+
+	rr = serveFeedRequest(t, h, "/feed.atom", "/feed.atom", AtomFeed(h), map[string]string{"If-Modified-Since": "Sun, 22 Jan 2017 10:00:00 GMT"})
+
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotModified, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestAFeedIsServedAgainOnceItChanges(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE}).Once().Return(feedPosts(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/feed.rss", "/feed.rss", RSSFeed(h), map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": "Sat, 21 Jan 2017 10:00:00 GMT"})
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+}
+
+func TestAnAuthorHasTheirOwnFeed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindByMoniker", mock.Anything, "hades").Once().Return(models.User{ID: 2, Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"}, nil)
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{PerPage: FEED_SIZE, Author: "hades"}).Once().Return([]models.Post{}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/authors/:moniker/feed.json", "/authors/hades/feed.json", JSONFeed(h), nil)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := `{"version":"https://jsonfeed.org/version/1.1","title":"Reblog - Lanre Adelowo","home_page_url":"https://blog.example.com/posts?author=hades",
+"feed_url":"https://blog.example.com/authors/hades/feed.json","description":"Gopher","authors":[{"name":"Lanre Adelowo"}],"items":[]}`
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAnUnknownAuthorHasNoFeed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindByMoniker", mock.Anything, "nobody").Once().Return(models.User{}, errors.New("User does not exist"))
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveFeedRequest(t, h, "/authors/:moniker/feed.rss", "/authors/nobody/feed.rss", RSSFeed(h), nil)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotFound, status)
+	}
+}
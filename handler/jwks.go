@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/utils"
+	"github.com/pressly/chi/render"
+	"net/http"
+)
+
+//JWKS publishes the public keys access tokens can be verified with,
+//so other services can check them without sharing a secret with reblog
+func JWKS(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Keys []utils.JWK `json:"keys"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		//Keys are rotated ahead of time, a short cache is enough to pick up new ones
+		w.Header().Set("Cache-Control", "public, max-age=300")
+
+		render.JSON(w, r, &res{h.JWT.JWKS()})
+	}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/adelowo/reblog/utils"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJWKSPublishesThePublicKeys(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	JWT := utils.MustNewJWTGenerator(
+		utils.SigningKey{ID: "secret", Method: jwt.SigningMethodHS256, Key: []byte("secret")},
+		utils.SigningKey{ID: "ec", Method: jwt.SigningMethodES256, Key: key})
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: JWT}
+
+	req, err := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(JWKS(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	var res struct {
+		Keys []map[string]string `json:"keys"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	//The HS256 secret is never published
+	if len(res.Keys) != 1 {
+		t.Fatalf("Expected 1 key. Got %d", len(res.Keys))
+	}
+
+	assert.Equal(t, "ec", res.Keys[0]["kid"])
+	assert.Equal(t, "EC", res.Keys[0]["kty"])
+	assert.Equal(t, "ES256", res.Keys[0]["alg"])
+	assert.Equal(t, "P-256", res.Keys[0]["crv"])
+	assert.NotEmpty(t, res.Keys[0]["x"])
+	assert.NotEmpty(t, res.Keys[0]["y"])
+	assert.Equal(t, "public, max-age=300", rr.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//Creates a post with the given Markdown and returns what would have been saved
+func createPostWithContent(t *testing.T, content string) models.Post {
+
+	body, err := json.Marshal(map[string]string{"title": "Markdown is neat", "content": content})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/create", bytes.NewBuffer(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	var created models.Post
+
+	db.On("FindPostByTitle", mock.Anything, "Markdown is neat").Once().Return(models.Post{}, errors.New("Post does not exists"))
+
+	db.On("CreatePost", mock.Anything, mock.AnythingOfType("*models.Post")).Once().
+		Run(func(args mock.Arguments) {
+			created = *args.Get(1).(*models.Post)
+		}).
+		Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+
+	return created
+}
+
+func TestPostContentIsRenderedFromMarkdown(t *testing.T) {
+
+	content := "# Markdown is neat\n\n" +
+		"Writing in *Markdown* beats writing HTML[^1].\nIt really does.\n\n" +
+		"| Syntax | Output |\n| ------ | ------ |\n| `*a*` | *a* |\n\n" +
+		"```go\nfmt.Println(\"hello\")\n```\n\n" +
+		"[^1]: Most of the time.\n"
+
+	p := createPostWithContent(t, content)
+
+	assert.Equal(t, content, p.Content)
+
+	assert.Contains(t, p.ContentHTML, "<h1")
+	assert.Contains(t, p.ContentHTML, "<em>Markdown</em>")
+	assert.Contains(t, p.ContentHTML, "<table>")
+	assert.Contains(t, p.ContentHTML, "<th>Syntax</th>")
+	assert.Contains(t, p.ContentHTML, `<code class="language-go">`)
+	assert.Contains(t, p.ContentHTML, "Most of the time.")
+	assert.Contains(t, p.ContentHTML, `href="#fn:1"`)
+
+	assert.Equal(t, "Writing in Markdown beats writing HTML. It really does.", p.Excerpt)
+	assert.Equal(t, 1, p.ReadingTime)
+}
+
+func TestScriptsAndEventHandlersAreStrippedFromPostContent(t *testing.T) {
+
+	content := "Hello there, this post tries to be sneaky.\n\n" +
+		"<script>alert('pwned')</script>\n\n" +
+		`<img src="/cat.png" onerror="alert('pwned')" alt="cat">` + "\n\n" +
+		`<a href="javascript:alert('pwned')" onclick="alert('pwned')">click</a>` + "\n\n" +
+		"[also click](javascript:alert('pwned'))\n"
+
+	p := createPostWithContent(t, content)
+
+	html := strings.ToLower(p.ContentHTML)
+
+	for _, forbidden := range []string{"<script", "alert(", "onerror", "onclick", "javascript:"} {
+		assert.NotContains(t, html, forbidden)
+	}
+
+	assert.Contains(t, p.ContentHTML, `<img src="/cat.png" alt="cat">`)
+	assert.Equal(t, "Hello there, this post tries to be sneaky.", p.Excerpt)
+}
+
+func TestReadingTimeIsEstimatedFromTheWordCount(t *testing.T) {
+
+	p := createPostWithContent(t, strings.Repeat("word ", 401))
+
+	assert.Equal(t, 3, p.ReadingTime)
+
+	assert.True(t, len([]rune(p.Excerpt)) <= utils.EXCERPT_LENGTH+1)
+	assert.True(t, strings.HasSuffix(p.Excerpt, "word…"))
+}
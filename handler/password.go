@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/reblog/mailer"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"log"
+	"net/http"
+	"time"
+)
+
+//ForgotPassword emails a link to reset their password to the user with the given email.
+//The response is the same whether there is such a user or not, so it can't be used to find out who has an account.
+func ForgotPassword(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Email string `json:"email"`
+	}
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data d
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || !utils.IsEmail(data.Email) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Please provide a valid email address"})
+			return
+		}
+
+		if user, err := h.DB.FindByEmail(r.Context(), data.Email); err == nil {
+			if err := sendPasswordReset(h, r, user); err != nil {
+				log.Printf("Could not send a password reset link to user %d: %v", user.ID, err)
+			}
+		}
+
+		render.JSON(w, r, &res{true, "If an account uses this email address, a link to reset its password has been sent to it"})
+	}
+}
+
+func sendPasswordReset(h *Handler, r *http.Request, user models.User) error {
+
+	token, err := h.DB.CreatePasswordReset(r.Context(), user.ID)
+
+	if err != nil {
+		return err
+	}
+
+	m, err := mailer.Render(user.Email, "Reset your password", "password_reset", struct {
+		Name      string
+		Site      string
+		Link      string
+		ExpiresIn time.Duration
+	}{user.Name, h.Site.Title, h.Site.baseURL() + "/password/reset/" + token, models.PASSWORD_RESET_TTL})
+
+	if err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(r.Context(), m)
+}
+
+//ResetPassword sets a new password for the user a password reset token was sent to.
+//Every refresh token of the user is revoked, so they have to log in again everywhere.
+func ResetPassword(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Password string `json:"password"`
+	}
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data d
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || len(data.Password) < 10 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your password should have a length greater than 10"})
+			return
+		}
+
+		ctx := r.Context()
+
+		reset, err := h.DB.FindPasswordReset(ctx, chi.URLParam(r, "token"))
+
+		if err != nil || reset.UsedAt != nil || reset.Expired(time.Now()) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "This link is invalid or has expired. Please ask for a new one"})
+			return
+		}
+
+		err = h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.UsePasswordReset(ctx, reset); err != nil {
+				return err
+			}
+
+			if err := tx.UpdatePassword(ctx, reset.UserID, data.Password); err != nil {
+				return err
+			}
+
+			return tx.RevokeUserRefreshTokens(ctx, reset.UserID)
+		})
+
+		//Someone else used the same token in the meantime
+		if err == models.ErrPasswordResetUsed {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "This link is invalid or has expired. Please ask for a new one"})
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried resetting your password"})
+			return
+		}
+
+		render.JSON(w, r, &res{true, "Your password has been reset. Please log in with your new password"})
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/adelowo/reblog/mailer"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeMailer keeps the messages it is asked to send
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (f *fakeMailer) Send(ctx context.Context, m mailer.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, m)
+
+	return nil
+}
+
+func (f *fakeMailer) messages() []mailer.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]mailer.Message(nil), f.sent...)
+}
+
+//failingMailer can't send any message, like when the mail server is down
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, m mailer.Message) error {
+	return errors.New("Connection refused")
+}
+
+const forgotPasswordResponse = `{"status":true,"message":"If an account uses this email address, a link to reset its password has been sent to it"}`
+
+func passwordRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Post("/login", PostLogin(h))
+	r.Post("/token/refresh", RefreshToken(h))
+	r.Post("/password/forgot", ForgotPassword(h))
+	r.Post("/password/reset/:token", ResetPassword(h))
+
+	return r
+}
+
+func TestAPasswordCanBeReset(t *testing.T) {
+
+	db := memory.New()
+
+	if err := db.CreateUser(context.Background(), &models.User{Moniker: "adelowo", Name: "Lanre Adelowo", Email: "adelowo@me.com", Password: "badpassword"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := passwordRouter(h)
+
+	login := postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "badpassword"}`, "")
+
+	before := decodeTokens(t, login)
+
+	rr := postJSON(t, r, "/password/forgot", `{"email" : "adelowo@me.com"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, forgotPasswordResponse, rr.Body.String())
+
+	sent := m.messages()
+
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 email. Got %d", len(sent))
+	}
+
+	assert.Equal(t, "adelowo@me.com", sent[0].To)
+
+	prefix := "https://blog.example.com/password/reset/"
+
+	i := strings.Index(sent[0].Text, prefix)
+
+	if i == -1 {
+		t.Fatalf("The email does not contain a reset link: %s", sent[0].Text)
+	}
+
+	link := strings.Fields(sent[0].Text[i:])[0]
+	path := strings.TrimPrefix(link, "https://blog.example.com")
+
+	rr = postJSON(t, r, path, `{"password" : "anewandbetterpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":true,"message":"Your password has been reset. Please log in with your new password"}`, rr.Body.String())
+
+	//The link can only be used once
+	rr = postJSON(t, r, path, `{"password" : "yetanotherpassword"}`, "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	//Sessions started with the old password are over
+	rr = postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+before.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "badpassword"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "anewandbetterpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestForgotPasswordDoesNotRevealWhetherTheEmailIsKnown(t *testing.T) {
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: memory.New(), JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	rr := postJSON(t, passwordRouter(h), "/password/forgot", `{"email" : "nobody@me.com"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, forgotPasswordResponse, rr.Body.String())
+	assert.Empty(t, m.messages())
+}
+
+func TestForgotPasswordNeedsAnEmail(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT(), Mailer: &fakeMailer{}, Site: testSite}
+
+	rr := postJSON(t, passwordRouter(h), "/password/forgot", `{"email" : "nobody"}`, "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Please provide a valid email address"}`, rr.Body.String())
+}
+
+func TestAnExpiredPasswordResetCannotBeUsed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPasswordReset", mock.Anything, "expired").
+		Return(models.PasswordReset{ID: 1, UserID: 1, ExpiresAt: time.Now().Add(-time.Minute)}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: &fakeMailer{}, Site: testSite}
+
+	rr := postJSON(t, passwordRouter(h), "/password/reset/expired", `{"password" : "anewandbetterpassword"}`, "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"This link is invalid or has expired. Please ask for a new one"}`, rr.Body.String())
+
+	db.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAResetPasswordMustBeLongEnough(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT(), Mailer: &fakeMailer{}, Site: testSite}
+
+	rr := postJSON(t, passwordRouter(h), "/password/reset/token", `{"password" : "short"}`, "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Your password should have a length greater than 10"}`, rr.Body.String())
+}
@@ -11,6 +11,9 @@ import (
 	"github.com/pressly/chi/render"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
 const (
@@ -21,13 +24,18 @@ const (
 func CreatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 
 	type d struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
+		Title     string     `json:"title"`
+		Content   string     `json:"content"`
+		PublishAt *time.Time `json:"publish_at"`
+		Category  string     `json:"category"`
+		Tags      []string   `json:"tags"`
 	}
 
 	type errorMessages struct {
-		Title   string `json:"title"`
-		Content string `json:"content"`
+		Title     string `json:"title"`
+		Content   string `json:"content"`
+		PublishAt string `json:"publish_at"`
+		Category  string `json:"category"`
 	}
 
 	type res struct {
@@ -36,7 +44,8 @@ func CreatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 		Errors  errorMessages `json:"errors"`
 	}
 
-	//If the author of the post isn't the admin, mark the post as unpublished
+	//If the author of the post isn't the admin, mark the post as unpublished.
+	//An admin can schedule a post by giving it a publishing date in the future.
 	return func(w http.ResponseWriter, r *http.Request) {
 		var data d
 
@@ -48,28 +57,22 @@ func CreatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		//Validate
-		errorBag := bag.NewValidatorErrorBag()
+		titleErr, contentError, ok := validatePost(data.Title, data.Content)
+		publishAtErr, publishAtOk := validatePublishAt(data.PublishAt)
 
-		if len(data.Title) < 10 {
-			errorBag.Add("title", "An article's title should be more than 10 characters")
+		//Scheduling a post publishes it, so it is left to those who may publish
+		if publishAtOk && data.PublishAt != nil && !middleware.Can(r, models.POST_PUBLISH) {
+			publishAtErr, publishAtOk = "You do not have permission to schedule posts", false
 		}
 
-		if len(data.Content) < 100 {
-			errorBag.Add("content", "The content of the article is too small. Should be at least 100 characters in length")
-		}
-
-		if errorBag.Count() != 0 {
-			titleErr, _ := errorBag.Get("title")
-			contentError, _ := errorBag.Get("content")
-
+		if !ok || !publishAtOk {
 			w.WriteHeader(http.StatusBadRequest)
-			render.JSON(w, r, &res{false, "Post could not be created due to invalid data", errorMessages{titleErr, contentError}})
+			render.JSON(w, r, &res{false, "Post could not be created due to invalid data", errorMessages{Title: titleErr, Content: contentError, PublishAt: publishAtErr}})
 			return
 
 		}
 
-		_, err := h.DB.FindPostByTitle(data.Title)
+		_, err := h.DB.FindPostByTitle(r.Context(), data.Title)
 
 		if err == nil {
 			//post exists
@@ -78,7 +81,7 @@ func CreatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		userType, err := getUserType(r)
+		userID, err := getUser(r)
 
 		if err != nil {
 			//this shouldn't happen though, just paranoia
@@ -87,7 +90,10 @@ func CreatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 		}
 		var status int
 
-		if userType == middleware.ADMIN {
+		//Posts of users who can't publish wait for a review
+		if data.PublishAt != nil {
+			status = models.SCHEDULED
+		} else if middleware.Can(r, models.POST_PUBLISH) {
 			status = PUBLISHED
 		} else {
 			status = UNPUBLISHED
@@ -95,19 +101,224 @@ func CreatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 
 		slug := h.Slug.Generate(data.Title)
 
-		p := models.Post{Title: data.Title, Content: data.Content, Slug: slug, Status: status}
+		p := models.Post{Title: data.Title, Content: data.Content, Slug: slug, Status: status, PublishAt: data.PublishAt,
+			UserID: userID, Tags: tagsFromNames(h, data.Tags)}
+
+		if data.Category != "" {
+			c, err := h.DB.FindCategoryBySlug(r.Context(), data.Category)
+
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, &res{false, "Post could not be created due to invalid data", errorMessages{Category: "Category does not exist"}})
+				return
+			}
+
+			p.CategoryID = &c.ID
+		}
+
+		if err = renderContent(h, &p); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while trying to create the post", errorMessages{}})
+			return
+		}
 
-		if err = h.DB.CreatePost(p, userType); err == nil {
+		if err = h.DB.CreatePost(r.Context(), &p); err == nil {
 			w.WriteHeader(http.StatusOK)
 			render.JSON(w, r, &res{true, "Post was successfully created", errorMessages{}})
 			return
 		}
 
+		if err == models.ErrPostExists {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Could not create post as that would lead to duplicates", errorMessages{Title: "Post with title, " + data.Title + " already exists"}})
+			return
+		}
+
 		w.WriteHeader(http.StatusInternalServerError)
 		render.JSON(w, r, &res{false, "An error occurred while trying to create the post", errorMessages{}})
 	}
 }
 
+//Lets the author of a post or an admin change the post's title, content, publishing date, category and/or tags.
+//Giving a publishing date schedules the post, which only those who may publish can do.
+//The slug is regenerated whenever the title changes.
+func UpdatePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Title     string     `json:"title"`
+		Content   string     `json:"content"`
+		PublishAt *time.Time `json:"publish_at"`
+		Category  *string    `json:"category"`
+		Tags      []string   `json:"tags"`
+	}
+
+	type errorMessages struct {
+		PostID    string `json:"post_id"`
+		Title     string `json:"title"`
+		Content   string `json:"content"`
+		PublishAt string `json:"publish_at"`
+		Category  string `json:"category"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid request", errorMessages{PostID: "Invalid post id"}})
+			return
+		}
+
+		var data d
+
+		decoder := json.NewDecoder(r.Body)
+
+		if err := decoder.Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Post could not be updated", errorMessages{}})
+			return
+		}
+
+		if _, err := getUser(r); err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		p, err := h.DB.FindPostByID(r.Context(), id)
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Post does not exist", errorMessages{PostID: "Post with the specified id does not exist"}})
+			return
+		}
+
+		if !canEditPost(r, p) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "You do not have permission to edit this post", errorMessages{}})
+			return
+		}
+
+		if !canEditLivePost(r, p) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "You do not have permission to edit a " + models.StatusName(p.Status) + " post", errorMessages{}})
+			return
+		}
+
+		//Fields that were left out are not updated
+		title, content := p.Title, p.Content
+
+		if data.Title != "" {
+			title = data.Title
+		}
+
+		if data.Content != "" {
+			content = data.Content
+		}
+
+		titleErr, contentErr, ok := validatePost(title, content)
+		publishAtErr, publishAtOk := validatePublishAt(data.PublishAt)
+
+		//Scheduling a post publishes it, so it is left to those who may publish
+		if publishAtOk && data.PublishAt != nil {
+			if !middleware.Can(r, models.POST_PUBLISH) {
+				publishAtErr, publishAtOk = "You do not have permission to schedule posts", false
+			} else if p.Status != models.SCHEDULED && !models.CanTransition(p.Status, models.SCHEDULED) {
+				publishAtErr, publishAtOk = "A "+models.StatusName(p.Status)+" post cannot be scheduled", false
+			}
+		}
+
+		if !ok || !publishAtOk {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Post could not be updated due to invalid data", errorMessages{Title: titleErr, Content: contentErr, PublishAt: publishAtErr}})
+			return
+		}
+
+		if title != p.Title {
+			if existing, err := h.DB.FindPostByTitle(r.Context(), title); err == nil && existing.ID != p.ID {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, &res{false, "Could not update post as that would lead to duplicates", errorMessages{Title: "Post with title, " + title + " already exists"}})
+				return
+			}
+
+			p.Title = title
+			p.Slug = h.Slug.Generate(title)
+		}
+
+		p.Content = content
+
+		if data.PublishAt != nil {
+			p.PublishAt = data.PublishAt
+		}
+
+		//An empty category removes the post from its category
+		if data.Category != nil && *data.Category == "" {
+			p.CategoryID = nil
+		} else if data.Category != nil {
+			c, err := h.DB.FindCategoryBySlug(r.Context(), *data.Category)
+
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, &res{false, "Post could not be updated due to invalid data", errorMessages{Category: "Category does not exist"}})
+				return
+			}
+
+			p.CategoryID = &c.ID
+		}
+
+		//Tags are left alone unless given. An empty list removes them all
+		p.Tags = tagsFromNames(h, data.Tags)
+
+		if err = renderContent(h, &p); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while trying to update the post", errorMessages{}})
+			return
+		}
+
+		ctx := r.Context()
+
+		if data.PublishAt != nil && p.Status != models.SCHEDULED {
+			err = h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+				if err := tx.UpdatePost(ctx, &p); err != nil {
+					return err
+				}
+
+				return tx.TransitionPost(ctx, &p, models.SCHEDULED, "")
+			})
+		} else {
+			err = h.DB.UpdatePost(ctx, &p)
+		}
+
+		if err == nil {
+			w.WriteHeader(http.StatusOK)
+			render.JSON(w, r, &res{true, "Post was updated", errorMessages{}})
+			return
+		}
+
+		if err == models.ErrInvalidTransition {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Post could not be updated due to invalid data", errorMessages{PublishAt: "The post was changed in the meantime and could not be scheduled"}})
+			return
+		}
+
+		if err == models.ErrPostExists {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Could not update post as that would lead to duplicates", errorMessages{Title: "Post with title, " + title + " already exists"}})
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, &res{false, "An error occurred while trying to update the post", errorMessages{}})
+	}
+}
+
 func DeletePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 
 	type res struct {
@@ -130,7 +341,7 @@ func DeletePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		p, err := h.DB.FindPostByID(id)
+		p, err := h.DB.FindPostByID(r.Context(), id)
 
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -140,7 +351,7 @@ func DeletePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err = h.DB.DeletePost(p); err == nil {
+		if err = h.DB.DeletePost(r.Context(), p); err == nil {
 			w.WriteHeader(http.StatusOK)
 			render.JSON(w, r, &res{true, "Post was deleted", struct {
 				PostID string `json:"post_id"`
@@ -178,7 +389,7 @@ func UnpublishPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		p, err := h.DB.FindPostByID(id)
+		p, err := h.DB.FindPostByID(r.Context(), id)
 
 		if err != nil {
 			w.WriteHeader(http.StatusNotFound)
@@ -188,7 +399,7 @@ func UnpublishPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err = h.DB.UnpublishPost(p); err == nil {
+		if err = h.DB.UnpublishPost(r.Context(), p); err == nil {
 			w.WriteHeader(http.StatusOK)
 			render.JSON(w, r, &res{true, "Post was updated", struct {
 				PostID string `json:"post_id"`
@@ -196,6 +407,14 @@ func UnpublishPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if err == models.ErrInvalidTransition {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "A " + models.StatusName(p.Status) + " post cannot be unpublished", struct {
+				PostID string `json:"post_id"`
+			}{"Post could not be unpublished"}})
+			return
+		}
+
 		w.WriteHeader(http.StatusInternalServerError)
 		render.JSON(w, r, &res{false, "An error occurred while trying to unpublish the post", struct {
 			PostID string `json:"post_id"`
@@ -203,17 +422,91 @@ func UnpublishPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getUserType(r *http.Request) (int, error) {
+//Validates an article's title and content.
+//Both CreatePost and UpdatePost apply the same rules.
+func validatePost(title, content string) (titleErr string, contentErr string, ok bool) {
+	errorBag := bag.NewValidatorErrorBag()
+
+	if len(title) < 10 {
+		errorBag.Add("title", "An article's title should be more than 10 characters")
+	}
+
+	if len(content) < 100 {
+		errorBag.Add("content", "The content of the article is too small. Should be at least 100 characters in length")
+	}
+
+	titleErr, _ = errorBag.Get("title")
+	contentErr, _ = errorBag.Get("content")
+
+	return titleErr, contentErr, errorBag.Count() == 0
+}
+
+//Renders the post's Markdown into sanitized HTML along with its excerpt and reading time.
+//Every post is saved after going through here, so its title and content are stripped of control characters first
+func renderContent(h *Handler, p *models.Post) error {
+	p.Title = stripControlCharacters(p.Title)
+	p.Content = stripControlCharacters(p.Content)
+
+	rendered, err := h.Markdown.Render(p.Content)
+
+	if err != nil {
+		return err
+	}
+
+	p.ContentHTML = rendered.HTML
+	p.Excerpt = rendered.Excerpt
+	p.ReadingTime = rendered.ReadingTime
+
+	return nil
+}
+
+//Search snippets mark matches with control characters, see models.HIGHLIGHT_START, so posts can't hold any.
+//Line breaks and tabs are kept
+func stripControlCharacters(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, s)
+}
+
+//A publishing date is optional but has to be in the future
+func validatePublishAt(publishAt *time.Time) (string, bool) {
+	if publishAt != nil && !publishAt.After(time.Now()) {
+		return "The publishing date should be in the future", false
+	}
+
+	return "", true
+}
+
+//Some roles can edit every post, others only what the user wrote
+func canEditPost(r *http.Request, p models.Post) bool {
+	return middleware.Can(r, models.POST_EDIT_ANY) || middleware.CanOwn(r, models.POST_EDIT_OWN, p.UserID)
+}
+
+//Edits to a published or scheduled post go live without any review, so only those who may publish can make them
+func canEditLivePost(r *http.Request, p models.Post) bool {
+	return (p.Status != models.PUBLISHED && p.Status != models.SCHEDULED) || middleware.Can(r, models.POST_PUBLISH)
+}
+
+//Fetches the id of the user making the request
+func getUser(r *http.Request) (int, error) {
 
 	ctx := r.Context()
 
 	jwtToken, ok := ctx.Value("jwt").(*jwt.Token)
 
 	if !ok || jwtToken == nil || !jwtToken.Valid {
-		return 0, errors.New("Could not fetch  user's id")
+		return 0, errors.New("Could not fetch user's id")
 	}
 
-	claims := jwtToken.Claims
+	userID, ok := jwtToken.Claims["userID"].(float64)
+
+	if !ok {
+		return 0, errors.New("Could not fetch user's id")
+	}
 
-	return int(claims["type"].(float64)), nil
+	return int(userID), nil
 }
@@ -4,15 +4,19 @@ import (
 	"bytes"
 	"github.com/adelowo/reblog/middleware"
 	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
 	"github.com/adelowo/reblog/models/mocks"
 	"github.com/adelowo/reblog/utils"
 	"github.com/pkg/errors"
 	"github.com/pressly/chi"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"golang.org/x/net/context"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 )
 
 var _ models.DataStore = new(mocks.DataStore)
@@ -23,7 +27,7 @@ func TestCannotCreatePostDueToInvalidData(t *testing.T) {
 
 	data := []byte(`{"title" : "go", "content" : "go code"}`)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator(), Slug: utils.NewSlugGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
 
 	req, err := http.NewRequest("POST", "/reblog/posts/create", bytes.NewBuffer(data))
 
@@ -40,7 +44,7 @@ func TestCannotCreatePostDueToInvalidData(t *testing.T) {
 		t.Fatal(status)
 	}
 
-	expectedText := string(`{"status": false, "message" : "Post could not be created due to invalid data", "errors" : {"title" : "An article's title should be more than 10 characters", "content" : "The content of the article is too small. Should be at least 100 characters in length"}}`)
+	expectedText := string(`{"status": false, "message" : "Post could not be created due to invalid data", "errors" : {"title" : "An article's title should be more than 10 characters", "content" : "The content of the article is too small. Should be at least 100 characters in length", "publish_at" : "", "category" : ""}}`)
 
 	assert.JSONEq(t, expectedText, rr.Body.String(), "The response body differs")
 }
@@ -58,11 +62,11 @@ func TestPostCouldNotBeCreatedBecauseItAlreadyExists(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindPostByTitle", "Go is awesome").
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").
 		Once().
 		Return(models.Post{}, nil)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator(), Slug: utils.NewSlugGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
 
 	rr := httptest.NewRecorder()
 
@@ -73,7 +77,7 @@ func TestPostCouldNotBeCreatedBecauseItAlreadyExists(t *testing.T) {
 		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
 	}
 
-	expectedText := string(`{"status":false, "message":"Could not create post as that would lead to duplicates", "errors":{"title" : "Post with title, Go is awesome already exists","content" :""}}`)
+	expectedText := string(`{"status":false, "message":"Could not create post as that would lead to duplicates", "errors":{"title" : "Post with title, Go is awesome already exists","content" :"","publish_at":"","category":""}}`)
 
 	assert.JSONEq(t, expectedText, rr.Body.String())
 }
@@ -89,26 +93,24 @@ func TestACollaboratorCanCreateAPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindPostByTitle", "Go is awesome").
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").
 		Once().
 		Return(models.Post{}, errors.New("Post could not be found")) //like seriously ?
 
-	p := models.Post{Title: "Go is awesome", Content: "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome", Slug: "Go-is-awesome", Status: UNPUBLISHED}
+	p := withRenderedContent(models.Post{Title: "Go is awesome", Content: "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome", Slug: "Go-is-awesome", Status: UNPUBLISHED, UserID: 51})
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator(), Slug: utils.NewSlugGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 51
 	claims["moniker"] = "collab"
-	claims["type"] = middleware.COLLABORATOR
+	claims["permissions"] = permissionsOf(models.CONTRIBUTOR)
 
-	db.On("CreatePost", p, claims["type"]).
+	db.On("CreatePost", mock.Anything, &p).
 		Return(nil)
 
-	h.JWT.Claims(claims)
-
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("51", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -134,7 +136,7 @@ func TestACollaboratorCanCreateAPost(t *testing.T) {
 		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
 	}
 
-	expectedText := string(`{"status" : true, "message" : "Post was successfully created", "errors":{"title":"", "content":""}}`)
+	expectedText := string(`{"status" : true, "message" : "Post was successfully created", "errors":{"title":"", "content":"", "publish_at":"","category":""}}`)
 
 	assert.JSONEq(t, expectedText, rr.Body.String())
 }
@@ -150,26 +152,24 @@ func TestAdminCanCreateAPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindPostByTitle", "Go is awesome").
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").
 		Once().
 		Return(models.Post{}, errors.New("Post could not be found"))
 
-	p := models.Post{Title: "Go is awesome", Content: "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome", Slug: "Go-is-awesome", Status: PUBLISHED}
+	p := withRenderedContent(models.Post{Title: "Go is awesome", Content: "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome", Slug: "Go-is-awesome", Status: PUBLISHED, UserID: 51})
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator(), Slug: utils.NewSlugGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 51
 	claims["moniker"] = "collab"
-	claims["type"] = middleware.ADMIN
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	db.On("CreatePost", p, claims["type"]).
+	db.On("CreatePost", mock.Anything, &p).
 		Return(nil)
 
-	h.JWT.Claims(claims)
-
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("51", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -195,7 +195,7 @@ func TestAdminCanCreateAPost(t *testing.T) {
 		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
 	}
 
-	expectedText := string(`{"status" : true, "message" : "Post was successfully created", "errors":{"title":"", "content":""}}`)
+	expectedText := string(`{"status" : true, "message" : "Post was successfully created", "errors":{"title":"", "content":"", "publish_at":"","category":""}}`)
 
 	assert.JSONEq(t, expectedText, rr.Body.String())
 }
@@ -211,26 +211,24 @@ func TestAnErrorOccurredWhileTryingToCreateAPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindPostByTitle", "Go is awesome").
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").
 		Once().
 		Return(models.Post{}, errors.New("Post could not be found"))
 
-	p := models.Post{Title: "Go is awesome", Content: "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome", Slug: "Go-is-awesome", Status: PUBLISHED}
+	p := withRenderedContent(models.Post{Title: "Go is awesome", Content: "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome", Slug: "Go-is-awesome", Status: PUBLISHED, UserID: 51})
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator(), Slug: utils.NewSlugGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 51
 	claims["moniker"] = "collab"
-	claims["type"] = middleware.ADMIN
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	db.On("CreatePost", p, claims["type"]).
+	db.On("CreatePost", mock.Anything, &p).
 		Return(errors.New("Could not create post"))
 
-	h.JWT.Claims(claims)
-
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("51", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -256,16 +254,100 @@ func TestAnErrorOccurredWhileTryingToCreateAPost(t *testing.T) {
 		t.Fatalf("Expected %d. Got %d instead", http.StatusInternalServerError, status)
 	}
 
-	expectedText := string(`{"status" : false, "message" : "An error occurred while trying to create the post", "errors":{"title":"", "content":""}}`)
+	expectedText := string(`{"status" : false, "message" : "An error occurred while trying to create the post", "errors":{"title":"", "content":"", "publish_at":"","category":""}}`)
+
+	assert.JSONEq(t, expectedText, rr.Body.String())
+}
+
+func TestAPostCreatedInTheMeantimeIsReportedAsADuplicate(t *testing.T) {
+	data := []byte(`{"title" : "Go is awesome", "content": "` + validContent + `"}`)
+
+	req, err := http.NewRequest("POST", "/reblog/post/create", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").
+		Once().
+		Return(models.Post{}, errors.New("Post could not be found"))
+
+	p := withRenderedContent(models.Post{Title: "Go is awesome", Content: validContent, Slug: "Go-is-awesome", Status: PUBLISHED, UserID: 1})
+
+	db.On("CreatePost", mock.Anything, &p).
+		Once().
+		Return(models.ErrPostExists)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
+	}
+
+	expectedText := string(`{"status" : false, "message" : "Could not create post as that would lead to duplicates", "errors":{"title":"Post with title, Go is awesome already exists", "content":"", "publish_at":"","category":""}}`)
 
 	assert.JSONEq(t, expectedText, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestTheSameTitleCannotBeUsedForTwoPosts(t *testing.T) {
+
+	db := memory.New()
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	create := func() *httptest.ResponseRecorder {
+		data := []byte(`{"title" : "Go is awesome", "content": "` + validContent + `"}`)
+
+		req, err := http.NewRequest("POST", "/reblog/post/create", bytes.NewBuffer(data))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req = authenticate(t, h, req, 7, models.CONTRIBUTOR)
+
+		rr := httptest.NewRecorder()
+
+		http.HandlerFunc(CreatePost(h)).
+			ServeHTTP(rr, req)
+
+		return rr
+	}
+
+	if status := create().Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d instead", http.StatusOK, status)
+	}
+
+	if status := create().Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
+	}
+
+	p, err := db.FindPostByTitle(context.Background(), "Go is awesome")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 7, p.UserID)
+	assert.Equal(t, UNPUBLISHED, p.Status)
 }
 
 func TestAnInvalidRequestCannotBeUsedToDeleteAPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("DELETE", "/reblog/posts/eighty", nil)
 
@@ -294,7 +376,7 @@ func TestACollaboratorCannotDeleteAPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("DELETE", "/reblog/posts/10", nil)
 
@@ -306,11 +388,9 @@ func TestACollaboratorCannotDeleteAPost(t *testing.T) {
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.COLLABORATOR
+	claims["permissions"] = permissionsOf(models.CONTRIBUTOR)
 
-	h.JWT.Claims(claims)
-
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -331,7 +411,7 @@ func TestACollaboratorCannotDeleteAPost(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	r.Handle("/reblog/posts/:id", middleware.Admin(http.HandlerFunc(DeletePost(h))))
+	r.Handle("/reblog/posts/:id", middleware.Require(models.POST_DELETE_ANY)(http.HandlerFunc(DeletePost(h))))
 
 	r.ServeHTTP(rr, req)
 
@@ -350,11 +430,11 @@ func TestAnAdminCanDeleteAPost(t *testing.T) {
 
 	p := models.Post{ID: 10, Status: PUBLISHED, Title: "Testing is key"}
 
-	db.On("FindPostByID", 10).Once().Return(p, nil)
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
 
-	db.On("DeletePost", p).Once().Return(nil)
+	db.On("DeletePost", mock.Anything, p).Once().Return(nil)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("DELETE", "/reblog/posts/10", nil)
 
@@ -366,11 +446,9 @@ func TestAnAdminCanDeleteAPost(t *testing.T) {
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.ADMIN
-
-	h.JWT.Claims(claims)
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -411,11 +489,11 @@ func TestNonExistentPostCannotBeDeleted(t *testing.T) {
 
 	p := models.Post{}
 
-	db.On("FindPostByID", 10).Once().Return(p, errors.New("Post does not exist"))
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, errors.New("Post does not exist"))
 
-	db.On("DeletePost", p).Once().Return(nil)
+	db.On("DeletePost", mock.Anything, p).Once().Return(nil)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	req, err := http.NewRequest("DELETE", "/reblog/posts/10", nil)
 
@@ -427,11 +505,9 @@ func TestNonExistentPostCannotBeDeleted(t *testing.T) {
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.ADMIN
-
-	h.JWT.Claims(claims)
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -475,17 +551,15 @@ func TestAdminOnlyCanMarkAPostAsUnpublished(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	h := &Handler{DB: new(mocks.DataStore), JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.COLLABORATOR
+	claims["permissions"] = permissionsOf(models.CONTRIBUTOR)
 
-	h.JWT.Claims(claims)
-
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -502,7 +576,7 @@ func TestAdminOnlyCanMarkAPostAsUnpublished(t *testing.T) {
 
 	req = req.WithContext(ctx)
 
-	middleware.Admin(http.HandlerFunc(UnpublishPost(h))).
+	middleware.Require(models.POST_UNPUBLISH)(http.HandlerFunc(UnpublishPost(h))).
 		ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusUnauthorized {
@@ -528,21 +602,19 @@ func TestAdminCanMarkAPostAsUnpublished(t *testing.T) {
 
 	p := models.Post{ID: 80}
 
-	db.On("FindPostByID", 80).Once().Return(p, nil)
+	db.On("FindPostByID", mock.Anything, 80).Once().Return(p, nil)
 
-	db.On("UnpublishPost", p).Once().Return(nil)
+	db.On("UnpublishPost", mock.Anything, p).Once().Return(nil)
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.ADMIN
-
-	h.JWT.Claims(claims)
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -561,7 +633,7 @@ func TestAdminCanMarkAPostAsUnpublished(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	r.Handle("/reblog/posts/:id", middleware.Admin(http.HandlerFunc(UnpublishPost(h))))
+	r.Handle("/reblog/posts/:id", middleware.Require(models.POST_UNPUBLISH)(http.HandlerFunc(UnpublishPost(h))))
 
 	r.ServeHTTP(rr, req)
 
@@ -586,19 +658,17 @@ func TestCannotDeleteNonExistentPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindPostByID", 80).Once().Return(models.Post{}, errors.New("Post does not exist"))
+	db.On("FindPostByID", mock.Anything, 80).Once().Return(models.Post{}, errors.New("Post does not exist"))
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.ADMIN
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	h.JWT.Claims(claims)
-
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -617,7 +687,7 @@ func TestCannotDeleteNonExistentPost(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	r.Handle("/reblog/posts/:id", middleware.Admin(http.HandlerFunc(UnpublishPost(h))))
+	r.Handle("/reblog/posts/:id", middleware.Require(models.POST_UNPUBLISH)(http.HandlerFunc(UnpublishPost(h))))
 
 	r.ServeHTTP(rr, req)
 
@@ -645,21 +715,19 @@ func TestAnErrorOccurredWhileTryingToUnpublishPost(t *testing.T) {
 
 	db := new(mocks.DataStore)
 
-	db.On("FindPostByID", 80).Once().Return(p, nil)
+	db.On("FindPostByID", mock.Anything, 80).Once().Return(p, nil)
 
-	db.On("UnpublishPost", p).Once().Return(errors.New("Could not unpublish Post"))
+	db.On("UnpublishPost", mock.Anything, p).Once().Return(errors.New("Could not unpublish Post"))
 
-	h := &Handler{DB: db, JWT: utils.NewJWTGenerator()}
+	h := &Handler{DB: db, JWT: newTestJWT()}
 
 	claims := make(map[string]interface{}, 4)
 
 	claims["userID"] = 15
 	claims["moniker"] = "horus"
-	claims["type"] = middleware.ADMIN
-
-	h.JWT.Claims(claims)
+	claims["permissions"] = permissionsOf(models.ADMIN)
 
-	token, err := h.JWT.Generate()
+	token, err := h.JWT.Generate("15", claims)
 
 	if err != nil {
 		t.Fatal(err)
@@ -678,7 +746,7 @@ func TestAnErrorOccurredWhileTryingToUnpublishPost(t *testing.T) {
 
 	r := chi.NewRouter()
 
-	r.Handle("/reblog/posts/:id", middleware.Admin(http.HandlerFunc(UnpublishPost(h))))
+	r.Handle("/reblog/posts/:id", middleware.Require(models.POST_UNPUBLISH)(http.HandlerFunc(UnpublishPost(h))))
 
 	r.ServeHTTP(rr, req)
 
@@ -691,3 +759,597 @@ func TestAnErrorOccurredWhileTryingToUnpublishPost(t *testing.T) {
 	assert.JSONEq(t, expected, rr.Body.String())
 
 }
+
+func TestAPostThatIsNotLiveCannotBeUnpublished(t *testing.T) {
+
+	req, err := http.NewRequest("PUT", "/reblog/posts/80", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	p := models.Post{ID: 80, Status: models.DRAFT}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 80).Once().Return(p, nil)
+
+	db.On("UnpublishPost", mock.Anything, p).Once().Return(models.ErrInvalidTransition)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req = authenticate(t, h, req, 15, models.ADMIN)
+
+	r := chi.NewRouter()
+
+	r.Handle("/reblog/posts/:id", middleware.Require(models.POST_UNPUBLISH)(http.HandlerFunc(UnpublishPost(h))))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"A draft post cannot be unpublished","errors":{"post_id":"Post could not be unpublished"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+//Attaches a decoded jwt for the given user to the request, just like the verifier middleware would.
+//The user has the default permissions of the role
+func authenticate(t *testing.T, h *Handler, req *http.Request, userID int, role string) *http.Request {
+
+	to, err := h.JWT.Decode(testToken(t, h, userID, role))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), "jwt", to))
+}
+
+//testToken generates a jwt for the given user, with the default permissions of the role
+func testToken(t *testing.T, h *Handler, userID int, role string) string {
+	claims := make(map[string]interface{}, 4)
+
+	claims["userID"] = userID
+	claims["moniker"] = "horus"
+	claims["role"] = role
+	claims["permissions"] = permissionsOf(role)
+
+	token, err := h.JWT.Generate(strconv.Itoa(userID), claims)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+//The default permissions of the role
+func permissionsOf(role string) []string {
+	for _, r := range models.DefaultRoles {
+		if r.Name == role {
+			return r.Permissions
+		}
+	}
+
+	return nil
+}
+
+//Adds what the handlers derive from a post's Markdown content.
+//Only meant for plain text content, which renders to a single paragraph
+func withRenderedContent(p models.Post) models.Post {
+	p.ContentHTML = "<p>" + p.Content + "</p>\n"
+	p.Excerpt = p.Content
+	p.ReadingTime = 1
+
+	return p
+}
+
+const validContent = "Go is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesomeGo is awesome"
+
+func TestAnAuthorCanUpdateTheirPost(t *testing.T) {
+
+	data := []byte(`{"title" : "Testing is really key"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, Title: "Testing is key", Slug: "Testing-is-key", Content: validContent, UserID: 15}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+
+	db.On("FindPostByTitle", mock.Anything, "Testing is really key").Once().Return(models.Post{}, errors.New("Post does not exists"))
+
+	updated := p
+	updated.Title = "Testing is really key"
+	updated.Slug = "Testing-is-really-key"
+	updated = withRenderedContent(updated)
+
+	db.On("UpdatePost", mock.Anything, &updated).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Post was updated","errors":{"post_id":"","title":"","content":"","publish_at":"","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAnAdminCanUpdateAnyPost(t *testing.T) {
+
+	data := []byte(`{"content" : "` + validContent + validContent + `"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, Title: "Testing is key", Slug: "Testing-is-key", Content: validContent, UserID: 15}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+
+	updated := p
+	updated.Content = validContent + validContent
+	updated = withRenderedContent(updated)
+
+	db.On("UpdatePost", mock.Anything, &updated).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestACollaboratorCannotUpdateAnotherAuthorsPost(t *testing.T) {
+
+	data := []byte(`{"title" : "Testing is really key"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is key", Content: validContent, UserID: 15}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 16, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+
+	expected := string(`{"status":false,"message":"You do not have permission to edit this post","errors":{"post_id":"","title":"","content":"","publish_at":"","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAPostCannotBeUpdatedWithInvalidData(t *testing.T) {
+
+	data := []byte(`{"title" : "Go", "content" : "go code"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is key", Content: validContent, UserID: 15}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be updated due to invalid data","errors":{"post_id":"","title":"An article's title should be more than 10 characters","content":"The content of the article is too small. Should be at least 100 characters in length","publish_at":"","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAPostCannotBeRenamedToAnExistingTitle(t *testing.T) {
+
+	data := []byte(`{"title" : "Go is awesome"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is key", Content: validContent, UserID: 15}, nil)
+
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").Once().Return(models.Post{ID: 11, Title: "Go is awesome"}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Could not update post as that would lead to duplicates","errors":{"post_id":"","title":"Post with title, Go is awesome already exists","content":"","publish_at":"","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestANonExistentPostCannotBeUpdated(t *testing.T) {
+
+	data := []byte(`{"title" : "Go is awesome"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{}, errors.New("Post does not exists"))
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotFound, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post does not exist","errors":{"post_id":"Post with the specified id does not exist","title":"","content":"","publish_at":"","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAnAdminCanScheduleAPost(t *testing.T) {
+	publishAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	data := []byte(`{"title" : "Go is awesome", "content": "` + validContent + `", "publish_at" : "` + publishAt.Format(time.RFC3339) + `"}`)
+
+	req, err := http.NewRequest("POST", "/reblog/post/create", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByTitle", mock.Anything, "Go is awesome").
+		Once().
+		Return(models.Post{}, errors.New("Post could not be found"))
+
+	p := withRenderedContent(models.Post{Title: "Go is awesome", Content: validContent, Slug: "Go-is-awesome", Status: models.SCHEDULED, PublishAt: &publishAt, UserID: 1})
+
+	db.On("CreatePost", mock.Anything, &p).
+		Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d instead", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestAPostCannotBeScheduledInThePast(t *testing.T) {
+	data := []byte(`{"title" : "Go is awesome", "content": "` + validContent + `", "publish_at" : "2017-01-01T10:00:00Z"}`)
+
+	req, err := http.NewRequest("POST", "/reblog/post/create", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be created due to invalid data","errors":{"title":"","content":"","publish_at":"The publishing date should be in the future","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAContributorCannotCreateAScheduledPost(t *testing.T) {
+	publishAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	data := []byte(`{"title" : "Go is awesome", "content": "` + validContent + `", "publish_at" : "` + publishAt.Format(time.RFC3339) + `"}`)
+
+	req, err := http.NewRequest("POST", "/reblog/post/create", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d instead", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be created due to invalid data","errors":{"title":"","content":"","publish_at":"You do not have permission to schedule posts","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything)
+}
+
+func TestAnAuthorCanScheduleTheirDraft(t *testing.T) {
+	publishAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	data := []byte(`{"publish_at" : "` + publishAt.Format(time.RFC3339) + `"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, Title: "Testing is key", Slug: "Testing-is-key", Content: validContent, UserID: 15, Status: models.DRAFT}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+
+	updated := withRenderedContent(p)
+	updated.PublishAt = &publishAt
+
+	runTxOn(db)
+
+	db.On("UpdatePost", mock.Anything, &updated).Once().Return(nil)
+
+	db.On("TransitionPost", mock.Anything, &updated, models.SCHEDULED, "").Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.AUTHOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestAContributorCannotScheduleTheirPost(t *testing.T) {
+	publishAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	data := []byte(`{"publish_at" : "` + publishAt.Format(time.RFC3339) + `"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is key", Content: validContent, UserID: 15, Status: models.DRAFT}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be updated due to invalid data","errors":{"post_id":"","title":"","content":"","publish_at":"You do not have permission to schedule posts","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertNotCalled(t, "UpdatePost", mock.Anything, mock.Anything)
+}
+
+func TestAPublishedPostCannotBeScheduled(t *testing.T) {
+	publishAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	data := []byte(`{"publish_at" : "` + publishAt.Format(time.RFC3339) + `"}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is key", Content: validContent, UserID: 15, Status: models.PUBLISHED}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.EDITOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be updated due to invalid data","errors":{"post_id":"","title":"","content":"","publish_at":"A published post cannot be scheduled","category":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertNotCalled(t, "UpdatePost", mock.Anything, mock.Anything)
+}
+
+func TestAContributorCannotEditTheirPublishedPost(t *testing.T) {
+
+	for _, status := range []int{models.PUBLISHED, models.SCHEDULED} {
+
+		data := []byte(`{"content" : "` + validContent + validContent + `"}`)
+
+		req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		db := new(mocks.DataStore)
+
+		db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is key", Content: validContent, UserID: 15, Status: status}, nil)
+
+		h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+		req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+		rr := httptest.NewRecorder()
+
+		r := chi.NewRouter()
+
+		r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+		r.ServeHTTP(rr, req)
+
+		if code := rr.Code; code != http.StatusUnauthorized {
+			t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, code)
+		}
+
+		expected := string(`{"status":false,"message":"You do not have permission to edit a ` + models.StatusName(status) + ` post","errors":{"post_id":"","title":"","content":"","publish_at":"","category":""}}`)
+
+		assert.JSONEq(t, expected, rr.Body.String())
+
+		db.AssertNotCalled(t, "UpdatePost", mock.Anything, mock.Anything)
+	}
+}
+
+func TestControlCharactersAreStrippedFromPosts(t *testing.T) {
+
+	p := models.Post{Title: "Go is \x02awesome\x03", Content: "Go is\tawesome\x00\n\n" + models.HIGHLIGHT_START + validContent}
+
+	if err := renderContent(&Handler{}, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Go is awesome", p.Title)
+	assert.Equal(t, "Go is\tawesome\n\n"+validContent, p.Content)
+	assert.NotContains(t, p.ContentHTML, models.HIGHLIGHT_START)
+}
@@ -0,0 +1,479 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/gotils/hasher"
+	"github.com/adelowo/reblog/mailer"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	//The longest a user's about can be
+	MAX_ABOUT_LENGTH = 1000
+
+	//The most social links a user can have
+	MAX_LINKS = 10
+)
+
+//Social links are named after the site they point to, e.g twitter or github
+var linkName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+//profile is what users get to see of their own account
+type profile struct {
+	ID        int               `json:"id"`
+	Moniker   string            `json:"moniker"`
+	Name      string            `json:"name"`
+	About     string            `json:"about"`
+	AvatarURL string            `json:"avatar_url"`
+	Links     map[string]string `json:"links"`
+	Email     string            `json:"email"`
+	Role      string            `json:"role"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func newProfile(u models.User) profile {
+	return profile{u.ID, u.Moniker, u.Name, u.About, u.AvatarURL, newLinks(u.Links), u.Email, u.Role, u.CreatedAt}
+}
+
+//authorProfile is what readers get to see of an author
+type authorProfile struct {
+	Moniker   string            `json:"moniker"`
+	Name      string            `json:"name"`
+	About     string            `json:"about"`
+	AvatarURL string            `json:"avatar_url"`
+	Links     map[string]string `json:"links"`
+}
+
+//newLinks makes sure links are encoded as an object, even when there are none
+func newLinks(l models.Links) map[string]string {
+	if l == nil {
+		return map[string]string{}
+	}
+
+	return l
+}
+
+//isWebURL tells if s is an absolute http or https url
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+//currentUser loads the user making the request.
+//If there is none, a response has been written already and false is returned.
+func currentUser(h *Handler, w http.ResponseWriter, r *http.Request) (models.User, bool) {
+
+	userID, err := getUser(r)
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return models.User{}, false
+	}
+
+	user, err := h.DB.FindByID(r.Context(), userID)
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+//ShowMe fetches the profile of the user making the request
+func ShowMe(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool    `json:"status"`
+		Message string  `json:"message"`
+		Data    profile `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := currentUser(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Your profile was fetched", newProfile(user)})
+	}
+}
+
+//UpdateMe changes the name, about, avatar_url and links of the user making the request.
+//Fields that aren't given are left alone, links are replaced as a whole.
+func UpdateMe(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Name      *string            `json:"name"`
+		About     *string            `json:"about"`
+		AvatarURL *string            `json:"avatar_url"`
+		Links     *map[string]string `json:"links"`
+	}
+
+	type errorMessages struct {
+		Name      string `json:"name"`
+		About     string `json:"about"`
+		AvatarURL string `json:"avatar_url"`
+		Links     string `json:"links"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Data    *profile      `json:"data"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data d
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your profile could not be updated", nil, errorMessages{}})
+			return
+		}
+
+		user, ok := currentUser(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		var errs errorMessages
+
+		if data.Name != nil {
+			user.Name = strings.TrimSpace(*data.Name)
+
+			if user.Name == "" || len(user.Name) > 255 {
+				errs.Name = "Please provide your name, in less than 255 characters"
+			}
+		}
+
+		if data.About != nil {
+			user.About = strings.TrimSpace(*data.About)
+
+			if len(user.About) > MAX_ABOUT_LENGTH {
+				errs.About = "Your about should have less than 1000 characters"
+			}
+		}
+
+		if data.AvatarURL != nil {
+			user.AvatarURL = strings.TrimSpace(*data.AvatarURL)
+
+			if user.AvatarURL != "" && (!isWebURL(user.AvatarURL) || len(user.AvatarURL) > 255) {
+				errs.AvatarURL = "The avatar should be a http or https url"
+			}
+		}
+
+		if data.Links != nil {
+			user.Links = make(models.Links, len(*data.Links))
+
+			for name, link := range *data.Links {
+				if !linkName.MatchString(name) || !isWebURL(link) {
+					errs.Links = "Links should be http or https urls named with lowercase letters, digits, - or _"
+				}
+
+				user.Links[name] = link
+			}
+
+			if len(user.Links) > MAX_LINKS {
+				errs.Links = "You can have up to 10 links"
+			}
+		}
+
+		if errs != (errorMessages{}) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your profile could not be updated due to invalid data", nil, errs})
+			return
+		}
+
+		if err := h.DB.UpdateProfile(r.Context(), user); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while updating your profile", nil, errorMessages{}})
+			return
+		}
+
+		p := newProfile(user)
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Your profile was updated", &p, errorMessages{}})
+	}
+}
+
+//ChangePassword sets a new password for the user making the request, who has to give their current one.
+//Every refresh token of the user is revoked, so they have to log in again everywhere.
+func ChangePassword(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+	}
+
+	type errorMessages struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data d
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || len(data.Password) < 10 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your password could not be changed", errorMessages{Password: "Your password should have a length greater than 10"}})
+			return
+		}
+
+		user, ok := currentUser(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !hasher.NewBcryptHasher(12).Verify(user.Password, data.CurrentPassword) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your password could not be changed", errorMessages{CurrentPassword: "Your current password is incorrect"}})
+			return
+		}
+
+		ctx := r.Context()
+
+		err := h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.UpdatePassword(ctx, user.ID, data.Password); err != nil {
+				return err
+			}
+
+			return tx.RevokeUserRefreshTokens(ctx, user.ID)
+		})
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried changing your password", errorMessages{}})
+			return
+		}
+
+		render.JSON(w, r, &res{true, "Your password has been changed. Please log in again", errorMessages{}})
+	}
+}
+
+//ChangeEmail emails a link to the new address of the user making the request, who has to give their password.
+//The address is only changed once the link is followed, see VerifyEmail.
+func ChangeEmail(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	type errorMessages struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data d
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || !utils.IsEmail(data.Email) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your email could not be changed", errorMessages{Email: "Please provide a valid email address"}})
+			return
+		}
+
+		user, ok := currentUser(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !hasher.NewBcryptHasher(12).Verify(user.Password, data.Password) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your email could not be changed", errorMessages{Password: "Your password is incorrect"}})
+			return
+		}
+
+		if _, err := h.DB.FindByEmail(r.Context(), data.Email); err == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Your email could not be changed", errorMessages{Email: "Email already identifies a user"}})
+			return
+		}
+
+		if err := sendEmailChange(h, r, user, data.Email); err != nil {
+			log.Printf("Could not send an email change link to user %d: %v", user.ID, err)
+
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried changing your email", errorMessages{}})
+			return
+		}
+
+		render.JSON(w, r, &res{true, "A link to confirm your new email address has been sent to it", errorMessages{}})
+	}
+}
+
+func sendEmailChange(h *Handler, r *http.Request, user models.User, email string) error {
+
+	token, err := h.DB.CreateEmailChange(r.Context(), user.ID, email)
+
+	if err != nil {
+		return err
+	}
+
+	m, err := mailer.Render(email, "Confirm your new email address", "email_change", struct {
+		Name      string
+		Site      string
+		Link      string
+		ExpiresIn time.Duration
+	}{user.Name, h.Site.Title, h.Site.baseURL() + "/email/verify/" + token, models.EMAIL_CHANGE_TTL})
+
+	if err != nil {
+		return err
+	}
+
+	return h.Mailer.Send(r.Context(), m)
+}
+
+//VerifyEmail changes the email address of the user an email change token was sent to
+func VerifyEmail(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := r.Context()
+
+		change, err := h.DB.FindEmailChange(ctx, chi.URLParam(r, "token"))
+
+		if err != nil || change.UsedAt != nil || change.Expired(time.Now()) {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "This link is invalid or has expired. Please ask for a new one"})
+			return
+		}
+
+		//Someone else may have started using the address since the link was sent
+		if u, err := h.DB.FindByEmail(ctx, change.Email); err == nil && u.ID != change.UserID {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "This email address is already used by someone else"})
+			return
+		}
+
+		err = h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.UseEmailChange(ctx, change); err != nil {
+				return err
+			}
+
+			return tx.UpdateEmail(ctx, change.UserID, change.Email)
+		})
+
+		//Someone else used the same token in the meantime
+		if err == models.ErrEmailChangeUsed {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "This link is invalid or has expired. Please ask for a new one"})
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried changing your email"})
+			return
+		}
+
+		render.JSON(w, r, &res{true, "Your email address has been changed"})
+	}
+}
+
+//ShowAuthor fetches the profile of the author with the moniker in the url, along with their published posts.
+//It accepts the same query parameters as ListPosts, author aside.
+func ShowAuthor(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type data struct {
+		Author     *authorProfile `json:"author"`
+		Posts      []publicPost   `json:"posts"`
+		Pagination pagination     `json:"pagination"`
+	}
+
+	type res struct {
+		Status  bool              `json:"status"`
+		Message string            `json:"message"`
+		Data    data              `json:"data"`
+		Errors  map[string]string `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, err := h.DB.FindByMoniker(r.Context(), chi.URLParam(r, "moniker"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Author does not exist", data{Posts: []publicPost{}}, map[string]string{}})
+			return
+		}
+
+		filter, errs := postFilterFromRequest(r)
+
+		if len(errs) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid query parameters", data{Posts: []publicPost{}}, errs})
+			return
+		}
+
+		filter.Author = user.Moniker
+
+		posts, err := h.DB.FindPublishedPosts(r.Context(), filter)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching posts", data{Posts: []publicPost{}}, map[string]string{}})
+			return
+		}
+
+		total, err := h.DB.CountPublishedPosts(r.Context(), filter)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching posts", data{Posts: []publicPost{}}, map[string]string{}})
+			return
+		}
+
+		p := make([]publicPost, 0, len(posts))
+
+		for _, post := range posts {
+			p = append(p, newPublicPost(post))
+		}
+
+		author := authorProfile{user.Moniker, user.Name, user.About, user.AvatarURL, newLinks(user.Links)}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Author was fetched", data{&author, p, newPagination(filter, total)}, map[string]string{}})
+	}
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func profileRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Post("/login", PostLogin(h))
+	r.Post("/token/refresh", RefreshToken(h))
+	r.Post("/email/verify/:token", VerifyEmail(h))
+	r.Get("/authors/:moniker", ShowAuthor(h))
+
+	r.Route("/reblog/me", func(ro chi.Router) {
+		ro.Use(h.JWT.Verifier)
+
+		ro.Get("/", ShowMe(h))
+		ro.Patch("/", UpdateMe(h))
+		ro.Post("/password", ChangePassword(h))
+		ro.Post("/email", ChangeEmail(h))
+	})
+
+	return r
+}
+
+func sendJSON(t *testing.T, handler http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "BEARER "+token)
+	}
+
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	return rr
+}
+
+//newProfileStore returns a store with a single user, adelowo, whose password is badpassword
+func newProfileStore(t *testing.T) (*memory.Store, models.User) {
+
+	db := memory.New()
+
+	if err := db.CreateUser(context.Background(), &models.User{Moniker: "adelowo", Name: "Lanre Adelowo", Email: "adelowo@me.com", Password: "badpassword"}); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.FindByMoniker(context.Background(), "adelowo")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, user
+}
+
+func TestPasswordsAreNeverEncoded(t *testing.T) {
+
+	db, user := newProfileStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	rr := sendJSON(t, profileRouter(h), "GET", "/reblog/me", "", testToken(t, h, user.ID, models.CONTRIBUTOR))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var res struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "adelowo", res.Data["moniker"])
+	assert.Equal(t, "adelowo@me.com", res.Data["email"])
+	assert.Equal(t, "contributor", res.Data["role"])
+	assert.Equal(t, map[string]interface{}{}, res.Data["links"])
+	assert.NotContains(t, res.Data, "password")
+	assert.NotContains(t, rr.Body.String(), user.Password)
+
+	b, err := json.Marshal(user)
+
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), user.Password)
+}
+
+func TestTheProfileCanBeUpdated(t *testing.T) {
+
+	db, user := newProfileStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	rr := sendJSON(t, profileRouter(h), "PATCH", "/reblog/me",
+		`{"name" : " Lanre ", "avatar_url" : "https://reblog.io/lanre.png", "links" : {"github" : "https://github.com/adelowo"}}`,
+		testToken(t, h, user.ID, models.CONTRIBUTOR))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	updated, _ := db.FindByID(context.Background(), user.ID)
+
+	assert.Equal(t, "Lanre", updated.Name)
+	assert.Equal(t, user.About, updated.About)
+	assert.Equal(t, "https://reblog.io/lanre.png", updated.AvatarURL)
+	assert.Equal(t, models.Links{"github": "https://github.com/adelowo"}, updated.Links)
+}
+
+func TestInvalidProfilesAreRefused(t *testing.T) {
+
+	db, user := newProfileStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	token := testToken(t, h, user.ID, models.CONTRIBUTOR)
+
+	tests := []struct {
+		body     string
+		expected string
+	}{
+		{`{"name" : "  "}`, `{"name":"Please provide your name, in less than 255 characters","about":"","avatar_url":"","links":""}`},
+		{`{"about" : "` + strings.Repeat("a", MAX_ABOUT_LENGTH+1) + `"}`, `{"name":"","about":"Your about should have less than 1000 characters","avatar_url":"","links":""}`},
+		{`{"avatar_url" : "javascript:alert(1)"}`, `{"name":"","about":"","avatar_url":"The avatar should be a http or https url","links":""}`},
+		{`{"links" : {"Twitter!" : "https://twitter.com/adelowo"}}`, `{"name":"","about":"","avatar_url":"","links":"Links should be http or https urls named with lowercase letters, digits, - or _"}`},
+		{`{"links" : {"twitter" : "twitter.com/adelowo"}}`, `{"name":"","about":"","avatar_url":"","links":"Links should be http or https urls named with lowercase letters, digits, - or _"}`},
+	}
+
+	for _, tt := range tests {
+		rr := sendJSON(t, profileRouter(h), "PATCH", "/reblog/me", tt.body, token)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.JSONEq(t, `{"status":false,"message":"Your profile could not be updated due to invalid data","data":null,"errors":`+tt.expected+`}`, rr.Body.String())
+	}
+
+	unchanged, _ := db.FindByID(context.Background(), user.ID)
+
+	assert.Equal(t, user, unchanged)
+}
+
+func TestThePasswordCanBeChangedWithTheCurrentOne(t *testing.T) {
+
+	db, _ := newProfileStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	r := profileRouter(h)
+
+	before := decodeTokens(t, postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "badpassword"}`, ""))
+
+	rr := postJSON(t, r, "/reblog/me/password", `{"current_password" : "wrongpassword", "password" : "anewandbetterpassword"}`, before.Token)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Your password could not be changed","errors":{"current_password":"Your current password is incorrect","password":""}}`, rr.Body.String())
+
+	rr = postJSON(t, r, "/reblog/me/password", `{"current_password" : "badpassword", "password" : "short"}`, before.Token)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = postJSON(t, r, "/reblog/me/password", `{"current_password" : "badpassword", "password" : "anewandbetterpassword"}`, before.Token)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":true,"message":"Your password has been changed. Please log in again","errors":{"current_password":"","password":""}}`, rr.Body.String())
+
+	//Sessions started with the old password are over
+	rr = postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+before.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "anewandbetterpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestTheEmailIsOnlyChangedOnceTheNewAddressIsVerified(t *testing.T) {
+
+	db, user := newProfileStore(t)
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := profileRouter(h)
+
+	token := testToken(t, h, user.ID, models.CONTRIBUTOR)
+
+	rr := postJSON(t, r, "/reblog/me/email", `{"email" : "lanre@reblog.io", "password" : "wrongpassword"}`, token)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Empty(t, m.messages())
+
+	rr = postJSON(t, r, "/reblog/me/email", `{"email" : "lanre@reblog.io", "password" : "badpassword"}`, token)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	sent := m.messages()
+
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 email. Got %d", len(sent))
+	}
+
+	assert.Equal(t, "lanre@reblog.io", sent[0].To)
+
+	//Nothing changes until the link is followed
+	_, err := db.FindByEmail(context.Background(), "adelowo@me.com")
+
+	assert.NoError(t, err)
+
+	prefix := "https://blog.example.com/email/verify/"
+
+	i := strings.Index(sent[0].Text, prefix)
+
+	if i == -1 {
+		t.Fatalf("The email does not contain a verification link: %s", sent[0].Text)
+	}
+
+	path := strings.TrimPrefix(strings.Fields(sent[0].Text[i:])[0], "https://blog.example.com")
+
+	rr = postJSON(t, r, path, "", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":true,"message":"Your email address has been changed"}`, rr.Body.String())
+
+	updated, _ := db.FindByID(context.Background(), user.ID)
+
+	assert.Equal(t, "lanre@reblog.io", updated.Email)
+
+	//The link can only be used once
+	rr = postJSON(t, r, path, "", "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAnEmailUsedBySomeoneElseCannotBeTaken(t *testing.T) {
+
+	db, user := newProfileStore(t)
+
+	db.CreateUser(context.Background(), &models.User{Moniker: "hades", Name: "Hades", Email: "hades@reblog.io", Password: "badpassword"})
+
+	m := &fakeMailer{}
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Mailer: m, Site: testSite}
+
+	r := profileRouter(h)
+
+	rr := postJSON(t, r, "/reblog/me/email", `{"email" : "hades@reblog.io", "password" : "badpassword"}`, testToken(t, h, user.ID, models.CONTRIBUTOR))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Your email could not be changed","errors":{"email":"Email already identifies a user","password":""}}`, rr.Body.String())
+	assert.Empty(t, m.messages())
+
+	//Nor with a link sent before someone else started using the address
+	token, _ := db.CreateEmailChange(context.Background(), user.ID, "zeus@reblog.io")
+
+	db.CreateUser(context.Background(), &models.User{Moniker: "zeus", Name: "Zeus", Email: "zeus@reblog.io", Password: "badpassword"})
+
+	rr = postJSON(t, r, "/email/verify/"+token, "", "")
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	unchanged, _ := db.FindByID(context.Background(), user.ID)
+
+	assert.Equal(t, "adelowo@me.com", unchanged.Email)
+}
+
+func TestAnAuthorsProfileListsTheirPublishedPosts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	author := models.User{ID: 3, Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher", AvatarURL: "https://reblog.io/hades.png",
+		Links: models.Links{"github": "https://github.com/adelowo"}, Email: "hades@reblog.io", Password: "hash"}
+
+	posts := []models.Post{
+		{ID: 2, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test all the things", Status: PUBLISHED,
+			CreatedAt: createdAt, UpdatedAt: createdAt, User: models.User{Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"}},
+	}
+
+	filter := models.PostFilter{Page: 2, Author: "hades"}
+
+	db.On("FindByMoniker", mock.Anything, "hades").Once().Return(author, nil)
+	db.On("FindPublishedPosts", mock.Anything, filter).Once().Return(posts, nil)
+	db.On("CountPublishedPosts", mock.Anything, filter).Once().Return(21, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	rr := sendJSON(t, profileRouter(h), "GET", "/authors/hades?page=2", "", "")
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	expected := `{"status":true,"message":"Author was fetched","errors":{},"data":{
+		"author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher","avatar_url":"https://reblog.io/hades.png","links":{"github":"https://github.com/adelowo"}},
+		"posts":[{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"","excerpt":"","reading_time":0,
+			"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"}}],
+		"pagination":{"page":2,"per_page":20,"total":21,"total_pages":2}}}`
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAnUnknownAuthorIsNotFound(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindByMoniker", mock.Anything, "nobody").Once().Return(models.User{}, errors.New("Could not find a user with the specified username"))
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	rr := sendJSON(t, profileRouter(h), "GET", "/authors/nobody", "", "")
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	db.AssertNotCalled(t, "FindPublishedPosts", mock.Anything, mock.Anything)
+}
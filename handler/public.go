@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/models"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const maxPerPage = 100
+
+type publicAuthor struct {
+	Moniker string `json:"moniker"`
+	Name    string `json:"name"`
+	About   string `json:"about"`
+}
+
+//publicPost is what readers get to see of a post.
+type publicPost struct {
+	ID          int             `json:"id"`
+	Title       string          `json:"title"`
+	Slug        string          `json:"slug"`
+	Content     string          `json:"content"`
+	ContentHTML string          `json:"content_html"`
+	Excerpt     string          `json:"excerpt"`
+	ReadingTime int             `json:"reading_time"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Author      publicAuthor    `json:"author"`
+	Category    *publicCategory `json:"category,omitempty"`
+	Tags        []publicTag     `json:"tags,omitempty"`
+}
+
+func newPublicPost(p models.Post) publicPost {
+	post := publicPost{
+		ID:          p.ID,
+		Title:       p.Title,
+		Slug:        p.Slug,
+		Content:     p.Content,
+		ContentHTML: p.ContentHTML,
+		Excerpt:     p.Excerpt,
+		ReadingTime: p.ReadingTime,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		Author:      publicAuthor{p.User.Moniker, p.User.Name, p.User.About},
+	}
+
+	if p.Category.ID != 0 {
+		post.Category = &publicCategory{Name: p.Category.Name, Slug: p.Category.Slug}
+	}
+
+	for _, t := range p.Tags {
+		post.Tags = append(post.Tags, publicTag{t.Name, t.Slug})
+	}
+
+	return post
+}
+
+type pagination struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+func newPagination(f models.PostFilter, total int) pagination {
+	page := f.Page
+
+	if page < 1 {
+		page = 1
+	}
+
+	return pagination{page, f.Limit(), total, (total + f.Limit() - 1) / f.Limit()}
+}
+
+//Lists published posts for readers.
+//Supported query parameters are page, per_page, sort (created_at or updated_at), order (asc or desc),
+//author (a moniker), from and to (either 2006-01-02 or RFC3339 dates).
+func ListPosts(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return listPosts(h, false)
+}
+
+//Lists the published posts with the tag in the url.
+//It accepts the same query parameters as ListPosts.
+func ListTagPosts(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return listPosts(h, true)
+}
+
+func listPosts(h *Handler, byTag bool) func(w http.ResponseWriter, r *http.Request) {
+
+	type errorMessages struct {
+		Page    string `json:"page"`
+		PerPage string `json:"per_page"`
+		Sort    string `json:"sort"`
+		Order   string `json:"order"`
+		From    string `json:"from"`
+		To      string `json:"to"`
+	}
+
+	type data struct {
+		Posts      []publicPost `json:"posts"`
+		Pagination pagination   `json:"pagination"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Data    data          `json:"data"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		filter, errs := postFilterFromRequest(r)
+
+		if len(errs) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid query parameters", data{Posts: []publicPost{}},
+				errorMessages{errs["page"], errs["per_page"], errs["sort"], errs["order"], errs["from"], errs["to"]}})
+			return
+		}
+
+		if byTag {
+			filter.Tag = chi.URLParam(r, "slug")
+
+			if _, err := h.DB.FindTagBySlug(r.Context(), filter.Tag); err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				render.JSON(w, r, &res{false, "Tag does not exist", data{Posts: []publicPost{}}, errorMessages{}})
+				return
+			}
+		}
+
+		posts, err := h.DB.FindPublishedPosts(r.Context(), filter)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching posts", data{Posts: []publicPost{}}, errorMessages{}})
+			return
+		}
+
+		total, err := h.DB.CountPublishedPosts(r.Context(), filter)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching posts", data{Posts: []publicPost{}}, errorMessages{}})
+			return
+		}
+
+		p := make([]publicPost, 0, len(posts))
+
+		for _, post := range posts {
+			p = append(p, newPublicPost(post))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Posts were fetched", data{p, newPagination(filter, total)}, errorMessages{}})
+	}
+}
+
+//Fetches a single published post by its slug
+func ShowPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool        `json:"status"`
+		Message string      `json:"message"`
+		Data    *publicPost `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		p, err := h.DB.FindPublishedPostBySlug(r.Context(), chi.URLParam(r, "slug"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Post does not exist", nil})
+			return
+		}
+
+		post := newPublicPost(p)
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Post was fetched", &post})
+	}
+}
+
+//Builds the listing filter out of the query string.
+//Validation errors are keyed by the offending query parameter.
+func postFilterFromRequest(r *http.Request) (models.PostFilter, map[string]string) {
+	var f models.PostFilter
+	var err error
+
+	errs := make(map[string]string)
+	query := r.URL.Query()
+
+	if page := query.Get("page"); page != "" {
+		if f.Page, err = strconv.Atoi(page); err != nil || f.Page < 1 {
+			errs["page"] = "Page should be a number greater than zero"
+		}
+	}
+
+	if perPage := query.Get("per_page"); perPage != "" {
+		if f.PerPage, err = strconv.Atoi(perPage); err != nil || f.PerPage < 1 || f.PerPage > maxPerPage {
+			errs["per_page"] = "per_page should be a number between 1 and " + strconv.Itoa(maxPerPage)
+		}
+	}
+
+	switch sort := query.Get("sort"); sort {
+	case "", models.SORT_CREATED_AT, models.SORT_UPDATED_AT:
+		f.SortBy = sort
+	default:
+		errs["sort"] = "Posts can only be sorted by created_at or updated_at"
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		f.Ascending = true
+	default:
+		errs["order"] = "Order should either be asc or desc"
+	}
+
+	f.Author = query.Get("author")
+
+	if from := query.Get("from"); from != "" {
+		if f.From, _, err = parseDate(from); err != nil {
+			errs["from"] = "Please provide a valid date. E.g 2017-01-31"
+		}
+	}
+
+	if to := query.Get("to"); to != "" {
+		var dateOnly bool
+
+		if f.To, dateOnly, err = parseDate(to); err != nil {
+			errs["to"] = "Please provide a valid date. E.g 2017-01-31"
+		} else if dateOnly {
+			//Include everything written on that day
+			f.To = f.To.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	return f, errs
+}
+
+//parseDate accepts both a plain date and a full RFC3339 timestamp.
+//It reports whether only a date was given.
+func parseDate(val string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", val); err == nil {
+		return t, true, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, val)
+
+	return t, false, err
+}
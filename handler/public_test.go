@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"context"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPublishedPostsCanBeListed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	posts := []models.Post{
+		{ID: 2, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test all the things", ContentHTML: "<p>Test all the things</p>\n",
+			Excerpt: "Test all the things", ReadingTime: 1, Status: PUBLISHED,
+			CreatedAt: createdAt, UpdatedAt: createdAt, User: models.User{Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"}},
+	}
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(posts, nil)
+	db.On("CountPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(1, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListPosts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Posts were fetched","data":{"posts":[{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"<p>Test all the things</p>\n","excerpt":"Test all the things","reading_time":1,"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"}}],"pagination":{"page":1,"per_page":20,"total":1,"total_pages":1}},"errors":{"page":"","per_page":"","sort":"","order":"","from":"","to":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestTheStoreIsQueriedWithTheRequestContext(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	//The request was cancelled, so the store gives up
+	cancelled := mock.MatchedBy(func(c context.Context) bool {
+		return c.Err() == context.Canceled
+	})
+
+	db.On("FindPublishedPosts", cancelled, models.PostFilter{}).Once().Return(nil, context.Canceled)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListPosts(h)).
+		ServeHTTP(rr, req.WithContext(ctx))
+
+	db.AssertExpectations(t)
+}
+
+func TestPublishedPostsCanBeFilteredAndSorted(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	filter := models.PostFilter{
+		Page:      3,
+		PerPage:   5,
+		SortBy:    models.SORT_UPDATED_AT,
+		Ascending: true,
+		Author:    "hades",
+		From:      time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2017, time.January, 31, 23, 59, 59, 999999999, time.UTC),
+	}
+
+	db.On("FindPublishedPosts", mock.Anything, filter).Once().Return([]models.Post{}, nil)
+	db.On("CountPublishedPosts", mock.Anything, filter).Once().Return(11, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts?page=3&per_page=5&sort=updated_at&order=asc&author=hades&from=2017-01-01&to=2017-01-31", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListPosts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Posts were fetched","data":{"posts":[],"pagination":{"page":3,"per_page":5,"total":11,"total_pages":3}},"errors":{"page":"","per_page":"","sort":"","order":"","from":"","to":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestPostsCannotBeListedWithAnInvalidQuery(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts?page=zero&per_page=1000&sort=title&order=up&from=yesterday&to=2017-13-01", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListPosts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Invalid query parameters","data":{"posts":[],"pagination":{"page":0,"per_page":0,"total":0,"total_pages":0}},"errors":{"page":"Page should be a number greater than zero","per_page":"per_page should be a number between 1 and 100","sort":"Posts can only be sorted by created_at or updated_at","order":"Order should either be asc or desc","from":"Please provide a valid date. E.g 2017-01-31","to":"Please provide a valid date. E.g 2017-01-31"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAnErrorOccurredWhileListingPosts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(nil, errors.New("Could not fetch published posts"))
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListPosts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Fatalf("Expected %d. Got %d", http.StatusInternalServerError, status)
+	}
+}
+
+func TestAPublishedPostCanBeViewed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	p := models.Post{ID: 2, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test all the things", ContentHTML: "<p>Test all the things</p>\n",
+		Excerpt: "Test all the things", ReadingTime: 1, Status: PUBLISHED,
+		CreatedAt: createdAt, UpdatedAt: createdAt, User: models.User{Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"}}
+
+	db.On("FindPublishedPostBySlug", mock.Anything, "Testing-is-key").Once().Return(p, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts/Testing-is-key", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Get("/posts/:slug", ShowPost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Post was fetched","data":{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"<p>Test all the things</p>\n","excerpt":"Test all the things","reading_time":1,"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"}}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAnUnpublishedPostCannotBeViewed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPublishedPostBySlug", mock.Anything, "Draft-post").Once().Return(models.Post{}, errors.New("Post does not exists"))
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts/Draft-post", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Get("/posts/:slug", ShowPost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotFound, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post does not exist","data":null}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
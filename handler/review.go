@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/reblog/models"
+	"github.com/pressly/chi/render"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type reviewPost struct {
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Slug       string    `json:"slug"`
+	Status     string    `json:"status"`
+	ReviewNote string    `json:"review_note"`
+	Author     string    `json:"author"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+//Lets the author of a draft ask for it to be reviewed by an admin.
+//Posts sent back with changes requested are resubmitted the same way.
+func SubmitPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		transitionPost(h, w, r, p, models.IN_REVIEW, "", "Post was submitted for review")
+	}
+}
+
+//Publishes a post that was submitted for review.
+//If the author asked for a publishing date that is yet to come, the post is scheduled instead.
+func ApprovePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		//A draft may be published straight away, but not approved without being reviewed
+		if p.Status != models.IN_REVIEW {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "A " + models.StatusName(p.Status) + " post cannot be approved"})
+			return
+		}
+
+		if p.PublishAt != nil && p.PublishAt.After(time.Now()) {
+			transitionPost(h, w, r, p, models.SCHEDULED, "", "Post was approved and scheduled for publishing")
+			return
+		}
+
+		transitionPost(h, w, r, p, models.PUBLISHED, "", "Post was approved and published")
+	}
+}
+
+//Sends a post back to its author. The reviewer has to explain what needs to be changed.
+func RejectPost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Note string `json:"note"`
+	}
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+		Errors  struct {
+			Note string `json:"note"`
+		} `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data d
+
+		decoder := json.NewDecoder(r.Body)
+
+		if err := decoder.Decode(&data); err != nil || strings.TrimSpace(data.Note) == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Post could not be rejected", struct {
+				Note string `json:"note"`
+			}{"Please tell the author what needs to be changed"}})
+			return
+		}
+
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		transitionPost(h, w, r, p, models.CHANGES_REQUESTED, data.Note, "Changes were requested from the author")
+	}
+}
+
+func ArchivePost(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		transitionPost(h, w, r, p, models.ARCHIVED, "", "Post was archived")
+	}
+}
+
+//Lists every post waiting for an admin to approve or reject it
+func ReviewQueue(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool         `json:"status"`
+		Message string       `json:"message"`
+		Data    []reviewPost `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		posts, err := h.DB.FindPostsAwaitingReview(r.Context())
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching the review queue", []reviewPost{}})
+			return
+		}
+
+		data := make([]reviewPost, 0, len(posts))
+
+		for _, p := range posts {
+			data = append(data, reviewPost{p.ID, p.Title, p.Slug, models.StatusName(p.Status), p.ReviewNote, p.User.Moniker, p.UpdatedAt})
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Posts awaiting review were fetched", data})
+	}
+}
+
+func transitionPost(h *Handler, w http.ResponseWriter, r *http.Request, p models.Post, status int, note, message string) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	err := h.DB.TransitionPost(r.Context(), &p, status, note)
+
+	if err == nil {
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, message})
+		return
+	}
+
+	if err == models.ErrInvalidTransition {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, &res{false, "A " + models.StatusName(p.Status) + " post cannot be moved to " + models.StatusName(status)})
+		return
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	render.JSON(w, r, &res{false, "An error occurred while trying to update the post"})
+}
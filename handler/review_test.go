@@ -0,0 +1,338 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func reviewRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Post("/reblog/posts/:id/submit", SubmitPost(h))
+	r.With(middleware.Require(models.POST_REVIEW)).Get("/reblog/posts/review", ReviewQueue(h))
+	r.With(middleware.Require(models.POST_REVIEW)).Post("/reblog/posts/:id/approve", ApprovePost(h))
+	r.With(middleware.Require(models.POST_REVIEW)).Post("/reblog/posts/:id/reject", RejectPost(h))
+	r.With(middleware.Require(models.POST_REVIEW)).Post("/reblog/posts/:id/archive", ArchivePost(h))
+
+	return r
+}
+
+func TestAnAuthorCanSubmitADraftForReview(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.DRAFT}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("TransitionPost", mock.Anything, &p, models.IN_REVIEW, "").Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/submit", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Post was submitted for review"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAPublishedPostCannotBeSubmittedForReview(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.PUBLISHED}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("TransitionPost", mock.Anything, &p, models.IN_REVIEW, "").Once().Return(models.ErrInvalidTransition)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/submit", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"A published post cannot be moved to in_review"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestACollaboratorCannotApproveAPost(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/approve", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+}
+
+func TestAnAdminCanApproveAPost(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.IN_REVIEW}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("TransitionPost", mock.Anything, &p, models.PUBLISHED, "").Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/approve", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Post was approved and published"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestADraftCannotBeApproved(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.DRAFT}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/approve", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"A draft post cannot be approved"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertNotCalled(t, "TransitionPost", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAPostCannotBeRejectedWithoutANote(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/reject", bytes.NewBuffer([]byte(`{"note" : "  "}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be rejected","errors":{"note":"Please tell the author what needs to be changed"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAnAdminCanRequestChanges(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.IN_REVIEW}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("TransitionPost", mock.Anything, &p, models.CHANGES_REQUESTED, "Needs more examples").Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/reject", bytes.NewBuffer([]byte(`{"note" : "Needs more examples"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Changes were requested from the author"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAnErrorOccurredWhileArchivingAPost(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.PUBLISHED}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("TransitionPost", mock.Anything, &p, models.ARCHIVED, "").Once().Return(errors.New("Could not update post"))
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/archive", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Fatalf("Expected %d. Got %d", http.StatusInternalServerError, status)
+	}
+}
+
+func TestTheReviewQueueCanBeListed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	updatedAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	db.On("FindPostsAwaitingReview", mock.Anything).Once().Return([]models.Post{
+		{ID: 10, Title: "Testing is key", Slug: "Testing-is-key", Status: models.IN_REVIEW, UpdatedAt: updatedAt, User: models.User{Moniker: "horus"}},
+	}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/posts/review", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Posts awaiting review were fetched","data":[{"id":10,"title":"Testing is key","slug":"Testing-is-key","status":"in_review","review_note":"","author":"horus","updated_at":"2017-01-20T10:00:00Z"}]}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestApprovingAPostWithAFuturePublishingDateSchedulesIt(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	publishAt := time.Now().Add(time.Hour)
+
+	p := models.Post{ID: 10, UserID: 15, Status: models.IN_REVIEW, PublishAt: &publishAt}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("TransitionPost", mock.Anything, &p, models.SCHEDULED, "").Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/approve", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	reviewRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Post was approved and scheduled for publishing"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/adelowo/reblog/models"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type revision struct {
+	ID        int       `json:"id"`
+	PostID    int       `json:"post_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newRevision(r models.Revision) revision {
+	return revision{r.ID, r.PostID, r.Title, r.Content, r.Status, r.CreatedAt}
+}
+
+//Lists every revision of a post, the most recent first.
+func ListRevisions(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool       `json:"status"`
+		Message string     `json:"message"`
+		Data    []revision `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		revisions, err := h.DB.FindRevisionsByPost(r.Context(), p.ID)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching the post's revisions", []revision{}})
+			return
+		}
+
+		data := make([]revision, 0, len(revisions))
+
+		for _, rev := range revisions {
+			data = append(data, newRevision(rev))
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Revisions were fetched", data})
+	}
+}
+
+//Returns a unified diff between two revisions of the same post.
+//The revisions are given by the from and to query parameters.
+func DiffRevisions(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type data struct {
+		From int    `json:"from"`
+		To   int    `json:"to"`
+		Diff string `json:"diff"`
+	}
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+		Data    data   `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		fromID, err1 := strconv.Atoi(r.URL.Query().Get("from"))
+		toID, err2 := strconv.Atoi(r.URL.Query().Get("to"))
+
+		if err1 != nil || err2 != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Please provide the from and to revisions", data{}})
+			return
+		}
+
+		from, err1 := h.DB.FindRevisionByID(r.Context(), fromID)
+		to, err2 := h.DB.FindRevisionByID(r.Context(), toID)
+
+		if err1 != nil || err2 != nil || from.PostID != p.ID || to.PostID != p.ID {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Revision does not exist", data{}})
+			return
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(revisionText(from)),
+			B:        difflib.SplitLines(revisionText(to)),
+			FromFile: fmt.Sprintf("revision-%d", from.ID),
+			ToFile:   fmt.Sprintf("revision-%d", to.ID),
+			Context:  3,
+		})
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while comparing the revisions", data{}})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Revisions were compared", data{from.ID, to.ID, diff}})
+	}
+}
+
+//Makes an older revision the post's current title and content.
+//Since this is a write like any other, it creates a new revision instead of discarding the newer ones.
+func RestoreRevision(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		p, ok := findEditablePost(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		if !canEditLivePost(r, p) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "You do not have permission to edit a " + models.StatusName(p.Status) + " post"})
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "revision"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid revision id"})
+			return
+		}
+
+		rev, err := h.DB.FindRevisionByID(r.Context(), id)
+
+		if err != nil || rev.PostID != p.ID {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Revision does not exist"})
+			return
+		}
+
+		if rev.Title != p.Title {
+			if existing, err := h.DB.FindPostByTitle(r.Context(), rev.Title); err == nil && existing.ID != p.ID {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, &res{false, "Could not restore revision as another post now has the title, " + rev.Title})
+				return
+			}
+
+			p.Title = rev.Title
+			p.Slug = h.Slug.Generate(rev.Title)
+		}
+
+		p.Content = rev.Content
+
+		if err = renderContent(h, &p); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while restoring the revision"})
+			return
+		}
+
+		err = h.DB.UpdatePost(r.Context(), &p)
+
+		if err == nil {
+			w.WriteHeader(http.StatusOK)
+			render.JSON(w, r, &res{true, "Revision was restored"})
+			return
+		}
+
+		if err == models.ErrPostExists {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Could not restore revision as another post now has the title, " + rev.Title})
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, &res{false, "An error occurred while restoring the revision"})
+	}
+}
+
+//The text a diff is computed over
+func revisionText(r models.Revision) string {
+	return r.Title + "\n\n" + r.Content
+}
+
+//Loads the post identified by the id url parameter and makes sure the current user may edit it.
+//If not, a response has been written already and false is returned.
+func findEditablePost(h *Handler, w http.ResponseWriter, r *http.Request) (models.Post, bool) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, &res{false, "Invalid post id"})
+		return models.Post{}, false
+	}
+
+	if _, err := getUser(r); err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return models.Post{}, false
+	}
+
+	p, err := h.DB.FindPostByID(r.Context(), id)
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, &res{false, "Post does not exist"})
+		return models.Post{}, false
+	}
+
+	if !canEditPost(r, p) {
+		w.WriteHeader(http.StatusUnauthorized)
+		render.JSON(w, r, &res{false, "You do not have permission to edit this post"})
+		return models.Post{}, false
+	}
+
+	return p, true
+}
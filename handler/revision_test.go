@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func revisionRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/reblog/posts/:id/revisions", ListRevisions(h))
+	r.Get("/reblog/posts/:id/revisions/diff", DiffRevisions(h))
+	r.Post("/reblog/posts/:id/revisions/:revision/restore", RestoreRevision(h))
+
+	return r
+}
+
+func TestRevisionsOfAPostCanBeListed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, UserID: 15}, nil)
+
+	db.On("FindRevisionsByPost", mock.Anything, 10).Once().Return([]models.Revision{
+		{ID: 2, PostID: 10, Title: "Testing is key", Content: "Test it", Status: PUBLISHED, CreatedAt: createdAt},
+		{ID: 1, PostID: 10, Title: "Testing is key", Content: "Test", Status: UNPUBLISHED, CreatedAt: createdAt},
+	}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/posts/10/revisions", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	revisionRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Revisions were fetched","data":[{"id":2,"post_id":10,"title":"Testing is key","content":"Test it","status":1,"created_at":"2017-01-20T10:00:00Z"},{"id":1,"post_id":10,"title":"Testing is key","content":"Test","status":0,"created_at":"2017-01-20T10:00:00Z"}]}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestACollaboratorCannotSeeRevisionsOfAnotherAuthorsPost(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, UserID: 15}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/posts/10/revisions", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 16, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	revisionRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+
+	expected := string(`{"status":false,"message":"You do not have permission to edit this post"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestTwoRevisionsCanBeCompared(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, UserID: 15}, nil)
+	db.On("FindRevisionByID", mock.Anything, 1).Once().Return(models.Revision{ID: 1, PostID: 10, Title: "Testing is key", Content: "one\ntwo"}, nil)
+	db.On("FindRevisionByID", mock.Anything, 2).Once().Return(models.Revision{ID: 2, PostID: 10, Title: "Testing is key", Content: "one\nthree"}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/posts/10/revisions/diff?from=1&to=2", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	revisionRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Revisions were compared","data":{"from":1,"to":2,"diff":"--- revision-1\n+++ revision-2\n@@ -1,4 +1,4 @@\n Testing is key\n \n one\n-two\n+three\n"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestRevisionsOfAnotherPostCannotBeCompared(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, UserID: 15}, nil)
+	db.On("FindRevisionByID", mock.Anything, 1).Once().Return(models.Revision{ID: 1, PostID: 10}, nil)
+	db.On("FindRevisionByID", mock.Anything, 5).Once().Return(models.Revision{ID: 5, PostID: 11}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/posts/10/revisions/diff?from=1&to=5", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	revisionRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotFound, status)
+	}
+}
+
+func TestARevisionCanBeRestored(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	p := models.Post{ID: 10, Title: "Testing is really key", Slug: "Testing-is-really-key", Content: "Test everything", UserID: 15}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+	db.On("FindRevisionByID", mock.Anything, 1).Once().Return(models.Revision{ID: 1, PostID: 10, Title: "Testing is key", Content: "Test"}, nil)
+	db.On("FindPostByTitle", mock.Anything, "Testing is key").Once().Return(models.Post{}, errors.New("Post does not exists"))
+
+	restored := p
+	restored.Title = "Testing is key"
+	restored.Slug = "Testing-is-key"
+	restored.Content = "Test"
+	restored = withRenderedContent(restored)
+
+	db.On("UpdatePost", mock.Anything, &restored).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/revisions/1/restore", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	revisionRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Revision was restored"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAContributorCannotRestoreARevisionOfTheirPublishedPost(t *testing.T) {
+
+	for _, status := range []int{models.PUBLISHED, models.SCHEDULED} {
+
+		db := new(mocks.DataStore)
+
+		db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, Title: "Testing is really key", UserID: 15, Status: status}, nil)
+
+		h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+		req, err := http.NewRequest("POST", "/reblog/posts/10/revisions/1/restore", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+		rr := httptest.NewRecorder()
+
+		revisionRouter(h).ServeHTTP(rr, req)
+
+		if code := rr.Code; code != http.StatusUnauthorized {
+			t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, code)
+		}
+
+		expected := string(`{"status":false,"message":"You do not have permission to edit a ` + models.StatusName(status) + ` post"}`)
+
+		assert.JSONEq(t, expected, rr.Body.String())
+
+		db.AssertNotCalled(t, "UpdatePost", mock.Anything, mock.Anything)
+	}
+}
+
+func TestARevisionOfAnotherPostCannotBeRestored(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(models.Post{ID: 10, UserID: 15}, nil)
+	db.On("FindRevisionByID", mock.Anything, 5).Once().Return(models.Revision{ID: 5, PostID: 11}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req, err := http.NewRequest("POST", "/reblog/posts/10/revisions/5/restore", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	revisionRouter(h).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotFound, status)
+	}
+
+	expected := string(`{"status":false,"message":"Revision does not exist"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/models"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"net/http"
+)
+
+type publicRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+//Lists every role with its permissions, from the most to the least powerful
+func ListRoles(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool         `json:"status"`
+		Message string       `json:"message"`
+		Data    []publicRole `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		roles, err := h.DB.FindRoles(r.Context())
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching roles", []publicRole{}})
+			return
+		}
+
+		data := make([]publicRole, 0, len(roles))
+
+		for _, v := range roles {
+			data = append(data, publicRole{v.Name, v.Description, v.Permissions})
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Roles were fetched", data})
+	}
+}
+
+//Replaces the permissions of the role identified by the name url parameter.
+//The owner role always has every permission, and nobody can give a permission they don't have.
+//Users holding the role are logged out everywhere: their refresh tokens are revoked and their access tokens refused.
+func UpdateRole(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Permissions []string `json:"permissions"`
+	}
+
+	type errorMessages struct {
+		Permissions string `json:"permissions"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data d
+
+		decoder := json.NewDecoder(r.Body)
+
+		if err := decoder.Decode(&data); err != nil || data.Permissions == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Role could not be updated", errorMessages{Permissions: "Please provide the role's permissions"}})
+			return
+		}
+
+		name := chi.URLParam(r, "name")
+
+		if _, err := h.DB.FindRole(r.Context(), name); err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Role does not exist", errorMessages{}})
+			return
+		}
+
+		for _, p := range data.Permissions {
+			if models.IsPermission(p) && !middleware.Can(r, p) {
+				w.WriteHeader(http.StatusUnauthorized)
+				render.JSON(w, r, &res{false, "You cannot give a role a permission you do not have", errorMessages{Permissions: p}})
+				return
+			}
+		}
+
+		ctx := r.Context()
+
+		err := h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.UpdateRolePermissions(ctx, name, data.Permissions); err != nil {
+				return err
+			}
+
+			return tx.RevokeRoleRefreshTokens(ctx, name)
+		})
+
+		switch errors.Cause(err) {
+		case nil:
+			w.WriteHeader(http.StatusOK)
+			render.JSON(w, r, &res{true, "Role was updated", errorMessages{}})
+		case models.ErrRoleLocked:
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Role could not be updated", errorMessages{Permissions: "The owner can always do everything"}})
+		case models.ErrUnknownPermission:
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Role could not be updated", errorMessages{Permissions: err.Error()}})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while updating the role", errorMessages{}})
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func roleRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(h.JWT.Verifier)
+	r.Use(middleware.Require(models.ROLE_MANAGE))
+
+	r.Get("/reblog/roles", ListRoles(h))
+	r.Put("/reblog/roles/:name", UpdateRole(h))
+
+	return r
+}
+
+func serveRoleRequest(t *testing.T, h *Handler, method, path, body, role string) *httptest.ResponseRecorder {
+
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "BEARER "+testToken(t, h, 1, role))
+
+	rr := httptest.NewRecorder()
+
+	roleRouter(h).ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestRolesAreListedWithTheirPermissions(t *testing.T) {
+
+	h := &Handler{DB: memory.New(), JWT: newTestJWT()}
+
+	rr := serveRoleRequest(t, h, "GET", "/reblog/roles", "", models.OWNER)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	var res struct {
+		Data []publicRole `json:"data"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Data) != len(models.DefaultRoles) {
+		t.Fatalf("Expected %d roles. Got %d", len(models.DefaultRoles), len(res.Data))
+	}
+
+	assert.Equal(t, "owner", res.Data[0].Name)
+	assert.Equal(t, publicRole{"contributor", "Writes posts and submits them for review", []string{"post:create", "post:edit:own"}}, res.Data[4])
+}
+
+func TestOnlyTheOwnerCanManageRoles(t *testing.T) {
+
+	h := &Handler{DB: memory.New(), JWT: newTestJWT()}
+
+	rr := serveRoleRequest(t, h, "GET", "/reblog/roles", "", models.ADMIN)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"You do not have permission to view this resource"}`, rr.Body.String())
+}
+
+func TestTheOwnerCanChangeWhatARoleCanDo(t *testing.T) {
+
+	db := memory.New()
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	rr := serveRoleRequest(t, h, "PUT", "/reblog/roles/contributor", `{"permissions" : ["post:create", "post:edit:own", "post:publish"]}`, models.OWNER)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"status":true,"message":"Role was updated","errors":{"permissions":""}}`, rr.Body.String())
+
+	role, _ := db.FindRole(context.Background(), models.CONTRIBUTOR)
+
+	assert.True(t, role.Can(models.POST_PUBLISH))
+}
+
+func TestUsersHoldingAChangedRoleAreLoggedOut(t *testing.T) {
+
+	db := memory.New()
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	var tokens []string
+	var users []models.User
+
+	for _, role := range []string{models.CONTRIBUTOR, models.EDITOR} {
+		u := models.User{Moniker: role, Name: "The " + role, Email: role + "@reblog.io", Password: "badpassword", Role: role}
+
+		if err := db.CreateUser(context.Background(), &u); err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ = db.FindByMoniker(context.Background(), role)
+
+		token, err := db.CreateRefreshToken(context.Background(), u.ID, "")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tokens = append(tokens, token)
+		users = append(users, u)
+	}
+
+	rr := serveRoleRequest(t, h, "PUT", "/reblog/roles/contributor", `{"permissions" : ["post:create"]}`, models.OWNER)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	contributor, _ := db.FindRefreshToken(context.Background(), tokens[0])
+	editor, _ := db.FindRefreshToken(context.Background(), tokens[1])
+
+	assert.NotNil(t, contributor.RevokedAt)
+	assert.Nil(t, editor.RevokedAt)
+
+	//Their access tokens are refused too
+	outdated, _ := db.ArePermissionsOutdated(context.Background(), strconv.Itoa(users[0].ID), users[0].PermissionsVersion)
+
+	assert.True(t, outdated)
+
+	outdated, _ = db.ArePermissionsOutdated(context.Background(), strconv.Itoa(users[1].ID), users[1].PermissionsVersion)
+
+	assert.False(t, outdated)
+}
+
+func TestRolesCannotBeGivenInvalidPermissions(t *testing.T) {
+
+	db := memory.New()
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	tests := []struct {
+		path, body string
+		status     int
+		expected   string
+	}{
+		{"/reblog/roles/owner", `{"permissions" : []}`, http.StatusBadRequest,
+			`{"status":false,"message":"Role could not be updated","errors":{"permissions":"The owner can always do everything"}}`},
+		{"/reblog/roles/editor", `{"permissions" : ["post:create", "post:burn"]}`, http.StatusBadRequest,
+			`{"status":false,"message":"Role could not be updated","errors":{"permissions":"post:burn: Unknown permission"}}`},
+		{"/reblog/roles/editor", `{}`, http.StatusBadRequest,
+			`{"status":false,"message":"Role could not be updated","errors":{"permissions":"Please provide the role's permissions"}}`},
+		{"/reblog/roles/janitor", `{"permissions" : []}`, http.StatusNotFound,
+			`{"status":false,"message":"Role does not exist","errors":{"permissions":""}}`},
+	}
+
+	for _, tt := range tests {
+		rr := serveRoleRequest(t, h, "PUT", tt.path, tt.body, models.OWNER)
+
+		assert.Equal(t, tt.status, rr.Code, tt.body)
+		assert.JSONEq(t, tt.expected, rr.Body.String())
+	}
+
+	editor, _ := db.FindRole(context.Background(), models.EDITOR)
+
+	assert.True(t, editor.Can(models.POST_REVIEW))
+}
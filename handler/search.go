@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/models"
+	"github.com/pressly/chi/render"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type searchResult struct {
+	publicPost
+	Snippet string `json:"snippet"`
+	//Only set for searches that include unpublished posts
+	Status string `json:"status,omitempty"`
+}
+
+//Searches published posts by words in their title and content.
+//Results are ranked and come with a snippet in which the matched words are wrapped in <mark>.
+func Search(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return search(h, false)
+}
+
+//Searches posts including unpublished ones.
+//Admins search every post, collaborators only their own unpublished posts.
+func SearchDrafts(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+	return search(h, true)
+}
+
+func search(h *Handler, includeUnpublished bool) func(w http.ResponseWriter, r *http.Request) {
+
+	type errorMessages struct {
+		Query string `json:"q"`
+		Page  string `json:"page"`
+	}
+
+	type data struct {
+		Results []searchResult `json:"results"`
+		Page    int            `json:"page"`
+		PerPage int            `json:"per_page"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Data    data          `json:"data"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		q := models.SearchQuery{Terms: strings.TrimSpace(r.URL.Query().Get("q"))}
+
+		page := 1
+
+		var errs errorMessages
+
+		if q.Terms == "" {
+			errs.Query = "Please provide something to search for"
+		}
+
+		if p := r.URL.Query().Get("page"); p != "" {
+			var err error
+
+			if page, err = strconv.Atoi(p); err != nil || page < 1 {
+				errs.Page = "Page should be a number greater than zero"
+			}
+		}
+
+		if errs.Query != "" || errs.Page != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid query parameters", data{Results: []searchResult{}}, errs})
+			return
+		}
+
+		if includeUnpublished {
+			userID, err := getUser(r)
+
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			q.IncludeUnpublished = true
+
+			//Without the permission, users only find the unpublished posts they wrote
+			if !middleware.Can(r, models.POST_READ_ANY) {
+				q.UserID = userID
+			}
+		}
+
+		results, err := h.DB.Search(r.Context(), q, page)
+
+		if err == models.ErrEmptySearch {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid query parameters", data{Results: []searchResult{}}, errorMessages{Query: "Please provide something to search for"}})
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while searching", data{Results: []searchResult{}}, errorMessages{}})
+			return
+		}
+
+		found := make([]searchResult, 0, len(results))
+
+		for _, result := range results {
+			s := searchResult{publicPost: newPublicPost(result.Post), Snippet: highlight(result.Snippet)}
+
+			if includeUnpublished {
+				s.Status = models.StatusName(result.Status)
+			}
+
+			found = append(found, s)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Search results were fetched", data{found, page, models.DEFAULT_PER_PAGE}, errorMessages{}})
+	}
+}
+
+//Snippets are taken from what writers typed, so they are escaped before the matches are marked
+func highlight(snippet string) string {
+	return strings.NewReplacer(models.HIGHLIGHT_START, "<mark>", models.HIGHLIGHT_END, "</mark>").
+		Replace(html.EscapeString(snippet))
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func searchResults() []models.SearchResult {
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	return []models.SearchResult{
+		{Post: models.Post{ID: 2, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test all the things", Status: models.DRAFT,
+			CreatedAt: createdAt, UpdatedAt: createdAt, User: models.User{Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"}},
+			Snippet: "<b>Table</b> driven " + models.HIGHLIGHT_START + "tests" + models.HIGHLIGHT_END + "…"},
+	}
+}
+
+func TestPublishedPostsCanBeSearched(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	results := searchResults()
+	results[0].Status = PUBLISHED
+
+	db.On("Search", mock.Anything, models.SearchQuery{Terms: "tests"}, 2).Once().Return(results, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/search?q=+tests+&page=2", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Search(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Search results were fetched","data":{"results":[{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"","excerpt":"","reading_time":0,"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"},"snippet":"&lt;b&gt;Table&lt;/b&gt; driven <mark>tests</mark>…"}],"page":2,"per_page":20},"errors":{"q":"","page":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestSearchNeedsAQuery(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/search?q=%20&page=zero", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Search(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Invalid query parameters","data":{"results":[],"page":0,"per_page":0},"errors":{"q":"Please provide something to search for","page":"Page should be a number greater than zero"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestSearchWithNothingToLookFor(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("Search", mock.Anything, models.SearchQuery{Terms: `""`}, 1).Once().Return(nil, models.ErrEmptySearch)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", `/search?q=""`, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Search(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestAnErrorOccurredWhileSearching(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("Search", mock.Anything, models.SearchQuery{Terms: "go"}, 1).Once().Return(nil, errors.New("Could not search posts"))
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/search?q=go", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Search(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusInternalServerError {
+		t.Fatalf("Expected %d. Got %d", http.StatusInternalServerError, status)
+	}
+}
+
+func TestACollaboratorCanSearchTheirDrafts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("Search", mock.Anything, models.SearchQuery{Terms: "tests", IncludeUnpublished: true, UserID: 15}, 1).Once().Return(searchResults(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/search?q=tests", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(SearchDrafts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Search results were fetched","data":{"results":[{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"","excerpt":"","reading_time":0,"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"},"snippet":"&lt;b&gt;Table&lt;/b&gt; driven <mark>tests</mark>…","status":"draft"}],"page":1,"per_page":20},"errors":{"q":"","page":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAnAdminCanSearchEveryDraft(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("Search", mock.Anything, models.SearchQuery{Terms: "tests", IncludeUnpublished: true}, 1).Once().Return(searchResults(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/search?q=tests", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(SearchDrafts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestDraftsCannotBeSearchedWithoutLoggingIn(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/reblog/search?q=tests", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(SearchDrafts(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/xml"
+	"github.com/adelowo/reblog/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//The most urls a single sitemap may hold according to the sitemaps protocol
+const SITEMAP_SIZE = 50000
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+//Lists every published post for search engines.
+//Once there are more posts than a sitemap can hold, it becomes a sitemap index
+//pointing to the pages at /sitemap.xml?page=n
+func Sitemap(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		total, err := h.DB.CountPublishedPosts(r.Context(), models.PostFilter{})
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		pages := (total + SITEMAP_SIZE - 1) / SITEMAP_SIZE
+
+		var doc interface{}
+
+		switch param := r.URL.Query().Get("page"); {
+		case param == "" && pages > 1:
+			index := sitemapIndex{XMLNS: sitemapNamespace}
+
+			for i := 1; i <= pages; i++ {
+				index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: h.Site.baseURL() + "/sitemap.xml?page=" + strconv.Itoa(i)})
+			}
+
+			doc = index
+		default:
+			page := 1
+
+			if param != "" {
+				if page, err = strconv.Atoi(param); err != nil || page < 1 || page > pages {
+					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+					return
+				}
+			}
+
+			//Oldest first so a post never moves to another page
+			posts, err := h.DB.FindPublishedPosts(r.Context(), models.PostFilter{Page: page, PerPage: SITEMAP_SIZE, SortBy: models.SORT_CREATED_AT, Ascending: true})
+
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			set := urlSet{XMLNS: sitemapNamespace, URLs: []sitemapURL{}}
+
+			for _, p := range posts {
+				set.URLs = append(set.URLs, sitemapURL{h.Site.postURL(p), p.UpdatedAt.UTC().Format(time.RFC3339)})
+			}
+
+			doc = set
+		}
+
+		body, err := encodeXML(doc)
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
+
+//Serves the configured robots.txt.
+//Without one, crawlers are kept out of the admin api and pointed to the sitemap
+func Robots(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		robots := h.Site.Robots
+
+		if robots == "" {
+			robots = "User-agent: *\nDisallow: /reblog/\n\nSitemap: " + h.Site.baseURL() + "/sitemap.xml\n"
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(robots))
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveSitemap(t *testing.T, h *Handler, path string) *httptest.ResponseRecorder {
+
+	req, err := http.NewRequest("GET", path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Sitemap(h)).
+		ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestSitemapListsPublishedPosts(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("CountPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(2, nil)
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{Page: 1, PerPage: SITEMAP_SIZE, SortBy: models.SORT_CREATED_AT, Ascending: true}).
+		Once().Return(feedPosts(), nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveSitemap(t, h, "/sitemap.xml")
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Equal(t, "application/xml; charset=utf-8", rr.Header().Get("Content-Type"))
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+		`<url><loc>https://blog.example.com/posts/Testing-is-key</loc><lastmod>2017-01-22T10:00:00Z</lastmod></url>` +
+		`<url><loc>https://blog.example.com/posts/Hello-world</loc><lastmod>2017-01-19T10:00:00Z</lastmod></url>` +
+		`</urlset>`
+
+	assert.Equal(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestSitemapIsSplitIntoAnIndexWhenItGetsTooBig(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("CountPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(SITEMAP_SIZE+1, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveSitemap(t, h, "/sitemap.xml")
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+		`<sitemap><loc>https://blog.example.com/sitemap.xml?page=1</loc></sitemap>` +
+		`<sitemap><loc>https://blog.example.com/sitemap.xml?page=2</loc></sitemap>` +
+		`</sitemapindex>`
+
+	assert.Equal(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAPageOfASplitSitemap(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("CountPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(SITEMAP_SIZE+1, nil)
+	db.On("FindPublishedPosts", mock.Anything, models.PostFilter{Page: 2, PerPage: SITEMAP_SIZE, SortBy: models.SORT_CREATED_AT, Ascending: true}).
+		Once().Return(feedPosts()[1:], nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+	rr := serveSitemap(t, h, "/sitemap.xml?page=2")
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+		`<url><loc>https://blog.example.com/posts/Hello-world</loc><lastmod>2017-01-19T10:00:00Z</lastmod></url>` +
+		`</urlset>`
+
+	assert.Equal(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestSitemapPagesOutOfRangeDoNotExist(t *testing.T) {
+
+	for _, path := range []string{"/sitemap.xml?page=3", "/sitemap.xml?page=0", "/sitemap.xml?page=one"} {
+
+		db := new(mocks.DataStore)
+
+		db.On("CountPublishedPosts", mock.Anything, models.PostFilter{}).Once().Return(SITEMAP_SIZE+1, nil)
+
+		h := &Handler{DB: db, JWT: newTestJWT(), Site: testSite}
+
+		if status := serveSitemap(t, h, path).Code; status != http.StatusNotFound {
+			t.Fatalf("Expected %d for %s. Got %d", http.StatusNotFound, path, status)
+		}
+	}
+}
+
+func TestDefaultRobotsTxt(t *testing.T) {
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT(), Site: testSite}
+
+	req, err := http.NewRequest("GET", "/robots.txt", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Robots(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "User-agent: *\nDisallow: /reblog/\n\nSitemap: https://blog.example.com/sitemap.xml\n", rr.Body.String())
+}
+
+func TestConfiguredRobotsTxt(t *testing.T) {
+
+	site := testSite
+	site.Robots = "User-agent: *\nDisallow: /\n"
+
+	h := &Handler{DB: new(mocks.DataStore), JWT: newTestJWT(), Site: site}
+
+	req, err := http.NewRequest("GET", "/robots.txt", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(Robots(h)).
+		ServeHTTP(rr, req)
+
+	assert.Equal(t, "User-agent: *\nDisallow: /\n", rr.Body.String())
+}
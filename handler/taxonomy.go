@@ -0,0 +1,367 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/reblog/models"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"net/http"
+	"strings"
+)
+
+type publicTag struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+//publicCategory is a node of the category tree
+type publicCategory struct {
+	Name     string            `json:"name"`
+	Slug     string            `json:"slug"`
+	Children []*publicCategory `json:"children,omitempty"`
+}
+
+//Lists every tag along with the number of published posts using it
+func ListTags(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type tag struct {
+		Name  string `json:"name"`
+		Slug  string `json:"slug"`
+		Posts int    `json:"posts"`
+	}
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+		Data    []tag  `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		tags, err := h.DB.FindTags(r.Context())
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching tags", []tag{}})
+			return
+		}
+
+		t := make([]tag, 0, len(tags))
+
+		for _, v := range tags {
+			t = append(t, tag{v.Name, v.Slug, v.Posts})
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Tags were fetched", t})
+	}
+}
+
+//Lists categories as a tree. Top level categories are at the root
+func ListCategories(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool              `json:"status"`
+		Message string            `json:"message"`
+		Data    []*publicCategory `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		categories, err := h.DB.FindCategories(r.Context())
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching categories", []*publicCategory{}})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Categories were fetched", categoryTree(categories)})
+	}
+}
+
+//Creates a category. A parent can be given by its slug
+func CreateCategory(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Name   string `json:"name"`
+		Parent string `json:"parent"`
+	}
+
+	type errorMessages struct {
+		Name   string `json:"name"`
+		Parent string `json:"parent"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var data d
+
+		decoder := json.NewDecoder(r.Body)
+
+		if err := decoder.Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Category could not be created", errorMessages{}})
+			return
+		}
+
+		name := strings.TrimSpace(data.Name)
+
+		if name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Category could not be created due to invalid data", errorMessages{Name: "Please provide the category's name"}})
+			return
+		}
+
+		c := models.Category{Name: name, Slug: h.Slug.Generate(name)}
+
+		if data.Parent != "" {
+			parent, err := h.DB.FindCategoryBySlug(r.Context(), data.Parent)
+
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				render.JSON(w, r, &res{false, "Category could not be created due to invalid data", errorMessages{Parent: "Parent category does not exist"}})
+				return
+			}
+
+			c.ParentID = &parent.ID
+		}
+
+		err := h.DB.CreateCategory(r.Context(), &c)
+
+		if err == nil {
+			w.WriteHeader(http.StatusOK)
+			render.JSON(w, r, &res{true, "Category was created", errorMessages{}})
+			return
+		}
+
+		if err == models.ErrCategoryExists {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Could not create category as that would lead to duplicates", errorMessages{Name: "Category with name, " + name + " already exists"}})
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, &res{false, "An error occurred while trying to create the category", errorMessages{}})
+	}
+}
+
+//Renames a tag. The slug is regenerated from the new name
+func RenameTag(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Name string `json:"name"`
+	}
+
+	type errorMessages struct {
+		Name string `json:"name"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		t, err := h.DB.FindTagBySlug(r.Context(), chi.URLParam(r, "slug"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Tag does not exist", errorMessages{}})
+			return
+		}
+
+		var data d
+
+		decoder := json.NewDecoder(r.Body)
+
+		if err := decoder.Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Tag could not be renamed", errorMessages{}})
+			return
+		}
+
+		name := strings.TrimSpace(data.Name)
+
+		if name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Tag could not be renamed due to invalid data", errorMessages{Name: "Please provide the tag's new name"}})
+			return
+		}
+
+		t.Name = name
+		t.Slug = h.Slug.Generate(name)
+
+		err = h.DB.RenameTag(r.Context(), &t)
+
+		if err == nil {
+			w.WriteHeader(http.StatusOK)
+			render.JSON(w, r, &res{true, "Tag was renamed", errorMessages{}})
+			return
+		}
+
+		if err == models.ErrTagExists {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Could not rename tag as that would lead to duplicates", errorMessages{Name: "Tag with name, " + name + " already exists"}})
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, &res{false, "An error occurred while trying to rename the tag", errorMessages{}})
+	}
+}
+
+//Moves every post with the tag in the url over to another tag, then deletes it
+func MergeTag(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Into string `json:"into"`
+	}
+
+	type errorMessages struct {
+		Into string `json:"into"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, err := h.DB.FindTagBySlug(r.Context(), chi.URLParam(r, "slug"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Tag does not exist", errorMessages{}})
+			return
+		}
+
+		var data d
+
+		decoder := json.NewDecoder(r.Body)
+
+		if err := decoder.Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Tags could not be merged", errorMessages{}})
+			return
+		}
+
+		into, err := h.DB.FindTagBySlug(r.Context(), data.Into)
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Tags could not be merged due to invalid data", errorMessages{Into: "Tag to merge into does not exist"}})
+			return
+		}
+
+		if into.ID == from.ID {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Tags could not be merged due to invalid data", errorMessages{Into: "A tag cannot be merged into itself"}})
+			return
+		}
+
+		if err = h.DB.MergeTags(r.Context(), from, into); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while trying to merge the tags", errorMessages{}})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Tags were merged", errorMessages{}})
+	}
+}
+
+func DeleteTag(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		t, err := h.DB.FindTagBySlug(r.Context(), chi.URLParam(r, "slug"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			render.JSON(w, r, &res{false, "Tag does not exist"})
+			return
+		}
+
+		if err = h.DB.DeleteTag(r.Context(), t); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while trying to delete the tag"})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Tag was deleted"})
+	}
+}
+
+//Turns tag names sent by a writer into tags, dropping blanks and duplicates.
+//A non nil slice is always returned for non nil names so that "tags": [] clears a post's tags.
+func tagsFromNames(h *Handler, names []string) []models.Tag {
+	if names == nil {
+		return nil
+	}
+
+	tags := []models.Tag{}
+	seen := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			continue
+		}
+
+		slug := h.Slug.Generate(name)
+
+		if seen[slug] {
+			continue
+		}
+
+		seen[slug] = true
+		tags = append(tags, models.Tag{Name: name, Slug: slug})
+	}
+
+	return tags
+}
+
+//Builds the category tree out of a flat list.
+//Categories whose parent is unknown are placed at the root
+func categoryTree(categories []models.Category) []*publicCategory {
+	nodes := make(map[int]*publicCategory, len(categories))
+
+	for _, c := range categories {
+		nodes[c.ID] = &publicCategory{Name: c.Name, Slug: c.Slug}
+	}
+
+	roots := []*publicCategory{}
+
+	for _, c := range categories {
+		node := nodes[c.ID]
+
+		if c.ParentID != nil {
+			if parent, ok := nodes[*c.ParentID]; ok && *c.ParentID != c.ID {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+
+		roots = append(roots, node)
+	}
+
+	return roots
+}
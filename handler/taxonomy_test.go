@@ -0,0 +1,531 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTagsCanBeListed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindTags", mock.Anything).Once().Return([]models.Tag{{ID: 1, Name: "go lang", Slug: "go-lang", Posts: 3}, {ID: 2, Name: "web", Slug: "web"}}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/tags", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListTags(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Tags were fetched","data":[{"name":"go lang","slug":"go-lang","posts":3},{"name":"web","slug":"web","posts":0}]}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestPostsCanBeListedByTag(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	posts := []models.Post{
+		{ID: 2, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test all the things", Status: PUBLISHED,
+			CreatedAt: createdAt, UpdatedAt: createdAt, User: models.User{Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"},
+			Category: models.Category{ID: 4, Name: "Go", Slug: "Go"}},
+	}
+
+	filter := models.PostFilter{Tag: "go-lang"}
+
+	db.On("FindTagBySlug", mock.Anything, "go-lang").Once().Return(models.Tag{ID: 1, Name: "go lang", Slug: "go-lang"}, nil)
+	db.On("FindPublishedPosts", mock.Anything, filter).Once().Return(posts, nil)
+	db.On("CountPublishedPosts", mock.Anything, filter).Once().Return(1, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/tags/go-lang/posts", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Get("/tags/:slug/posts", ListTagPosts(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Posts were fetched","data":{"posts":[{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"","excerpt":"","reading_time":0,"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"},"category":{"name":"Go","slug":"Go"}}],"pagination":{"page":1,"per_page":20,"total":1,"total_pages":1}},"errors":{"page":"","per_page":"","sort":"","order":"","from":"","to":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestPostsCannotBeListedByAnUnknownTag(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindTagBySlug", mock.Anything, "unknown").Once().Return(models.Tag{}, errors.New("Tag does not exist"))
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/tags/unknown/posts", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Get("/tags/:slug/posts", ListTagPosts(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Fatalf("Expected %d. Got %d", http.StatusNotFound, status)
+	}
+}
+
+func TestAPublishedPostIsShownWithItsTags(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	createdAt := time.Date(2017, time.January, 20, 10, 0, 0, 0, time.UTC)
+
+	p := models.Post{ID: 2, Title: "Testing is key", Slug: "Testing-is-key", Content: "Test all the things", Status: PUBLISHED,
+		CreatedAt: createdAt, UpdatedAt: createdAt, User: models.User{Moniker: "hades", Name: "Lanre Adelowo", About: "Gopher"},
+		Tags: []models.Tag{{ID: 1, Name: "go lang", Slug: "go-lang"}}}
+
+	db.On("FindPublishedPostBySlug", mock.Anything, "Testing-is-key").Once().Return(p, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/posts/Testing-is-key", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Get("/posts/:slug", ShowPost(h))
+
+	r.ServeHTTP(rr, req)
+
+	expected := string(`{"status":true,"message":"Post was fetched","data":{"id":2,"title":"Testing is key","slug":"Testing-is-key","content":"Test all the things","content_html":"","excerpt":"","reading_time":0,"created_at":"2017-01-20T10:00:00Z","updated_at":"2017-01-20T10:00:00Z","author":{"moniker":"hades","name":"Lanre Adelowo","about":"Gopher"},"tags":[{"name":"go lang","slug":"go-lang"}]}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestCategoriesAreListedAsATree(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	tech, golang := 1, 2
+
+	db.On("FindCategories", mock.Anything).Once().Return([]models.Category{
+		{ID: golang, Name: "Go", Slug: "Go", ParentID: &tech},
+		{ID: 3, Name: "Life", Slug: "Life"},
+		{ID: 4, Name: "Testing", Slug: "Testing", ParentID: &golang},
+		{ID: tech, Name: "Tech", Slug: "Tech"},
+	}, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("GET", "/categories", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(ListCategories(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Categories were fetched","data":[{"name":"Life","slug":"Life"},{"name":"Tech","slug":"Tech","children":[{"name":"Go","slug":"Go","children":[{"name":"Testing","slug":"Testing"}]}]}]}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAdminCanCreateACategory(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	parent := models.Category{ID: 1, Name: "Tech", Slug: "Tech"}
+
+	db.On("FindCategoryBySlug", mock.Anything, "Tech").Once().Return(parent, nil)
+	db.On("CreateCategory", mock.Anything, &models.Category{Name: "Go lang", Slug: "Go-lang", ParentID: &parent.ID}).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req, err := http.NewRequest("POST", "/reblog/categories", bytes.NewBuffer([]byte(`{"name":"Go lang","parent":"Tech"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreateCategory(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Category was created","errors":{"name":"","parent":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestCannotCreateADuplicateCategory(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("CreateCategory", mock.Anything, &models.Category{Name: "Tech", Slug: "Tech"}).Once().Return(models.ErrCategoryExists)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req, err := http.NewRequest("POST", "/reblog/categories", bytes.NewBuffer([]byte(`{"name":"Tech"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreateCategory(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Could not create category as that would lead to duplicates","errors":{"name":"Category with name, Tech already exists","parent":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAdminCanRenameATag(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindTagBySlug", mock.Anything, "golang").Once().Return(models.Tag{ID: 1, Name: "golang", Slug: "golang"}, nil)
+	db.On("RenameTag", mock.Anything, &models.Tag{ID: 1, Name: "go lang", Slug: "go-lang"}).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req, err := http.NewRequest("PATCH", "/reblog/tags/golang", bytes.NewBuffer([]byte(`{"name":"go lang"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/tags/:slug", RenameTag(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Tag was renamed","errors":{"name":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestCannotRenameATagToAnExistingOne(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindTagBySlug", mock.Anything, "golang").Once().Return(models.Tag{ID: 1, Name: "golang", Slug: "golang"}, nil)
+	db.On("RenameTag", mock.Anything, &models.Tag{ID: 1, Name: "web", Slug: "web"}).Once().Return(models.ErrTagExists)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req, err := http.NewRequest("PATCH", "/reblog/tags/golang", bytes.NewBuffer([]byte(`{"name":"web"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/tags/:slug", RenameTag(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Could not rename tag as that would lead to duplicates","errors":{"name":"Tag with name, web already exists"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAdminCanMergeTags(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	from := models.Tag{ID: 1, Name: "golang", Slug: "golang"}
+	into := models.Tag{ID: 2, Name: "go", Slug: "go"}
+
+	db.On("FindTagBySlug", mock.Anything, "golang").Once().Return(from, nil)
+	db.On("FindTagBySlug", mock.Anything, "go").Once().Return(into, nil)
+	db.On("MergeTags", mock.Anything, from, into).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/tags/golang/merge", bytes.NewBuffer([]byte(`{"into":"go"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Post("/reblog/tags/:slug/merge", MergeTag(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	expected := string(`{"status":true,"message":"Tags were merged","errors":{"into":""}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestATagCannotBeMergedIntoItself(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	tag := models.Tag{ID: 1, Name: "go", Slug: "go"}
+
+	db.On("FindTagBySlug", mock.Anything, "go").Twice().Return(tag, nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("POST", "/reblog/tags/go/merge", bytes.NewBuffer([]byte(`{"into":"go"}`)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Post("/reblog/tags/:slug/merge", MergeTag(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Tags could not be merged due to invalid data","errors":{"into":"A tag cannot be merged into itself"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAdminCanDeleteATag(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	tag := models.Tag{ID: 1, Name: "go", Slug: "go"}
+
+	db.On("FindTagBySlug", mock.Anything, "go").Once().Return(tag, nil)
+	db.On("DeleteTag", mock.Anything, tag).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	req, err := http.NewRequest("DELETE", "/reblog/tags/go", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Delete("/reblog/tags/:slug", DeleteTag(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.JSONEq(t, `{"status":true,"message":"Tag was deleted"}`, rr.Body.String())
+
+	db.AssertExpectations(t)
+}
+
+func TestAPostCanBeCreatedWithTagsAndACategory(t *testing.T) {
+
+	data := []byte(`{"title" : "Testing is key", "content" : "` + validContent + `", "category" : "Go", "tags" : ["go lang", " Testing ", "", "go lang"]}`)
+
+	req, err := http.NewRequest("POST", "/reblog/posts/create", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	category := models.Category{ID: 3, Name: "Go", Slug: "Go"}
+
+	db.On("FindPostByTitle", mock.Anything, "Testing is key").Once().Return(models.Post{}, errors.New("Post does not exists"))
+	db.On("FindCategoryBySlug", mock.Anything, "Go").Once().Return(category, nil)
+
+	p := withRenderedContent(models.Post{Title: "Testing is key", Slug: "Testing-is-key", Content: validContent, Status: PUBLISHED, CategoryID: &category.ID, UserID: 1,
+		Tags: []models.Tag{{Name: "go lang", Slug: "go-lang"}, {Name: "Testing", Slug: "Testing"}}})
+
+	db.On("CreatePost", mock.Anything, &p).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestAPostCannotBeCreatedInAnUnknownCategory(t *testing.T) {
+
+	data := []byte(`{"title" : "Testing is key", "content" : "` + validContent + `", "category" : "Unknown"}`)
+
+	req, err := http.NewRequest("POST", "/reblog/posts/create", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	db.On("FindPostByTitle", mock.Anything, "Testing is key").Once().Return(models.Post{}, errors.New("Post does not exists"))
+	db.On("FindCategoryBySlug", mock.Anything, "Unknown").Once().Return(models.Category{}, errors.New("Category does not exist"))
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 1, models.ADMIN)
+
+	rr := httptest.NewRecorder()
+
+	http.HandlerFunc(CreatePost(h)).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	expected := string(`{"status":false,"message":"Post could not be created due to invalid data","errors":{"title":"","content":"","publish_at":"","category":"Category does not exist"}}`)
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestUpdatingAPostCanClearItsTagsAndCategory(t *testing.T) {
+
+	data := []byte(`{"category" : "", "tags" : []}`)
+
+	req, err := http.NewRequest("PATCH", "/reblog/posts/10", bytes.NewBuffer(data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(mocks.DataStore)
+
+	categoryID := 3
+
+	p := models.Post{ID: 10, Title: "Testing is key", Slug: "Testing-is-key", Content: validContent, UserID: 15, CategoryID: &categoryID}
+
+	db.On("FindPostByID", mock.Anything, 10).Once().Return(p, nil)
+
+	updated := p
+	updated.CategoryID = nil
+	updated.Tags = []models.Tag{}
+	updated = withRenderedContent(updated)
+
+	db.On("UpdatePost", mock.Anything, &updated).Once().Return(nil)
+
+	h := &Handler{DB: db, JWT: newTestJWT(), Slug: utils.NewSlugGenerator()}
+
+	req = authenticate(t, h, req, 15, models.CONTRIBUTOR)
+
+	rr := httptest.NewRecorder()
+
+	r := chi.NewRouter()
+
+	r.Patch("/reblog/posts/:id", UpdatePost(h))
+
+	r.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	db.AssertExpectations(t)
+}
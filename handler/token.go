@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/reblog/models"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pressly/chi/render"
+	"net/http"
+	"time"
+)
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+//RefreshToken trades a refresh token for a new access token and a new refresh token.
+//A refresh token can only be used once. Using it again means it has leaked,
+//so every token issued since the user logged in is revoked.
+func RefreshToken(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+		Data    tokens `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data refreshTokenRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || data.RefreshToken == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Please provide your refresh token", tokens{}})
+			return
+		}
+
+		ctx := r.Context()
+
+		t, err := h.DB.FindRefreshToken(ctx, data.RefreshToken)
+
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "Invalid refresh token", tokens{}})
+			return
+		}
+
+		if t.RevokedAt != nil {
+			h.DB.RevokeTokenFamily(ctx, t.Family)
+
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "The refresh token has been revoked. Please log in again", tokens{}})
+			return
+		}
+
+		if t.Expired(time.Now()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "The refresh token has expired. Please log in again", tokens{}})
+			return
+		}
+
+		user, err := h.DB.FindByID(ctx, t.UserID)
+
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "Invalid refresh token", tokens{}})
+			return
+		}
+
+		if user.Suspended() {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "Your account has been suspended", tokens{}})
+			return
+		}
+
+		var refreshToken string
+
+		err = h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.RevokeRefreshToken(ctx, t); err != nil {
+				return err
+			}
+
+			refreshToken, err = tx.CreateRefreshToken(ctx, t.UserID, t.Family)
+
+			return err
+		})
+
+		//Someone else used the same token in the meantime
+		if err == models.ErrRefreshTokenRevoked {
+			h.DB.RevokeTokenFamily(ctx, t.Family)
+
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "The refresh token has been revoked. Please log in again", tokens{}})
+			return
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried refreshing your token", tokens{}})
+			return
+		}
+
+		token, err := accessToken(ctx, h, user)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried refreshing your token", tokens{}})
+			return
+		}
+
+		render.JSON(w, r, &res{true, "Your token has been refreshed", tokens{token, refreshToken}})
+	}
+}
+
+//PostLogout revokes the refresh token along with every token issued with it since the user logged in.
+//The access token the request was made with, if any, can't be used anymore either.
+func PostLogout(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var data refreshTokenRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || data.RefreshToken == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Please provide your refresh token"})
+			return
+		}
+
+		ctx := r.Context()
+
+		t, err := h.DB.FindRefreshToken(ctx, data.RefreshToken)
+
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "Invalid refresh token"})
+			return
+		}
+
+		if err = h.DB.RevokeTokenFamily(ctx, t.Family); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while we tried logging you out"})
+			return
+		}
+
+		if token, ok := ctx.Value("jwt").(*jwt.Token); ok && token != nil && token.Valid {
+			jti, _ := token.Claims["jti"].(string)
+			exp, _ := token.Claims["exp"].(float64)
+
+			if jti != "" {
+				if err = h.DB.RevokeAccessToken(ctx, jti, time.Unix(int64(exp), 0)); err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					render.JSON(w, r, &res{false, "An error occurred while we tried logging you out"})
+					return
+				}
+			}
+		}
+
+		render.JSON(w, r, &res{true, "You have been logged out"})
+	}
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/goware/jwtauth"
+	"github.com/pkg/errors"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+//postJSON sends body to handler, with the access token if there is one
+func postJSON(t *testing.T, handler http.Handler, path, body, token string) *httptest.ResponseRecorder {
+
+	req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "BEARER "+token)
+	}
+
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func decodeTokens(t *testing.T, rr *httptest.ResponseRecorder) tokens {
+
+	var res struct {
+		Data tokens `json:"data"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res.Data
+}
+
+func TestATokenCanBeRefreshed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	token := models.RefreshToken{ID: 3, UserID: 1, Family: "family", ExpiresAt: time.Now().Add(time.Hour)}
+
+	db.On("FindRefreshToken", mock.Anything, "refresh-token").Return(token, nil)
+	db.On("FindByID", mock.Anything, 1).Return(models.User{ID: 1, Moniker: "adelowo", Role: models.CONTRIBUTOR}, nil)
+	db.On("RevokeRefreshToken", mock.Anything, token).Once().Return(nil)
+	db.On("CreateRefreshToken", mock.Anything, 1, "family").Once().Return("new-refresh-token", nil)
+	db.On("FindRole", mock.Anything, models.CONTRIBUTOR).Return(models.Role{Name: models.CONTRIBUTOR, Permissions: permissionsOf(models.CONTRIBUTOR)}, nil)
+
+	runTxOn(db)
+
+	rr := postJSON(t, http.HandlerFunc(RefreshToken(h)), "/token/refresh", `{"refresh_token" : "refresh-token"}`, "")
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	tokens := decodeTokens(t, rr)
+
+	assert.NotEmpty(t, tokens.Token)
+	assert.Equal(t, "new-refresh-token", tokens.RefreshToken)
+
+	db.AssertExpectations(t)
+}
+
+func TestAReusedRefreshTokenRevokesItsFamily(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	revokedAt := time.Now().Add(-time.Minute)
+
+	db.On("FindRefreshToken", mock.Anything, "refresh-token").
+		Return(models.RefreshToken{ID: 3, UserID: 1, Family: "family", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt}, nil)
+
+	db.On("RevokeTokenFamily", mock.Anything, "family").Once().Return(nil)
+
+	rr := postJSON(t, http.HandlerFunc(RefreshToken(h)), "/token/refresh", `{"refresh_token" : "refresh-token"}`, "")
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+
+	expected := `{"status":false,"message":"The refresh token has been revoked. Please log in again","data":{"token":"","refresh_token":""}}`
+
+	assert.JSONEq(t, expected, rr.Body.String())
+
+	db.AssertExpectations(t)
+	db.AssertNotCalled(t, "CreateRefreshToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestARefreshTokenUsedTwiceAtTheSameTimeRevokesItsFamily(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	token := models.RefreshToken{ID: 3, UserID: 1, Family: "family", ExpiresAt: time.Now().Add(time.Hour)}
+
+	db.On("FindRefreshToken", mock.Anything, "refresh-token").Return(token, nil)
+	db.On("FindByID", mock.Anything, 1).Return(models.User{ID: 1, Moniker: "adelowo"}, nil)
+	db.On("RevokeRefreshToken", mock.Anything, token).Return(models.ErrRefreshTokenRevoked)
+	db.On("RevokeTokenFamily", mock.Anything, "family").Once().Return(nil)
+
+	runTxOn(db)
+
+	rr := postJSON(t, http.HandlerFunc(RefreshToken(h)), "/token/refresh", `{"refresh_token" : "refresh-token"}`, "")
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+
+	db.AssertExpectations(t)
+}
+
+func TestAnExpiredRefreshTokenCannotBeUsed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	db.On("FindRefreshToken", mock.Anything, "refresh-token").
+		Return(models.RefreshToken{ID: 3, UserID: 1, Family: "family", ExpiresAt: time.Now().Add(-time.Minute)}, nil)
+
+	rr := postJSON(t, http.HandlerFunc(RefreshToken(h)), "/token/refresh", `{"refresh_token" : "refresh-token"}`, "")
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+
+	expected := `{"status":false,"message":"The refresh token has expired. Please log in again","data":{"token":"","refresh_token":""}}`
+
+	assert.JSONEq(t, expected, rr.Body.String())
+}
+
+func TestAnUnknownRefreshTokenCannotBeUsed(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+
+	db.On("FindRefreshToken", mock.Anything, "refresh-token").
+		Return(models.RefreshToken{}, errors.New("Refresh token does not exist"))
+
+	rr := postJSON(t, http.HandlerFunc(RefreshToken(h)), "/token/refresh", `{"refresh_token" : "refresh-token"}`, "")
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+
+	rr = postJSON(t, http.HandlerFunc(RefreshToken(h)), "/token/refresh", `{}`, "")
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d. Got %d", http.StatusBadRequest, status)
+	}
+
+	rr = postJSON(t, http.HandlerFunc(PostLogout(h)), "/logout", `{"refresh_token" : "refresh-token"}`, "")
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatalf("Expected %d. Got %d", http.StatusUnauthorized, status)
+	}
+}
+
+func TestTokensCanBeRefreshedUntilTheUserLogsOut(t *testing.T) {
+
+	db := memory.New()
+
+	if err := db.CreateUser(context.Background(), &models.User{Moniker: "adelowo", Name: "Lanre Adelowo", Email: "adelowo@me.com", Password: "badpassword"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := chi.NewRouter()
+
+	r.Post("/login", PostLogin(h))
+	r.Post("/token/refresh", RefreshToken(h))
+	r.With(h.JWT.Verifier).Post("/logout", PostLogout(h))
+
+	r.With(h.JWT.Verifier, jwtauth.Authenticator).Get("/reblog/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ping := func(token string) int {
+		req, _ := http.NewRequest("GET", "/reblog/ping", nil)
+		req.Header.Set("Authorization", "BEARER "+token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	login := postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "badpassword"}`, "")
+
+	if status := login.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	first := decodeTokens(t, login)
+
+	refreshed := postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+first.RefreshToken+`"}`, "")
+
+	if status := refreshed.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	second := decodeTokens(t, refreshed)
+
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEqual(t, first.Token, second.Token)
+	assert.Equal(t, http.StatusOK, ping(second.Token))
+
+	//Using the first refresh token again revokes the second one too
+	reused := postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+first.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, reused.Code)
+
+	reused = postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+second.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, reused.Code)
+
+	login = postJSON(t, r, "/login", `{"email" : "adelowo@me.com", "password" : "badpassword"}`, "")
+
+	third := decodeTokens(t, login)
+
+	assert.Equal(t, http.StatusOK, ping(third.Token))
+
+	logout := postJSON(t, r, "/logout", `{"refresh_token" : "`+third.RefreshToken+`"}`, third.Token)
+
+	if status := logout.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.JSONEq(t, `{"status":true,"message":"You have been logged out"}`, logout.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, ping(third.Token))
+
+	//Tokens from another login are left alone
+	assert.Equal(t, http.StatusOK, ping(second.Token))
+
+	refreshed = postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+third.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, refreshed.Code)
+}
@@ -1,12 +1,28 @@
 package handler
 
 import (
+	"github.com/adelowo/reblog/mailer"
 	"github.com/adelowo/reblog/models"
 	"github.com/adelowo/reblog/utils"
 )
 
 type Handler struct {
-	DB   models.DataStore
-	JWT  *utils.JWTTokenGenerator
-	Slug utils.Slug
+	DB       models.DataStore
+	JWT      *utils.JWTTokenGenerator
+	Slug     utils.Slug
+	Markdown utils.Markdown
+	Site     Site
+	Mailer   mailer.Mailer
+}
+
+//Site describes the blog itself. Feeds use it for their title, links and author.
+//URL is where the blog is served from, e.g https://blog.example.com
+//Robots is served as is at /robots.txt, a default is used when it is empty.
+type Site struct {
+	Title       string
+	Description string
+	URL         string
+	AuthorName  string
+	AuthorEmail string
+	Robots      string
 }
@@ -0,0 +1,484 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/models"
+	"github.com/pressly/chi"
+	"github.com/pressly/chi/render"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	//What happens to the posts of a deleted user
+	POSTS_REASSIGN = "reassign"
+	POSTS_DELETE   = "delete"
+)
+
+//managedUser is what users allowed to manage others get to see of an account
+type managedUser struct {
+	ID          int        `json:"id"`
+	Moniker     string     `json:"moniker"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Status      string     `json:"status"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func newManagedUser(u models.User) managedUser {
+	status := models.USER_ACTIVE
+
+	if u.Suspended() {
+		status = models.USER_SUSPENDED
+	}
+
+	return managedUser{u.ID, u.Moniker, u.Name, u.Email, u.Role, status, u.SuspendedAt, u.CreatedAt}
+}
+
+//ListUsers lists every user, oldest first.
+//Supported query parameters are page, per_page, role, status (active or suspended) and q,
+//which matches part of the users' moniker, name or email.
+func ListUsers(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type data struct {
+		Users      []managedUser `json:"users"`
+		Pagination pagination    `json:"pagination"`
+	}
+
+	type res struct {
+		Status  bool              `json:"status"`
+		Message string            `json:"message"`
+		Data    data              `json:"data"`
+		Errors  map[string]string `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		filter, errs := userFilterFromRequest(r)
+
+		if filter.Role != "" {
+			if _, err := h.DB.FindRole(r.Context(), filter.Role); err != nil {
+				errs["role"] = "The role does not exist"
+			}
+		}
+
+		if len(errs) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Invalid query parameters", data{Users: []managedUser{}}, errs})
+			return
+		}
+
+		users, err := h.DB.FindUsers(r.Context(), filter)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching users", data{Users: []managedUser{}}, errs})
+			return
+		}
+
+		total, err := h.DB.CountUsers(r.Context(), filter)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while fetching users", data{Users: []managedUser{}}, errs})
+			return
+		}
+
+		u := make([]managedUser, 0, len(users))
+
+		for _, user := range users {
+			u = append(u, newManagedUser(user))
+		}
+
+		p := newPagination(models.PostFilter{Page: filter.Page, PerPage: filter.PerPage}, total)
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "Users were fetched", data{u, p}, errs})
+	}
+}
+
+//Builds the user listing filter out of the query string.
+//Validation errors are keyed by the offending query parameter.
+func userFilterFromRequest(r *http.Request) (models.UserFilter, map[string]string) {
+	var f models.UserFilter
+	var err error
+
+	errs := make(map[string]string)
+	query := r.URL.Query()
+
+	if page := query.Get("page"); page != "" {
+		if f.Page, err = strconv.Atoi(page); err != nil || f.Page < 1 {
+			errs["page"] = "Page should be a number greater than zero"
+		}
+	}
+
+	if perPage := query.Get("per_page"); perPage != "" {
+		if f.PerPage, err = strconv.Atoi(perPage); err != nil || f.PerPage < 1 || f.PerPage > maxPerPage {
+			errs["per_page"] = "per_page should be a number between 1 and " + strconv.Itoa(maxPerPage)
+		}
+	}
+
+	switch status := query.Get("status"); status {
+	case "", models.USER_ACTIVE, models.USER_SUSPENDED:
+		f.Status = status
+	default:
+		errs["status"] = "Status should either be active or suspended"
+	}
+
+	f.Role = query.Get("role")
+	f.Query = query.Get("q")
+
+	return f, errs
+}
+
+//ShowUser fetches the account of the user in the url
+func ShowUser(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool        `json:"status"`
+		Message string      `json:"message"`
+		Data    managedUser `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := userFromRequest(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "User was fetched", newManagedUser(user)})
+	}
+}
+
+//UpdateUserRole gives the user in the url another role, which applies from their next login.
+//They are logged out everywhere: their refresh tokens are revoked and the access tokens carrying the permissions of their old role are refused.
+//Nobody can be made the owner, nor given a permission the user changing their role doesn't have.
+func UpdateUserRole(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type d struct {
+		Role string `json:"role"`
+	}
+
+	type errorMessages struct {
+		Role string `json:"role"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Data    *managedUser  `json:"data"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := userFromRequest(h, w, r)
+
+		if !ok || !canManageUser(h, w, r, user) {
+			return
+		}
+
+		var data d
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil || data.Role == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Validation failed", nil, errorMessages{"Please provide the user's new role"}})
+			return
+		}
+
+		role, err := h.DB.FindRole(r.Context(), data.Role)
+
+		if err != nil || role.Name == models.OWNER {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Validation failed", nil, errorMessages{"The role does not exist or cannot be given to users"}})
+			return
+		}
+
+		for _, p := range role.Permissions {
+			if !middleware.Can(r, p) {
+				w.WriteHeader(http.StatusUnauthorized)
+				render.JSON(w, r, &res{false, "You cannot give a user more permissions than you have", nil, errorMessages{"The " + role.Name + " role can " + p}})
+				return
+			}
+		}
+
+		ctx := r.Context()
+
+		err = h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.UpdateUserRole(ctx, user.ID, role.Name); err != nil {
+				return err
+			}
+
+			return tx.RevokeUserRefreshTokens(ctx, user.ID)
+		})
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while changing the user's role", nil, errorMessages{}})
+			return
+		}
+
+		user.Role = role.Name
+		u := newManagedUser(user)
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "The user's role was changed", &u, errorMessages{}})
+	}
+}
+
+//SuspendUser keeps the user in the url from logging in until they are reactivated.
+//They are logged out everywhere and the access tokens they still hold are refused right away.
+func SuspendUser(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool         `json:"status"`
+		Message string       `json:"message"`
+		Data    *managedUser `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := userFromRequest(h, w, r)
+
+		if !ok || !canManageUser(h, w, r, user) {
+			return
+		}
+
+		ctx := r.Context()
+
+		err := h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+			if err := tx.SuspendUser(ctx, user.ID, time.Now()); err != nil {
+				return err
+			}
+
+			return tx.RevokeUserRefreshTokens(ctx, user.ID)
+		})
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while suspending the user", nil})
+			return
+		}
+
+		if user, err = h.DB.FindByID(ctx, user.ID); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while suspending the user", nil})
+			return
+		}
+
+		u := newManagedUser(user)
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "User was suspended", &u})
+	}
+}
+
+//ReactivateUser lifts the suspension of the user in the url, who can log in again
+func ReactivateUser(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	type res struct {
+		Status  bool         `json:"status"`
+		Message string       `json:"message"`
+		Data    *managedUser `json:"data"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := userFromRequest(h, w, r)
+
+		if !ok || !canManageUser(h, w, r, user) {
+			return
+		}
+
+		if err := h.DB.ReactivateUser(r.Context(), user.ID); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			render.JSON(w, r, &res{false, "An error occurred while reactivating the user", nil})
+			return
+		}
+
+		user.SuspendedAt = nil
+		u := newManagedUser(user)
+
+		w.WriteHeader(http.StatusOK)
+		render.JSON(w, r, &res{true, "User was reactivated", &u})
+	}
+}
+
+//userDeletion says what happens to the posts of a deleted user.
+//They are either reassigned to the user ReassignTo or deleted.
+type userDeletion struct {
+	Posts      string `json:"posts"`
+	ReassignTo int    `json:"reassign_to"`
+}
+
+//DeleteUser deletes the user in the url along with their tokens.
+//Their posts are reassigned to another user or deleted, as the request's posts field says.
+func DeleteUser(h *Handler) func(w http.ResponseWriter, r *http.Request) {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := userFromRequest(h, w, r)
+
+		if !ok {
+			return
+		}
+
+		var data userDeletion
+
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			//Handled as if nothing was said about the posts
+			data = userDeletion{}
+		}
+
+		deleteUser(h, w, r, user, data)
+	}
+}
+
+//deleteUser deletes user once the request is known to be allowed to and data makes sense
+func deleteUser(h *Handler, w http.ResponseWriter, r *http.Request, user models.User, data userDeletion) {
+
+	type errorMessages struct {
+		Posts      string `json:"posts,omitempty"`
+		ReassignTo string `json:"reassign_to,omitempty"`
+	}
+
+	type res struct {
+		Status  bool          `json:"status"`
+		Message string        `json:"message"`
+		Errors  errorMessages `json:"errors"`
+	}
+
+	if !canManageUser(h, w, r, user) {
+		return
+	}
+
+	ctx := r.Context()
+
+	switch data.Posts {
+	case POSTS_DELETE:
+	case POSTS_REASSIGN:
+		if data.ReassignTo == user.ID {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Validation failed", errorMessages{ReassignTo: "The posts cannot be reassigned to the user being deleted"}})
+			return
+		}
+
+		if _, err := h.DB.FindByID(ctx, data.ReassignTo); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			render.JSON(w, r, &res{false, "Validation failed", errorMessages{ReassignTo: "Please provide the id of the user the posts are reassigned to"}})
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, &res{false, "Validation failed", errorMessages{Posts: "Please say whether the user's posts should be reassigned or deleted"}})
+		return
+	}
+
+	err := h.DB.WithTx(ctx, func(tx models.DataStore) error {
+
+		var err error
+
+		if data.Posts == POSTS_REASSIGN {
+			err = tx.ReassignPosts(ctx, user.ID, data.ReassignTo)
+		} else {
+			err = tx.DeleteUserPosts(ctx, user.ID)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = tx.RevokeUserRefreshTokens(ctx, user.ID); err != nil {
+			return err
+		}
+
+		return tx.DeleteUser(ctx, user)
+	})
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, &res{false, "An error occurred while deleting the user", errorMessages{}})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	render.JSON(w, r, &res{true, "User was successfully deleted", errorMessages{}})
+}
+
+//userFromRequest loads the user whose id is in the url.
+//If there is none, a response has been written already and false is returned.
+func userFromRequest(h *Handler, w http.ResponseWriter, r *http.Request) (models.User, bool) {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, &res{false, "Invalid user id"})
+		return models.User{}, false
+	}
+
+	user, err := h.DB.FindByID(r.Context(), id)
+
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		render.JSON(w, r, &res{false, "User not found"})
+		return models.User{}, false
+	}
+
+	return user, true
+}
+
+//canManageUser tells if the request can change the role of user, suspend or delete them.
+//Nobody can do so to themselves, to the owner, or to a user with a permission they don't have.
+//If it can't, a response has been written already.
+func canManageUser(h *Handler, w http.ResponseWriter, r *http.Request, user models.User) bool {
+
+	type res struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}
+
+	if userID, err := getUser(r); err != nil || userID == user.ID {
+		w.WriteHeader(http.StatusBadRequest)
+		render.JSON(w, r, &res{false, "You cannot manage your own account"})
+		return false
+	}
+
+	if user.Role == models.OWNER {
+		w.WriteHeader(http.StatusUnauthorized)
+		render.JSON(w, r, &res{false, "The owner cannot be managed"})
+		return false
+	}
+
+	role, err := h.DB.FindRole(r.Context(), user.Role)
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		render.JSON(w, r, &res{false, "An error occurred while fetching the user's role"})
+		return false
+	}
+
+	for _, p := range role.Permissions {
+		if !middleware.Can(r, p) {
+			w.WriteHeader(http.StatusUnauthorized)
+			render.JSON(w, r, &res{false, "You cannot manage a user with more permissions than you have"})
+			return false
+		}
+	}
+
+	return true
+}
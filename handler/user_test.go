@@ -0,0 +1,368 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/memory"
+	"github.com/goware/jwtauth"
+	"github.com/pressly/chi"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func userRouter(h *Handler) http.Handler {
+	r := chi.NewRouter()
+
+	r.Post("/login", PostLogin(h))
+	r.Post("/token/refresh", RefreshToken(h))
+
+	r.Route("/reblog", func(ro chi.Router) {
+		ro.Use(h.JWT.Verifier)
+		ro.Use(jwtauth.Authenticator)
+
+		ro.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+		ro.Route("/users", func(roo chi.Router) {
+			roo.Use(middleware.Require(models.USER_MANAGE))
+
+			roo.Get("/", ListUsers(h))
+			roo.Get("/:id", ShowUser(h))
+			roo.Put("/:id/role", UpdateUserRole(h))
+			roo.Post("/:id/suspend", SuspendUser(h))
+			roo.Post("/:id/reactivate", ReactivateUser(h))
+			roo.Delete("/:id", DeleteUser(h))
+		})
+	})
+
+	return r
+}
+
+//newUserStore returns a store with an owner, an admin, an editor and an author, in that order.
+//Everyone's password is badpassword
+func newUserStore(t *testing.T) (*memory.Store, []models.User) {
+
+	db := memory.New()
+
+	var users []models.User
+
+	for _, role := range []string{models.OWNER, models.ADMIN, models.EDITOR, models.AUTHOR} {
+		u := models.User{Moniker: role, Name: "The " + role, Email: role + "@reblog.io", Password: "badpassword", Role: role}
+
+		if err := db.CreateUser(context.Background(), &u); err != nil {
+			t.Fatal(err)
+		}
+
+		created, err := db.FindByMoniker(context.Background(), role)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		users = append(users, created)
+	}
+
+	return db, users
+}
+
+func decodeManagedUsers(t *testing.T, body []byte) ([]managedUser, pagination) {
+
+	var res struct {
+		Data struct {
+			Users      []managedUser `json:"users"`
+			Pagination pagination    `json:"pagination"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	return res.Data.Users, res.Data.Pagination
+}
+
+func TestUsersCanBeListedAndFiltered(t *testing.T) {
+
+	db, users := newUserStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := userRouter(h)
+	admin := testToken(t, h, users[1].ID, models.ADMIN)
+
+	rr := sendJSON(t, r, "GET", "/reblog/users?per_page=3", "", admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	listed, p := decodeManagedUsers(t, rr.Body.Bytes())
+
+	assert.Len(t, listed, 3)
+	assert.Equal(t, "owner", listed[0].Moniker)
+	assert.Equal(t, pagination{1, 3, 4, 2}, p)
+
+	if err := db.SuspendUser(context.Background(), users[3].ID, users[3].CreatedAt); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, _ = decodeManagedUsers(t, sendJSON(t, r, "GET", "/reblog/users?status=suspended", "", admin).Body.Bytes())
+
+	assert.Len(t, listed, 1)
+	assert.Equal(t, "author", listed[0].Moniker)
+	assert.Equal(t, models.USER_SUSPENDED, listed[0].Status)
+
+	listed, _ = decodeManagedUsers(t, sendJSON(t, r, "GET", "/reblog/users?role=editor&q=EDITOR@", "", admin).Body.Bytes())
+
+	assert.Len(t, listed, 1)
+	assert.Equal(t, users[2].ID, listed[0].ID)
+
+	rr = sendJSON(t, r, "GET", "/reblog/users?status=banned&role=guest", "", admin)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Invalid query parameters","data":{"users":[],"pagination":{"page":0,"per_page":0,"total":0,"total_pages":0}},
+"errors":{"status":"Status should either be active or suspended","role":"The role does not exist"}}`, rr.Body.String())
+
+	//Editors can't manage users
+	rr = sendJSON(t, r, "GET", "/reblog/users/"+strconv.Itoa(users[3].ID), "", testToken(t, h, users[2].ID, models.EDITOR))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestSuspendedUsersAreLockedOutUntilReactivated(t *testing.T) {
+
+	db, users := newUserStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := userRouter(h)
+	admin := testToken(t, h, users[1].ID, models.ADMIN)
+	author := "/reblog/users/" + strconv.Itoa(users[3].ID)
+
+	login := postJSON(t, r, "/login", `{"email" : "author@reblog.io", "password" : "badpassword"}`, "")
+
+	if status := login.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	tokens := decodeTokens(t, login)
+
+	assert.Equal(t, http.StatusOK, sendJSON(t, r, "GET", "/reblog/ping", "", tokens.Token).Code)
+
+	rr := sendJSON(t, r, "POST", author+"/suspend", "", admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	//The access token is refused right away, without waiting for it to expire
+	assert.Equal(t, http.StatusUnauthorized, sendJSON(t, r, "GET", "/reblog/ping", "", tokens.Token).Code)
+
+	rr = postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+tokens.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = postJSON(t, r, "/login", `{"email" : "author@reblog.io", "password" : "badpassword"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Your account has been suspended","errors":{"username":"","email":"","password":""}}`, rr.Body.String())
+
+	rr = sendJSON(t, r, "POST", author+"/reactivate", "", admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	login = postJSON(t, r, "/login", `{"email" : "author@reblog.io", "password" : "badpassword"}`, "")
+
+	assert.Equal(t, http.StatusOK, login.Code)
+	assert.Equal(t, http.StatusOK, sendJSON(t, r, "GET", "/reblog/ping", "", decodeTokens(t, login).Token).Code)
+}
+
+func TestUsersCannotManageThemselvesTheOwnerOrUsersWithMorePermissions(t *testing.T) {
+
+	db, users := newUserStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := userRouter(h)
+	admin := testToken(t, h, users[1].ID, models.ADMIN)
+
+	rr := sendJSON(t, r, "POST", "/reblog/users/"+strconv.Itoa(users[1].ID)+"/suspend", "", admin)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"You cannot manage your own account"}`, rr.Body.String())
+
+	rr = sendJSON(t, r, "DELETE", "/reblog/users/"+strconv.Itoa(users[0].ID), `{"posts" : "delete"}`, admin)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"The owner cannot be managed"}`, rr.Body.String())
+
+	//Admins can't manage users who can change what roles do
+	if err := db.UpdateRolePermissions(context.Background(), models.EDITOR, append([]string{models.ROLE_MANAGE}, permissionsOf(models.EDITOR)...)); err != nil {
+		t.Fatal(err)
+	}
+
+	rr = sendJSON(t, r, "POST", "/reblog/users/"+strconv.Itoa(users[2].ID)+"/suspend", "", admin)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"You cannot manage a user with more permissions than you have"}`, rr.Body.String())
+
+	for _, u := range users {
+		stored, err := db.FindByID(context.Background(), u.ID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.False(t, stored.Suspended())
+	}
+}
+
+func TestTheRoleOfAUserCanBeChanged(t *testing.T) {
+
+	db, users := newUserStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := userRouter(h)
+	admin := testToken(t, h, users[1].ID, models.ADMIN)
+	author := "/reblog/users/" + strconv.Itoa(users[3].ID)
+
+	rr := sendJSON(t, r, "PUT", author+"/role", `{"role" : "owner"}`, admin)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Validation failed","data":null,"errors":{"role":"The role does not exist or cannot be given to users"}}`, rr.Body.String())
+
+	rr = sendJSON(t, r, "PUT", author+"/role", `{"role" : "editor"}`, admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	u, err := db.FindByID(context.Background(), users[3].ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, models.EDITOR, u.Role)
+}
+
+func TestADemotedUserCannotRefreshTheirOldPermissions(t *testing.T) {
+
+	db, users := newUserStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := userRouter(h)
+	admin := testToken(t, h, users[1].ID, models.ADMIN)
+
+	login := postJSON(t, r, "/login", `{"email" : "editor@reblog.io", "password" : "badpassword"}`, "")
+
+	if status := login.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	tokens := decodeTokens(t, login)
+
+	rr := sendJSON(t, r, "PUT", "/reblog/users/"+strconv.Itoa(users[2].ID)+"/role", `{"role" : "author"}`, admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	rr = postJSON(t, r, "/token/refresh", `{"refresh_token" : "`+tokens.RefreshToken+`"}`, "")
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	//The access token is refused right away, without waiting for it to expire
+	assert.Equal(t, http.StatusUnauthorized, sendJSON(t, r, "GET", "/reblog/ping", "", tokens.Token).Code)
+
+	login = postJSON(t, r, "/login", `{"email" : "editor@reblog.io", "password" : "badpassword"}`, "")
+
+	if status := login.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	assert.Equal(t, http.StatusOK, sendJSON(t, r, "GET", "/reblog/ping", "", decodeTokens(t, login).Token).Code)
+
+	to, err := h.JWT.Decode(decodeTokens(t, login).Token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, models.AUTHOR, to.Claims["role"])
+}
+
+func TestDeletingAUserReassignsOrDeletesTheirPosts(t *testing.T) {
+
+	db, users := newUserStore(t)
+
+	h := &Handler{DB: db, JWT: newTestJWT()}
+	h.JWT.UseDenylist(db)
+
+	r := userRouter(h)
+	ctx := context.Background()
+	admin := testToken(t, h, users[1].ID, models.ADMIN)
+
+	editorsPost := &models.Post{Title: "Go is awesome", Slug: "go-is-awesome", Content: validContent, UserID: users[2].ID}
+	authorsPost := &models.Post{Title: "Go is fast", Slug: "go-is-fast", Content: validContent, UserID: users[3].ID}
+
+	for _, p := range []*models.Post{editorsPost, authorsPost} {
+		if err := db.CreatePost(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	author := "/reblog/users/" + strconv.Itoa(users[3].ID)
+	authorsToken := testToken(t, h, users[3].ID, models.AUTHOR)
+
+	rr := sendJSON(t, r, "DELETE", author, `{}`, admin)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.JSONEq(t, `{"status":false,"message":"Validation failed","errors":{"posts":"Please say whether the user's posts should be reassigned or deleted"}}`, rr.Body.String())
+
+	rr = sendJSON(t, r, "DELETE", author, `{"posts" : "reassign", "reassign_to" : `+strconv.Itoa(users[3].ID)+`}`, admin)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = sendJSON(t, r, "DELETE", author, `{"posts" : "reassign", "reassign_to" : `+strconv.Itoa(users[0].ID)+`}`, admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	p, err := db.FindPostByID(ctx, authorsPost.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, users[0].ID, p.UserID)
+
+	//Deleted users' tokens are refused too
+	assert.Equal(t, http.StatusUnauthorized, sendJSON(t, r, "GET", "/reblog/ping", "", authorsToken).Code)
+	assert.Equal(t, http.StatusNotFound, sendJSON(t, r, "GET", author, "", admin).Code)
+
+	rr = sendJSON(t, r, "DELETE", "/reblog/users/"+strconv.Itoa(users[2].ID), `{"posts" : "delete"}`, admin)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Expected %d. Got %d", http.StatusOK, status)
+	}
+
+	_, err = db.FindPostByID(ctx, editorsPost.ID)
+
+	assert.Error(t, err)
+}
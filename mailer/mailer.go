@@ -0,0 +1,43 @@
+//Package mailer sends the emails reblog needs to get to its users, like password reset links.
+//Handlers only depend on the Mailer interface, so how emails are delivered can be swapped.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//Message is an email to a single recipient.
+//Text is always sent, HTML is an alternative for clients that can display it.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+//LogMailer writes every email to w instead of sending it.
+//It is meant for development, where links in emails still need to be followed.
+type LogMailer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogMailer(w io.Writer) *LogMailer {
+	return &LogMailer{w: w}
+}
+
+func (l *LogMailer) Send(ctx context.Context, m Message) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err := fmt.Fprintf(l.w, "To: %s\nSubject: %s\n\n%s\n\n", m.To, m.Subject, m.Text)
+
+	return err
+}
@@ -0,0 +1,20 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLogMailerWritesTheTextOfTheMessage(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	m := NewLogMailer(&buf)
+
+	err := m.Send(context.Background(), Message{To: "hades@reblog.io", Subject: "Hello", Text: "Hello Hades", HTML: "<p>Hello Hades</p>"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "To: hades@reblog.io\nSubject: Hello\n\nHello Hades\n\n", buf.String())
+}
@@ -0,0 +1,154 @@
+package mailer
+
+import (
+	"context"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/utils"
+	"log"
+	"sync"
+	"time"
+)
+
+//How many due emails a dispatcher run fetches at once
+const DISPATCH_BATCH_SIZE = 50
+
+//How long a single email has to be sent before the attempt is given up on.
+//A claimed email is left alone by other dispatchers for that long.
+const SEND_TIMEOUT = time.Minute
+
+//The longest wait between two attempts at sending an email
+const MAX_RETRY_DELAY = time.Hour
+
+//Outbox queues emails in the database instead of sending them.
+//A Dispatcher sends them in the background, so an email is not lost when the mail server can't be reached.
+type Outbox struct {
+	db models.OutboxStore
+}
+
+func NewOutbox(db models.OutboxStore) *Outbox {
+	return &Outbox{db: db}
+}
+
+func (o *Outbox) Send(ctx context.Context, m Message) error {
+	return o.db.QueueEmail(ctx, &models.Email{To: m.To, Subject: m.Subject, Text: m.Text, HTML: m.HTML})
+}
+
+//Dispatcher periodically sends the emails queued in the outbox with another Mailer.
+//
+//Failed attempts are retried with an exponential backoff, up to models.MAX_EMAIL_ATTEMPTS times.
+//Several dispatchers (one per server instance) can safely run against the same database,
+//every email is claimed with models.OutboxStore.ClaimEmail before it is sent.
+type Dispatcher struct {
+	db       models.OutboxStore
+	mailer   Mailer
+	clock    utils.Clock
+	interval time.Duration
+
+	once sync.Once
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewDispatcher(db models.OutboxStore, mailer Mailer, clock utils.Clock, interval time.Duration) *Dispatcher {
+	return &Dispatcher{db: db, mailer: mailer, clock: clock, interval: interval, done: make(chan struct{})}
+}
+
+//Start runs the dispatcher in the background until Stop is called
+func (d *Dispatcher) Start() {
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := d.DeliverDueEmails(context.Background()); err != nil {
+				log.Println(err)
+			}
+
+			select {
+			case <-d.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+//Stop waits for the current run, if any, to finish and stops the dispatcher
+func (d *Dispatcher) Stop() {
+	d.once.Do(func() {
+		close(d.done)
+	})
+
+	d.wg.Wait()
+}
+
+//DeliverDueEmails sends every email that is due and reports how many were sent.
+//An email that can't be sent is scheduled for another attempt, emails claimed by another dispatcher are skipped.
+func (d *Dispatcher) DeliverDueEmails(ctx context.Context) (int, error) {
+
+	emails, err := d.db.FindDueEmails(ctx, d.clock.Now(), DISPATCH_BATCH_SIZE)
+
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+
+	for _, e := range emails {
+		err := d.db.ClaimEmail(ctx, e, d.clock.Now().Add(SEND_TIMEOUT))
+
+		if err == models.ErrEmailClaimed {
+			continue
+		}
+
+		if err != nil {
+			return sent, err
+		}
+
+		if err := d.send(ctx, e); err != nil {
+			log.Printf("Could not send email %d to %s (attempt %d): %v", e.ID, e.To, e.Attempts+1, err)
+
+			if err := d.db.MarkEmailFailed(ctx, e.ID, err.Error(), d.clock.Now().Add(retryDelay(e.Attempts+1))); err != nil {
+				return sent, err
+			}
+
+			continue
+		}
+
+		if err := d.db.MarkEmailSent(ctx, e.ID, d.clock.Now()); err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, e models.Email) error {
+	ctx, cancel := context.WithTimeout(ctx, SEND_TIMEOUT)
+	defer cancel()
+
+	return d.mailer.Send(ctx, Message{To: e.To, Subject: e.Subject, Text: e.Text, HTML: e.HTML})
+}
+
+//retryDelay is how long to wait before trying an email again after its nth failed attempt.
+//It doubles from a minute on every attempt, up to MAX_RETRY_DELAY
+func retryDelay(attempts int) time.Duration {
+
+	delay := time.Minute
+
+	for i := 1; i < attempts && delay < MAX_RETRY_DELAY; i++ {
+		delay *= 2
+	}
+
+	if delay > MAX_RETRY_DELAY {
+		return MAX_RETRY_DELAY
+	}
+
+	return delay
+}
@@ -0,0 +1,158 @@
+package mailer
+
+import (
+	"context"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+var now = time.Date(2017, time.February, 1, 9, 0, 0, 0, time.UTC)
+
+//failingMailer fails to send to the addresses in fail and keeps the messages it sent
+type failingMailer struct {
+	fail map[string]bool
+	sent []Message
+}
+
+func (f *failingMailer) Send(ctx context.Context, m Message) error {
+	if f.fail[m.To] {
+		return errors.New("Connection refused")
+	}
+
+	f.sent = append(f.sent, m)
+
+	return nil
+}
+
+func TestTheOutboxQueuesEmails(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("QueueEmail", mock.Anything, &models.Email{To: "hades@reblog.io", Subject: "Hello", Text: "Hello Hades", HTML: "<p>Hello Hades</p>"}).
+		Once().Return(nil)
+
+	err := NewOutbox(db).Send(context.Background(), Message{To: "hades@reblog.io", Subject: "Hello", Text: "Hello Hades", HTML: "<p>Hello Hades</p>"})
+
+	assert.NoError(t, err)
+
+	db.AssertExpectations(t)
+}
+
+func TestDueEmailsAreDelivered(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	first := models.Email{ID: 1, To: "hades@reblog.io", Subject: "Hello", Text: "Hello Hades"}
+	second := models.Email{ID: 2, To: "zeus@reblog.io", Subject: "Hello", Text: "Hello Zeus", Attempts: 3}
+
+	db.On("FindDueEmails", mock.Anything, now, DISPATCH_BATCH_SIZE).Once().Return([]models.Email{first, second}, nil)
+	db.On("ClaimEmail", mock.Anything, first, now.Add(SEND_TIMEOUT)).Once().Return(nil)
+	db.On("ClaimEmail", mock.Anything, second, now.Add(SEND_TIMEOUT)).Once().Return(nil)
+	db.On("MarkEmailSent", mock.Anything, 1, now).Once().Return(nil)
+	db.On("MarkEmailFailed", mock.Anything, 2, "Connection refused", now.Add(8*time.Minute)).Once().Return(nil)
+
+	m := &failingMailer{fail: map[string]bool{"zeus@reblog.io": true}}
+
+	count, err := NewDispatcher(db, m, fixedClock{now}, time.Minute).DeliverDueEmails(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []Message{{To: "hades@reblog.io", Subject: "Hello", Text: "Hello Hades"}}, m.sent)
+
+	db.AssertExpectations(t)
+}
+
+func TestEmailsClaimedByAnotherDispatcherAreSkipped(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	first := models.Email{ID: 1, To: "hades@reblog.io"}
+	second := models.Email{ID: 2, To: "zeus@reblog.io"}
+
+	db.On("FindDueEmails", mock.Anything, now, DISPATCH_BATCH_SIZE).Once().Return([]models.Email{first, second}, nil)
+	db.On("ClaimEmail", mock.Anything, first, now.Add(SEND_TIMEOUT)).Once().Return(models.ErrEmailClaimed)
+	db.On("ClaimEmail", mock.Anything, second, now.Add(SEND_TIMEOUT)).Once().Return(nil)
+	db.On("MarkEmailSent", mock.Anything, 2, now).Once().Return(nil)
+
+	m := &failingMailer{}
+
+	count, err := NewDispatcher(db, m, fixedClock{now}, time.Minute).DeliverDueEmails(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Len(t, m.sent, 1)
+
+	db.AssertExpectations(t)
+}
+
+func TestDeliveryStopsOnError(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindDueEmails", mock.Anything, now, DISPATCH_BATCH_SIZE).Once().Return(nil, errors.New("Could not fetch due emails"))
+
+	count, err := NewDispatcher(db, &failingMailer{}, fixedClock{now}, time.Minute).DeliverDueEmails(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestRetriesBackOffExponentially(t *testing.T) {
+
+	assert.Equal(t, time.Minute, retryDelay(1))
+	assert.Equal(t, 2*time.Minute, retryDelay(2))
+	assert.Equal(t, 32*time.Minute, retryDelay(6))
+	assert.Equal(t, MAX_RETRY_DELAY, retryDelay(7))
+	assert.Equal(t, MAX_RETRY_DELAY, retryDelay(models.MAX_EMAIL_ATTEMPTS))
+}
+
+func TestTheDispatcherStopsCleanly(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	ran := make(chan struct{}, 1)
+
+	db.On("FindDueEmails", mock.Anything, now, DISPATCH_BATCH_SIZE).Return([]models.Email{}, nil).Run(func(args mock.Arguments) {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+	})
+
+	d := NewDispatcher(db, &failingMailer{}, fixedClock{now}, time.Millisecond)
+
+	d.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("The dispatcher never ran")
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		d.Stop()
+		d.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("The dispatcher did not stop")
+	}
+}
@@ -0,0 +1,212 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+//SMTPMailer sends emails through an SMTP server.
+//The connection is upgraded with STARTTLS whenever the server supports it.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+//NewSMTPMailer sends emails from the given address through the server at addr (host:port).
+//auth may be nil for servers that don't require authentication
+func NewSMTPMailer(addr, from string, auth smtp.Auth) *SMTPMailer {
+	return &SMTPMailer{addr: addr, from: from, auth: auth}
+}
+
+func (s *SMTPMailer) Send(ctx context.Context, m Message) error {
+
+	from, err := mail.ParseAddress(s.from)
+
+	if err != nil {
+		return errors.Wrap(err, "Invalid sender address")
+	}
+
+	to, err := mail.ParseAddress(m.To)
+
+	if err != nil {
+		return errors.Wrap(err, "Invalid recipient address")
+	}
+
+	body, err := buildMessage(from, to, m, time.Now())
+
+	if err != nil {
+		return errors.Wrap(err, "Could not build the email")
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not connect to the SMTP server")
+	}
+
+	//The whole exchange has to fit in the context's deadline, if it has one
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, _ := net.SplitHostPort(s.addr)
+
+	c, err := smtp.NewClient(conn, host)
+
+	if err != nil {
+		conn.Close()
+		return errors.Wrap(err, "Could not connect to the SMTP server")
+	}
+
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return errors.Wrap(err, "Could not start TLS")
+		}
+	}
+
+	if s.auth != nil {
+		if err = c.Auth(s.auth); err != nil {
+			return errors.Wrap(err, "Could not authenticate with the SMTP server")
+		}
+	}
+
+	if err = c.Mail(from.Address); err != nil {
+		return errors.Wrap(err, "The SMTP server refused the sender")
+	}
+
+	if err = c.Rcpt(to.Address); err != nil {
+		return errors.Wrap(err, "The SMTP server refused the recipient")
+	}
+
+	w, err := c.Data()
+
+	if err != nil {
+		return errors.Wrap(err, "The SMTP server refused the email")
+	}
+
+	if _, err = w.Write(body); err != nil {
+		return errors.Wrap(err, "Could not send the email")
+	}
+
+	if err = w.Close(); err != nil {
+		return errors.Wrap(err, "The SMTP server refused the email")
+	}
+
+	return c.Quit()
+}
+
+//buildMessage encodes m as a MIME email.
+//Messages with an HTML body are sent as multipart/alternative, with the text first as clients prefer the last part they can display.
+func buildMessage(from, to *mail.Address, m Message, date time.Time) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	header := make(textproto.MIMEHeader)
+
+	header.Set("From", from.String())
+	header.Set("To", to.String())
+	header.Set("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header.Set("Date", date.Format(time.RFC1123Z))
+	header.Set("Message-ID", messageID(from.Address))
+	header.Set("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		writeHeader(&buf, header)
+
+		if err := writeQuotedPrintable(&buf, m.Text); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	var parts bytes.Buffer
+
+	mw := multipart.NewWriter(&parts)
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{{"text/plain; charset=utf-8", m.Text}, {"text/html; charset=utf-8", m.HTML}} {
+
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err = writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	header.Set("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+
+	writeHeader(&buf, header)
+	buf.Write(parts.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+func writeHeader(w io.Writer, header textproto.MIMEHeader) {
+	for _, key := range []string{"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"} {
+		if val := header.Get(key); val != "" {
+			fmt.Fprintf(w, "%s: %s\r\n", key, val)
+		}
+	}
+
+	io.WriteString(w, "\r\n")
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	//Line breaks are written as CRLF, as SMTP expects
+	qp := quotedprintable.NewWriter(w)
+
+	if _, err := io.WriteString(qp, s); err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
+
+func messageID(from string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	domain := "localhost"
+
+	if i := strings.LastIndex(from, "@"); i != -1 {
+		domain = from[i+1:]
+	}
+
+	return fmt.Sprintf("<%x@%s>", b, domain)
+}
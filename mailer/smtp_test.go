@@ -0,0 +1,278 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//received is an email as the fake SMTP server got it
+type received struct {
+	from string
+	to   []string
+	data string
+}
+
+//fakeSMTPServer is an SMTP server that keeps the emails it receives.
+//It refuses every recipient when rejectRecipients is set.
+type fakeSMTPServer struct {
+	l                net.Listener
+	rejectRecipients bool
+
+	mu     sync.Mutex
+	emails []received
+	wg     sync.WaitGroup
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeSMTPServer{l: l}
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			conn, err := l.Accept()
+
+			if err != nil {
+				return
+			}
+
+			s.wg.Add(1)
+
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeSMTPServer) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *fakeSMTPServer) close() {
+	s.l.Close()
+	s.wg.Wait()
+}
+
+func (s *fakeSMTPServer) received() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]received(nil), s.emails...)
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var current received
+
+	reply("220 localhost ESMTP fake")
+
+	for {
+		line, err := r.ReadLine()
+
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case "MAIL":
+			current = received{from: strings.Fields(line[len("MAIL FROM:"):])[0]}
+			reply("250 OK")
+		case "RCPT":
+			if s.rejectRecipients {
+				reply("550 No such user")
+				continue
+			}
+
+			current.to = append(current.to, line[len("RCPT TO:"):])
+			reply("250 OK")
+		case "DATA":
+			reply("354 Go ahead")
+
+			data, err := r.ReadDotBytes()
+
+			if err != nil {
+				return
+			}
+
+			current.data = string(data)
+
+			s.mu.Lock()
+			s.emails = append(s.emails, current)
+			s.mu.Unlock()
+
+			reply("250 Queued")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func TestTheSMTPMailerSendsTextAndHTML(t *testing.T) {
+
+	s := newFakeSMTPServer(t)
+	defer s.close()
+
+	m := NewSMTPMailer(s.addr(), "Reblog <noreply@reblog.io>", nil)
+
+	err := m.Send(context.Background(), Message{
+		To:      "hades@reblog.io",
+		Subject: "Welcome to Réblog",
+		Text:    "Hello Hades\nFollow https://reblog.io/signup/token",
+		HTML:    `<p>Hello Hades</p><p><a href="https://reblog.io/signup/token">Sign up</a></p>`,
+	})
+
+	assert.NoError(t, err)
+
+	emails := s.received()
+
+	if len(emails) != 1 {
+		t.Fatalf("Expected 1 email. Got %d", len(emails))
+	}
+
+	assert.Equal(t, "<noreply@reblog.io>", emails[0].from)
+	assert.Equal(t, []string{"<hades@reblog.io>"}, emails[0].to)
+
+	msg, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome to Réblog", subject)
+	assert.Equal(t, `"Reblog" <noreply@reblog.io>`, msg.Header.Get("From"))
+	assert.Equal(t, "<hades@reblog.io>", msg.Header.Get("To"))
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	var parts []string
+
+	for {
+		part, err := mr.NextPart()
+
+		if err != nil {
+			break
+		}
+
+		//The quoted-printable encoding, CRLF line breaks included, is undone by the reader
+		body, _ := ioutil.ReadAll(part)
+
+		parts = append(parts, part.Header.Get("Content-Type")+"\n"+string(body))
+	}
+
+	assert.Equal(t, []string{
+		"text/plain; charset=utf-8\nHello Hades\nFollow https://reblog.io/signup/token",
+		"text/html; charset=utf-8\n" + `<p>Hello Hades</p><p><a href="https://reblog.io/signup/token">Sign up</a></p>`,
+	}, parts)
+}
+
+func TestTheSMTPMailerSendsPlainTextWithoutHTML(t *testing.T) {
+
+	s := newFakeSMTPServer(t)
+	defer s.close()
+
+	m := NewSMTPMailer(s.addr(), "noreply@reblog.io", nil)
+
+	assert.NoError(t, m.Send(context.Background(), Message{To: "hades@reblog.io", Subject: "Hello", Text: "Hello Hades"}))
+
+	emails := s.received()
+
+	if len(emails) != 1 {
+		t.Fatalf("Expected 1 email. Got %d", len(emails))
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(emails[0].data))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(msg.Body)
+
+	assert.Equal(t, "text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+	assert.Equal(t, "Hello Hades", strings.TrimSpace(string(body)))
+}
+
+func TestTheSMTPMailerFailsWhenTheServerRefusesTheRecipient(t *testing.T) {
+
+	s := newFakeSMTPServer(t)
+	s.rejectRecipients = true
+	defer s.close()
+
+	m := NewSMTPMailer(s.addr(), "noreply@reblog.io", nil)
+
+	err := m.Send(context.Background(), Message{To: "nobody@reblog.io", Subject: "Hello", Text: "Hello"})
+
+	assert.Error(t, err)
+	assert.Empty(t, s.received())
+}
+
+func TestTheSMTPMailerFailsWhenTheServerIsUnavailable(t *testing.T) {
+
+	s := newFakeSMTPServer(t)
+	addr := s.addr()
+	s.close()
+
+	m := NewSMTPMailer(addr, "noreply@reblog.io", nil)
+
+	assert.Error(t, m.Send(context.Background(), Message{To: "hades@reblog.io", Subject: "Hello", Text: "Hello"}))
+}
+
+func TestTheSMTPMailerRefusesInvalidAddresses(t *testing.T) {
+
+	s := newFakeSMTPServer(t)
+	defer s.close()
+
+	assert.Error(t, NewSMTPMailer(s.addr(), "not an address", nil).Send(context.Background(), Message{To: "hades@reblog.io"}))
+	assert.Error(t, NewSMTPMailer(s.addr(), "noreply@reblog.io", nil).Send(context.Background(), Message{To: "hades"}))
+	assert.Empty(t, s.received())
+}
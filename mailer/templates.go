@@ -0,0 +1,75 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/pkg/errors"
+	html "html/template"
+	text "text/template"
+	"time"
+)
+
+//Every email has a text template, templates/<name>.txt, and optionally an HTML one, templates/<name>.html
+//
+//go:embed templates/*
+var templates embed.FS
+
+var funcs = map[string]interface{}{"duration": humanDuration}
+
+var (
+	textTemplates = text.Must(text.New("").Funcs(text.FuncMap(funcs)).ParseFS(templates, "templates/*.txt"))
+	htmlTemplates = html.Must(html.New("").Funcs(html.FuncMap(funcs)).ParseFS(templates, "templates/*.html"))
+)
+
+//Render builds the message to send to the given address from the templates called name.
+//data is given to both templates. The HTML one escapes it.
+func Render(to, subject, name string, data interface{}) (Message, error) {
+
+	m := Message{To: to, Subject: subject}
+
+	var buf bytes.Buffer
+
+	if err := textTemplates.ExecuteTemplate(&buf, name+".txt", data); err != nil {
+		return m, errors.Wrap(err, "Could not render the email")
+	}
+
+	m.Text = buf.String()
+
+	if htmlTemplates.Lookup(name+".html") == nil {
+		return m, nil
+	}
+
+	buf.Reset()
+
+	if err := htmlTemplates.ExecuteTemplate(&buf, name+".html", data); err != nil {
+		return m, errors.Wrap(err, "Could not render the email")
+	}
+
+	m.HTML = buf.String()
+
+	return m, nil
+}
+
+//humanDuration writes d the way it would be in a sentence, e.g "1 hour" or "20 minutes"
+func humanDuration(d time.Duration) string {
+
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return plural(int64(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	case d >= time.Minute && d%time.Minute == 0:
+		return plural(int64(d/time.Minute), "minute")
+	}
+
+	return d.String()
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTheInvitationTemplatesContainTheSignUpLink(t *testing.T) {
+
+	m, err := Render("hades@reblog.io", "Join Reblog", "invitation", struct {
+		Site      string
+		Link      string
+		ExpiresIn time.Duration
+	}{"Reblog", "https://reblog.io/signup/token?a=1&b=2", 20 * time.Minute})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hades@reblog.io", m.To)
+	assert.Equal(t, "Join Reblog", m.Subject)
+
+	assert.Contains(t, m.Text, "https://reblog.io/signup/token?a=1&b=2")
+	assert.Contains(t, m.Text, "within 20 minutes")
+
+	//The HTML template escapes the link
+	assert.Contains(t, m.HTML, `<a href="https://reblog.io/signup/token?a=1&amp;b=2">`)
+	assert.False(t, strings.Contains(m.HTML, "a=1&b=2"))
+}
+
+func TestRenderingAnUnknownTemplateFails(t *testing.T) {
+
+	_, err := Render("hades@reblog.io", "Hello", "unknown", nil)
+
+	assert.Error(t, err)
+}
+
+func TestDurationsAreWrittenForPeople(t *testing.T) {
+
+	for d, expected := range map[time.Duration]string{
+		time.Minute:      "1 minute",
+		20 * time.Minute: "20 minutes",
+		time.Hour:        "1 hour",
+		48 * time.Hour:   "2 days",
+		90 * time.Minute: "90 minutes",
+		time.Second:      "1s",
+	} {
+		assert.Equal(t, expected, humanDuration(d))
+	}
+}
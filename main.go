@@ -1,27 +1,121 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"github.com/adelowo/reblog/handler"
+	"github.com/adelowo/reblog/mailer"
 	m "github.com/adelowo/reblog/middleware"
+	"github.com/adelowo/reblog/migrations"
 	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/publisher"
 	"github.com/adelowo/reblog/utils"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/goware/jwtauth"
 	"github.com/pressly/chi"
 	"github.com/pressly/chi/middleware"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 )
 
+//Used when REBLOG_DATABASE_URL isn't set
 const DATABASE_NAME = "reblog.db"
 
+//How often scheduled posts are checked for publishing
+const PUBLISHER_INTERVAL = time.Minute
+
+//How often the outbox is checked for emails to send
+const DISPATCHER_INTERVAL = 30 * time.Second
+
 func main() {
 
-	db := models.MustNewDB(DATABASE_NAME)
+	//A postgres:// url runs reblog on PostgreSQL, anything else is the path to a SQLite database
+	db := models.MustNewDB(getenv("REBLOG_DATABASE_URL", DATABASE_NAME))
+
+	pool, err := poolOptions()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db.Configure(pool)
+
+	source, err := migrations.Source(db.DriverName())
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrator, err := migrations.New(db.DB, source)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(migrator, os.Args[2:], os.Stdout))
+	}
+
+	//Refuses to start if the database has been migrated by a newer release
+	applied, err := migrator.Up()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+
+	keys, err := signingKeys()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	jwtGenerator, err := utils.NewJWTGenerator(keys...)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//Access tokens revoked on logout are refused until they expire, those of suspended users until they are reactivated
+	jwtGenerator.UseDenylist(db)
+
+	site := handler.Site{
+		Title:       getenv("REBLOG_SITE_TITLE", "Reblog"),
+		Description: getenv("REBLOG_SITE_DESCRIPTION", "Some simple blog built in Go"),
+		URL:         getenv("REBLOG_BASE_URL", "http://localhost:3000"),
+		AuthorName:  os.Getenv("REBLOG_AUTHOR_NAME"),
+		AuthorEmail: os.Getenv("REBLOG_AUTHOR_EMAIL"),
+	}
+
+	if robots := os.Getenv("REBLOG_ROBOTS_FILE"); robots != "" {
+		contents, err := ioutil.ReadFile(robots)
+
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	jwtGenerator := utils.NewJWTGenerator()
+		site.Robots = string(contents)
+	}
 
-	h := &handler.Handler{DB: db, JWT: jwtGenerator, Slug: utils.Slug{}}
+	transport, err := mailTransport()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//Emails are queued in the outbox and sent in the background, so they are retried when the mail server is down
+	h := &handler.Handler{DB: db, JWT: jwtGenerator, Slug: utils.Slug{}, Markdown: utils.NewMarkdownRenderer(), Site: site, Mailer: mailer.NewOutbox(db)}
 
 	router := chi.NewRouter()
 
@@ -36,9 +130,35 @@ func main() {
 		r.Use(m.Guest)
 		r.Post("/login", handler.PostLogin(h))
 		r.Post("/signup/:token", handler.PostSignUp(h))
+		r.Post("/password/forgot", handler.ForgotPassword(h))
+		r.Post("/password/reset/:token", handler.ResetPassword(h))
 
 	})
 
+	router.Post("/token/refresh", handler.RefreshToken(h))
+	router.With(jwtGenerator.Verifier).Post("/logout", handler.PostLogout(h))
+	router.Post("/email/verify/:token", handler.VerifyEmail(h))
+
+	router.Get("/posts", handler.ListPosts(h))
+	router.Get("/posts/:slug", handler.ShowPost(h))
+	router.Get("/tags", handler.ListTags(h))
+	router.Get("/tags/:slug/posts", handler.ListTagPosts(h))
+	router.Get("/categories", handler.ListCategories(h))
+	router.Get("/search", handler.Search(h))
+
+	router.Get("/.well-known/jwks.json", handler.JWKS(h))
+
+	router.Get("/sitemap.xml", handler.Sitemap(h))
+	router.Get("/robots.txt", handler.Robots(h))
+
+	router.Get("/feed.rss", handler.RSSFeed(h))
+	router.Get("/feed.atom", handler.AtomFeed(h))
+	router.Get("/feed.json", handler.JSONFeed(h))
+	router.Get("/authors/:moniker", handler.ShowAuthor(h))
+	router.Get("/authors/:moniker/feed.rss", handler.RSSFeed(h))
+	router.Get("/authors/:moniker/feed.atom", handler.AtomFeed(h))
+	router.Get("/authors/:moniker/feed.json", handler.JSONFeed(h))
+
 	router.Group(func(r chi.Router) {
 
 		r.Route("/reblog", func(ro chi.Router) {
@@ -46,26 +166,203 @@ func main() {
 			ro.Use(jwtGenerator.Verifier)
 			ro.Use(jwtauth.Authenticator)
 
+			ro.Route("/me", func(roo chi.Router) {
+
+				roo.Get("/", handler.ShowMe(h))
+				roo.Patch("/", handler.UpdateMe(h))
+				roo.Post("/password", handler.ChangePassword(h))
+				roo.Post("/email", handler.ChangeEmail(h))
+			})
+
 			ro.Route("/collaborator", func(roo chi.Router) {
 
-				roo.Use(m.Admin)
+				roo.With(m.Require(models.USER_INVITE)).Post("/create", handler.CreateCollaborator(h))
+				roo.With(m.Require(models.USER_MANAGE)).Post("/delete", handler.DeleteCollaborator(h))
+				roo.With(m.Require(models.USER_INVITE)).Get("/invites", handler.ListInvitations(h))
+				roo.With(m.Require(models.USER_INVITE)).Post("/invites/:id/resend", handler.ResendInvitation(h))
+				roo.With(m.Require(models.USER_INVITE)).Delete("/invites/:id", handler.RevokeInvitation(h))
+			})
+
+			ro.Route("/users", func(roo chi.Router) {
+
+				roo.Use(m.Require(models.USER_MANAGE))
+
+				roo.Get("/", handler.ListUsers(h))
+				roo.Get("/:id", handler.ShowUser(h))
+				roo.Put("/:id/role", handler.UpdateUserRole(h))
+				roo.Post("/:id/suspend", handler.SuspendUser(h))
+				roo.Post("/:id/reactivate", handler.ReactivateUser(h))
+				roo.Delete("/:id", handler.DeleteUser(h))
+			})
+
+			ro.Route("/roles", func(roo chi.Router) {
+
+				roo.Use(m.Require(models.ROLE_MANAGE))
+
+				roo.Get("/", handler.ListRoles(h))
+				roo.Put("/:name", handler.UpdateRole(h))
+			})
+
+			ro.Route("/tags", func(roo chi.Router) {
+
+				roo.Use(m.Require(models.TAXONOMY_MANAGE))
 
-				roo.Post("/create", handler.CreateCollaborator(h))
-				roo.Post("/delete", handler.DeleteCollaborator(h))
+				roo.Patch("/:slug", handler.RenameTag(h))
+				roo.Post("/:slug/merge", handler.MergeTag(h))
+				roo.Delete("/:slug", handler.DeleteTag(h))
 			})
 
+			ro.With(m.Require(models.TAXONOMY_MANAGE)).Post("/categories", handler.CreateCategory(h))
+
+			ro.Get("/search", handler.SearchDrafts(h))
+
 			ro.Route("/posts", func(roo chi.Router) {
 
-				roo.Post("/create", handler.CreatePost(h))
+				roo.With(m.Require(models.POST_CREATE)).Post("/create", handler.CreatePost(h))
+				roo.Patch("/:id", handler.UpdatePost(h))
+				roo.Get("/:id/revisions", handler.ListRevisions(h))
+				roo.Get("/:id/revisions/diff", handler.DiffRevisions(h))
+				roo.Post("/:id/revisions/:revision/restore", handler.RestoreRevision(h))
+
+				roo.Post("/:id/submit", handler.SubmitPost(h))
 
-				roo.With(m.Admin)
-				roo.Delete("/:id", handler.DeletePost(h))
-				roo.Put("/:id", handler.UnpublishPost(h))
+				roo.With(m.Require(models.POST_REVIEW)).Get("/review", handler.ReviewQueue(h))
+				roo.With(m.Require(models.POST_REVIEW)).Post("/:id/approve", handler.ApprovePost(h))
+				roo.With(m.Require(models.POST_REVIEW)).Post("/:id/reject", handler.RejectPost(h))
+				roo.With(m.Require(models.POST_REVIEW)).Post("/:id/archive", handler.ArchivePost(h))
+				roo.With(m.Require(models.POST_DELETE_ANY)).Delete("/:id", handler.DeletePost(h))
+				roo.With(m.Require(models.POST_UNPUBLISH)).Put("/:id", handler.UnpublishPost(h))
 			})
 		})
 
 	})
 
-	log.Println("Starting app")
-	http.ListenAndServe(":3000", router)
+	pub := publisher.New(db, utils.NewSystemClock(), PUBLISHER_INTERVAL)
+	pub.Start()
+
+	dispatcher := mailer.NewDispatcher(db, transport, utils.NewSystemClock(), DISPATCHER_INTERVAL)
+	dispatcher.Start()
+
+	srv := &http.Server{Addr: ":3000", Handler: router}
+
+	go func() {
+		log.Println("Starting app")
+
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	<-stop
+
+	log.Println("Shutting down")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println(err)
+	}
+
+	pub.Stop()
+	dispatcher.Stop()
+
+	if err := db.Close(); err != nil {
+		log.Println(err)
+	}
+}
+
+//getenv reads a setting from the environment, falling back to a default when it isn't set
+func getenv(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+
+	return fallback
+}
+
+//poolOptions reads the connection pool's settings from the environment
+func poolOptions() (models.PoolOptions, error) {
+	var o models.PoolOptions
+	var err error
+
+	if val := os.Getenv("REBLOG_DB_MAX_OPEN_CONNS"); val != "" {
+		if o.MaxOpenConns, err = strconv.Atoi(val); err != nil {
+			return o, fmt.Errorf("Invalid REBLOG_DB_MAX_OPEN_CONNS %q", val)
+		}
+	}
+
+	if val := os.Getenv("REBLOG_DB_MAX_IDLE_CONNS"); val != "" {
+		if o.MaxIdleConns, err = strconv.Atoi(val); err != nil {
+			return o, fmt.Errorf("Invalid REBLOG_DB_MAX_IDLE_CONNS %q", val)
+		}
+	}
+
+	if val := os.Getenv("REBLOG_DB_CONN_MAX_LIFETIME"); val != "" {
+		if o.ConnMaxLifetime, err = time.ParseDuration(val); err != nil {
+			return o, fmt.Errorf("Invalid REBLOG_DB_CONN_MAX_LIFETIME %q", val)
+		}
+	}
+
+	return o, nil
+}
+
+//signingKeys reads the keys access tokens are signed with.
+//REBLOG_JWT_KEYS lists them along with when each one starts signing,
+//otherwise the secret in JWT is used as the only HS256 key.
+//Reblog won't start without any.
+func signingKeys() ([]utils.SigningKey, error) {
+
+	if path := os.Getenv("REBLOG_JWT_KEYS"); path != "" {
+		return utils.LoadSigningKeys(path)
+	}
+
+	if secret := os.Getenv("JWT"); secret != "" {
+		return []utils.SigningKey{{ID: "default", Method: jwt.SigningMethodHS256, Key: []byte(secret)}}, nil
+	}
+
+	return nil, fmt.Errorf("No signing key is configured. Set REBLOG_JWT_KEYS or JWT")
+}
+
+//mailTransport is how emails are sent.
+//They go through the SMTP server at REBLOG_SMTP_ADDR when it is set. Otherwise they are written to
+//REBLOG_MAIL_FILE, or to the log, so links in them can still be followed in development.
+func mailTransport() (mailer.Mailer, error) {
+
+	if addr := os.Getenv("REBLOG_SMTP_ADDR"); addr != "" {
+		from := os.Getenv("REBLOG_MAIL_FROM")
+
+		if from == "" {
+			return nil, fmt.Errorf("REBLOG_MAIL_FROM is required to send emails through %s", addr)
+		}
+
+		var auth smtp.Auth
+
+		if username := os.Getenv("REBLOG_SMTP_USERNAME"); username != "" {
+			host, _, err := net.SplitHostPort(addr)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid REBLOG_SMTP_ADDR %q", addr)
+			}
+
+			auth = smtp.PlainAuth("", username, os.Getenv("REBLOG_SMTP_PASSWORD"), host)
+		}
+
+		return mailer.NewSMTPMailer(addr, from, auth), nil
+	}
+
+	if path := os.Getenv("REBLOG_MAIL_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return mailer.NewLogMailer(f), nil
+	}
+
+	return mailer.NewLogMailer(os.Stderr), nil
 }
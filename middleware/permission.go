@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pressly/chi/render"
+	"net/http"
+)
+
+//Require protects routes from users whose role doesn't have the permission.
+//The permissions of the user's role are stored in the token when they log in.
+func Require(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if _, ok := claims(r); !ok {
+				http.Error(w, http.StatusText(401), 401)
+				return
+			}
+
+			if Can(r, permission) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sendFailureResponse(w, r)
+		})
+	}
+}
+
+//Can tells if the user making the request has the permission
+func Can(r *http.Request, permission string) bool {
+
+	c, ok := claims(r)
+
+	if !ok {
+		return false
+	}
+
+	//Numbers and arrays come out of a verified token as float64 and []interface{}
+	switch permissions := c["permissions"].(type) {
+	case []interface{}:
+		for _, p := range permissions {
+			if p == permission {
+				return true
+			}
+		}
+	case []string:
+		for _, p := range permissions {
+			if p == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//CanOwn tells if the user making the request has the permission and is the owner of the resource.
+//It is meant for :own permissions, like being allowed to edit the posts you wrote.
+func CanOwn(r *http.Request, permission string, ownerID int) bool {
+
+	c, ok := claims(r)
+
+	if !ok {
+		return false
+	}
+
+	userID, ok := c["userID"].(float64)
+
+	return ok && int(userID) == ownerID && Can(r, permission)
+}
+
+func claims(r *http.Request) (map[string]interface{}, bool) {
+
+	jwtToken, ok := r.Context().Value("jwt").(*jwt.Token)
+
+	if !ok || jwtToken == nil || !jwtToken.Valid {
+		return nil, false
+	}
+
+	return jwtToken.Claims, true
+}
+
+func sendFailureResponse(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusUnauthorized)
+
+	d := struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}{
+		false,
+		"You do not have permission to view this resource",
+	}
+
+	render.JSON(w, r, d)
+
+	return
+
+}
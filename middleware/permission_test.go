@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/utils"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func tokenFor(t *testing.T, JWT *utils.JWTTokenGenerator, user models.User, permissions []string) string {
+
+	claims := make(map[string]interface{}, 4)
+
+	claims["userID"] = user.ID
+	claims["moniker"] = user.Moniker
+	claims["role"] = user.Role
+	claims["permissions"] = permissions
+
+	token, err := JWT.Generate(strconv.Itoa(user.ID), claims)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestRequire(t *testing.T) {
+	JWT := utils.MustNewJWTGenerator(utils.SigningKey{ID: "test", Method: jwt.SigningMethodHS256, Key: []byte("secret")})
+
+	user := models.User{ID: 1, Moniker: "hades", Role: models.ADMIN}
+
+	req, err := http.NewRequest("GET", "/reblog/collaborator/create", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+tokenFor(t, JWT, user, []string{models.POST_CREATE, models.USER_INVITE}))
+
+	rr := httptest.NewRecorder()
+
+	JWT.Verifier(Require(models.USER_INVITE)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Created a new user"))
+	}))).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatal(status)
+	}
+
+	if !bytes.Equal([]byte("Created a new user"), rr.Body.Bytes()) {
+		t.Fatal("Response body differ")
+	}
+
+	testPreventsUsersWithoutThePermissionFromAccessingThisEndpoint(JWT, t)
+}
+
+func testPreventsUsersWithoutThePermissionFromAccessingThisEndpoint(JWT *utils.JWTTokenGenerator, t *testing.T) {
+	user := models.User{ID: 4, Moniker: "alcheme", Role: models.CONTRIBUTOR}
+
+	req, err := http.NewRequest("GET", "/reblog/collaborator/create", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+tokenFor(t, JWT, user, []string{models.POST_CREATE, models.POST_EDIT_OWN}))
+
+	rr := httptest.NewRecorder()
+
+	JWT.Verifier(Require(models.USER_INVITE)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Created a new user"))
+	}))).
+		ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusUnauthorized {
+		t.Fatal(status)
+	}
+
+	expected := string(`{"status":false,"message":"You do not have permission to view this resource"}`)
+
+	assert.JSONEq(t, expected, rr.Body.String(), "Expected json to be equal")
+
+}
+
+func TestCanOwnOnlyAppliesToTheOwner(t *testing.T) {
+	JWT := utils.MustNewJWTGenerator(utils.SigningKey{ID: "test", Method: jwt.SigningMethodHS256, Key: []byte("secret")})
+
+	user := models.User{ID: 4, Moniker: "alcheme", Role: models.CONTRIBUTOR}
+
+	req, err := http.NewRequest("GET", "/reblog/posts/1", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+tokenFor(t, JWT, user, []string{models.POST_EDIT_OWN}))
+
+	var own, other, any bool
+
+	JWT.Verifier(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		own = CanOwn(r, models.POST_EDIT_OWN, 4)
+		other = CanOwn(r, models.POST_EDIT_OWN, 5)
+		any = Can(r, models.POST_EDIT_ANY)
+	})).
+		ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, own)
+	assert.False(t, other)
+	assert.False(t, any)
+
+	//Without a token, nothing is allowed
+	assert.False(t, Can(req, models.POST_EDIT_OWN))
+}
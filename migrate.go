@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"github.com/adelowo/reblog/migrations"
+	"io"
+)
+
+const migrateUsage = "Usage: reblog migrate up|down|status"
+
+//runMigrate implements `reblog migrate up|down|status` and returns the process' exit code
+func runMigrate(m *migrations.Migrator, args []string, out io.Writer) int {
+
+	if len(args) != 1 {
+		fmt.Fprintln(out, migrateUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+
+		for _, migration := range applied {
+			fmt.Fprintf(out, "Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+
+		if len(applied) == 0 {
+			fmt.Fprintln(out, "The database is up to date")
+		}
+	case "down":
+		migration, rolledBack, err := m.Down()
+
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+
+		if !rolledBack {
+			fmt.Fprintln(out, "There is no migration to roll back")
+			return 0
+		}
+
+		fmt.Fprintf(out, "Rolled back %04d_%s\n", migration.Version, migration.Name)
+	case "status":
+		statuses, err := m.Status()
+
+		if err != nil {
+			fmt.Fprintln(out, err)
+			return 1
+		}
+
+		for _, s := range statuses {
+			status := "pending"
+
+			if s.Applied {
+				status = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Fprintf(out, "%04d_%s\t%s\n", s.Version, s.Name, status)
+		}
+	default:
+		fmt.Fprintln(out, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
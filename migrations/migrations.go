@@ -0,0 +1,391 @@
+//Package migrations keeps the database schema up to date.
+//Migrations are embedded in the binary as NNNN_name.up.sql and NNNN_name.down.sql files,
+//one directory per database driver, and the versions applied to a database are tracked in its schema_migrations table.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
+
+//The directory in FS holding the migrations for each database driver
+var directories = map[string]string{
+	"sqlite3":  "sqlite",
+	"postgres": "postgres",
+}
+
+//ErrDatabaseAhead is returned when the database has migrations applied that this binary doesn't know about.
+//This usually means an older release is being run against a database upgraded by a newer one.
+var ErrDatabaseAhead = errors.New("The database schema is newer than this version of reblog")
+
+//ErrNoDownMigration is returned when rolling back a migration that can't be undone
+var ErrNoDownMigration = errors.New("The migration cannot be rolled back")
+
+var filename = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+//Status tells whether a migration has been applied to the database and when
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+//Source returns the embedded migrations written for the database driver
+func Source(driver string) (fs.FS, error) {
+	dir, ok := directories[driver]
+
+	if !ok {
+		return nil, fmt.Errorf("There are no migrations for the %s driver", driver)
+	}
+
+	return fs.Sub(FS, dir)
+}
+
+//New reads the migrations in source, which is usually what Source returns.
+//Every migration needs an up file, the down file is optional.
+func New(db *sqlx.DB, source fs.FS) (*Migrator, error) {
+
+	files, err := fs.ReadDir(source, ".")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read migrations")
+	}
+
+	byVersion := make(map[int]*Migration)
+
+	for _, f := range files {
+		matches := filename.FindStringSubmatch(f.Name())
+
+		if f.IsDir() || matches == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(matches[1])
+
+		m, ok := byVersion[version]
+
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("Migration %04d has more than one name: %s and %s", version, m.Name, matches[2])
+		}
+
+		contents, err := fs.ReadFile(source, path.Join(".", f.Name()))
+
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not read migration "+f.Name())
+		}
+
+		if matches[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrator := &Migrator{db: db}
+
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("Migration %04d_%s has no up migration", m.Version, m.Name)
+		}
+
+		migrator.migrations = append(migrator.migrations, *m)
+	}
+
+	sort.Slice(migrator.migrations, func(i, j int) bool {
+		return migrator.migrations[i].Version < migrator.migrations[j].Version
+	})
+
+	return migrator, nil
+}
+
+//Migrations lists every known migration, oldest first
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+//ensureTable creates the schema_migrations table if the database doesn't have one yet.
+//Databases set up by hand with the db.sql file that came before migrations already have every table of the first migration,
+//which is exactly that file, so it is recorded as applied for them instead of failing on tables that already exist.
+func (m *Migrator) ensureTable() error {
+
+	tracked, err := m.hasTable("schema_migrations")
+
+	if err != nil {
+		return err
+	}
+
+	if tracked {
+		return nil
+	}
+
+	baseline, err := m.hasInitialSchema()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations
+(
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not create the schema_migrations table")
+	}
+
+	if !baseline {
+		return nil
+	}
+
+	_, err = m.db.Exec(m.db.Rebind("INSERT INTO schema_migrations(version, name, applied_at) VALUES(?,?,?)"),
+		m.migrations[0].Version, m.migrations[0].Name, time.Now().UTC())
+
+	return errors.Wrap(err, "Could not record the existing schema as migrated")
+}
+
+var createTable = regexp.MustCompile(`(?i)CREATE (?:VIRTUAL )?TABLE (\w+)`)
+
+//hasInitialSchema reports whether the database already has every table the first migration creates
+func (m *Migrator) hasInitialSchema() (bool, error) {
+
+	if len(m.migrations) == 0 {
+		return false, nil
+	}
+
+	tables := createTable.FindAllStringSubmatch(m.migrations[0].Up, -1)
+
+	if len(tables) == 0 {
+		return false, nil
+	}
+
+	for _, table := range tables {
+		exists, err := m.hasTable(table[1])
+
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+//hasTable reports whether the database has a table with the given name
+func (m *Migrator) hasTable(name string) (bool, error) {
+	var count int
+
+	query := "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema=current_schema() AND table_name=?"
+
+	if m.db.DriverName() == "sqlite3" {
+		query = "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?"
+	}
+
+	if err := m.db.Get(&count, m.db.Rebind(query), name); err != nil {
+		return false, errors.Wrap(err, "Could not look up the "+name+" table")
+	}
+
+	return count > 0, nil
+}
+
+//applied maps the versions applied to the database to when they were applied
+func (m *Migrator) applied() (map[int]time.Time, error) {
+
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+
+	if err := m.db.Select(&rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, errors.Wrap(err, "Could not fetch applied migrations")
+	}
+
+	applied := make(map[int]time.Time, len(rows))
+
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+
+	return applied, nil
+}
+
+//Check returns ErrDatabaseAhead if the database has a migration applied that isn't known to this binary
+func (m *Migrator) Check() error {
+
+	applied, err := m.applied()
+
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int]bool, len(m.migrations))
+
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+
+	for version := range applied {
+		if !known[version] {
+			return ErrDatabaseAhead
+		}
+	}
+
+	return nil
+}
+
+//Up applies every pending migration, oldest first, and returns the ones that were applied.
+//Each migration runs in its own transaction so a failing one leaves the database as it was before it.
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+
+	if err := m.Check(); err != nil {
+		return done, err
+	}
+
+	applied, err := m.applied()
+
+	if err != nil {
+		return done, err
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		err := m.inTransaction(migration, migration.Up, func(tx *sqlx.Tx) error {
+			_, err := tx.Exec(tx.Rebind("INSERT INTO schema_migrations(version, name, applied_at) VALUES(?,?,?)"),
+				migration.Version, migration.Name, time.Now().UTC())
+
+			return err
+		})
+
+		if err != nil {
+			return done, err
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+//Down rolls back the most recently applied migration.
+//It returns false if there was nothing to roll back.
+func (m *Migrator) Down() (Migration, bool, error) {
+	var migration Migration
+
+	if err := m.Check(); err != nil {
+		return migration, false, err
+	}
+
+	applied, err := m.applied()
+
+	if err != nil {
+		return migration, false, err
+	}
+
+	found := false
+
+	for _, known := range m.migrations {
+		if _, ok := applied[known.Version]; ok {
+			migration = known
+			found = true
+		}
+	}
+
+	if !found {
+		return migration, false, nil
+	}
+
+	if migration.Down == "" {
+		return migration, false, ErrNoDownMigration
+	}
+
+	err = m.inTransaction(migration, migration.Down, func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(tx.Rebind("DELETE FROM schema_migrations WHERE version=?"), migration.Version)
+
+		return err
+	})
+
+	return migration, err == nil, err
+}
+
+//Status lists every known migration along with whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	var statuses []Status
+
+	if err := m.Check(); err != nil {
+		return statuses, err
+	}
+
+	applied, err := m.applied()
+
+	if err != nil {
+		return statuses, err
+	}
+
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+
+		statuses = append(statuses, Status{migration, ok, appliedAt})
+	}
+
+	return statuses, nil
+}
+
+//inTransaction runs the migration's sql and then record, committing only if both succeed
+func (m *Migrator) inTransaction(migration Migration, sql string, record func(tx *sqlx.Tx) error) error {
+
+	name := fmt.Sprintf("%04d_%s", migration.Version, migration.Name)
+
+	tx, err := m.db.Beginx()
+
+	if err != nil {
+		return errors.Wrap(err, "Could not start a transaction for migration "+name)
+	}
+
+	if _, err = tx.Exec(sql); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Migration "+name+" failed")
+	}
+
+	if err = record(tx); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "Could not record migration "+name)
+	}
+
+	return errors.Wrap(tx.Commit(), "Could not commit migration "+name)
+}
@@ -0,0 +1,426 @@
+package migrations
+
+import (
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testDB(t *testing.T) *sqlx.DB {
+	db := sqlx.MustConnect("sqlite3", ":memory:")
+
+	//Every connection to :memory: gets its own database
+	db.SetMaxOpenConns(1)
+
+	return db
+}
+
+func testMigrations() fstest.MapFS {
+	return fstest.MapFS{
+		"0002_create_posts.up.sql":   {Data: []byte("CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER NOT NULL);")},
+		"0002_create_posts.down.sql": {Data: []byte("DROP TABLE posts;")},
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);\nCREATE INDEX users_id_index ON users (id);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"README.md":                  {Data: []byte("Not a migration")},
+	}
+}
+
+func tableExists(t *testing.T, db *sqlx.DB, name string) bool {
+	var count int
+
+	if err := db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", name); err != nil {
+		t.Fatal(err)
+	}
+
+	return count == 1
+}
+
+func TestMigrationsAreReadInOrder(t *testing.T) {
+
+	m, err := New(testDB(t), testMigrations())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations := m.Migrations()
+
+	if len(migrations) != 2 {
+		t.Fatalf("Expected 2 migrations. Got %d", len(migrations))
+	}
+
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].Down)
+	assert.Equal(t, 2, migrations[1].Version)
+}
+
+func TestAMigrationNeedsAnUpFile(t *testing.T) {
+
+	source := testMigrations()
+
+	delete(source, "0002_create_posts.up.sql")
+
+	_, err := New(testDB(t), source)
+
+	assert.EqualError(t, err, "Migration 0002_create_posts has no up migration")
+}
+
+func TestTheInitialSchemaIsEmbedded(t *testing.T) {
+
+	for _, driver := range []string{"sqlite3", "postgres"} {
+
+		source, err := Source(driver)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := New(testDB(t), source)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		migrations := m.Migrations()
+
+		if len(migrations) == 0 {
+			t.Fatalf("Expected the embedded %s migrations to be found", driver)
+		}
+
+		assert.Equal(t, 1, migrations[0].Version)
+		assert.Equal(t, "initial", migrations[0].Name)
+		assert.Contains(t, migrations[0].Up, "CREATE TABLE posts")
+		assert.Contains(t, migrations[0].Down, "DROP TABLE posts")
+	}
+}
+
+func TestEveryDriverHasTheSameMigrations(t *testing.T) {
+
+	var versions [][]int
+
+	for _, driver := range []string{"sqlite3", "postgres"} {
+
+		source, err := Source(driver)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := New(testDB(t), source)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var known []int
+
+		for _, migration := range m.Migrations() {
+			known = append(known, migration.Version)
+		}
+
+		versions = append(versions, known)
+	}
+
+	assert.Equal(t, versions[0], versions[1])
+}
+
+func TestThereAreNoMigrationsForUnknownDrivers(t *testing.T) {
+
+	_, err := Source("mysql")
+
+	assert.EqualError(t, err, "There are no migrations for the mysql driver")
+}
+
+func TestPendingMigrationsAreApplied(t *testing.T) {
+
+	db := testDB(t)
+
+	m, err := New(db, testMigrations())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, applied, 2)
+	assert.True(t, tableExists(t, db, "users"))
+	assert.True(t, tableExists(t, db, "posts"))
+
+	applied, err = m.Up()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, applied, 0)
+
+	var versions []int
+
+	if err = db.Select(&versions, "SELECT version FROM schema_migrations ORDER BY version"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []int{1, 2}, versions)
+}
+
+func TestADatabaseSetUpBeforeMigrationsIsBaselined(t *testing.T) {
+
+	db := testDB(t)
+
+	//What running the old db.sql by hand left behind
+	db.MustExec("CREATE TABLE users (id INTEGER PRIMARY KEY);")
+
+	m, err := New(db, testMigrations())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, 2, applied[0].Version)
+	}
+
+	assert.True(t, tableExists(t, db, "posts"))
+
+	var versions []int
+
+	if err = db.Select(&versions, "SELECT version FROM schema_migrations ORDER BY version"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []int{1, 2}, versions)
+}
+
+func TestADatabaseCreatedFromDBSQLIsUpgraded(t *testing.T) {
+
+	db := testDB(t)
+
+	source, err := Source("sqlite3")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(db, source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The first migration is the db.sql file installs were created from
+	db.MustExec(m.Migrations()[0].Up)
+	db.MustExec(`INSERT INTO posts (title, slug, content, status, created_at, updated_at, user_id)
+VALUES ('Go is awesome', 'Go-is-awesome', 'Go is awesome', 1, '2017-05-01 10:00:00.5+01:00', '2017-05-01 10:00:00.5+01:00', 1)`)
+
+	if _, err = m.Up(); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			t.Skip("SQLite was built without FTS5. Run the tests with -tags sqlite_fts5")
+		}
+
+		t.Fatal(err)
+	}
+
+	var post struct {
+		Content   string    `db:"content_markdown"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	if err = db.Get(&post, "SELECT content_markdown, created_at FROM posts"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Go is awesome", post.Content)
+	assert.True(t, time.Date(2017, 5, 1, 9, 0, 0, 5e8, time.UTC).Equal(post.CreatedAt))
+
+	var found int
+
+	if err = db.Get(&found, "SELECT COUNT(*) FROM posts_fts WHERE posts_fts MATCH 'awesome'"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, found)
+}
+
+func TestADatabaseMissingPartOfTheInitialSchemaIsNotBaselined(t *testing.T) {
+
+	db := testDB(t)
+
+	db.MustExec("CREATE TABLE users (id INTEGER PRIMARY KEY);")
+
+	source := testMigrations()
+
+	source["0001_create_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);\nCREATE TABLE sessions (id INTEGER PRIMARY KEY);")}
+
+	m, err := New(db, source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Up()
+
+	assert.Error(t, err)
+	assert.False(t, tableExists(t, db, "sessions"))
+
+	var versions []int
+
+	if err = db.Select(&versions, "SELECT version FROM schema_migrations"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, versions)
+}
+
+func TestAFailingMigrationIsRolledBack(t *testing.T) {
+
+	db := testDB(t)
+
+	source := testMigrations()
+
+	source["0003_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE comments (id INTEGER PRIMARY KEY);\nINSERT INTO nowhere VALUES (1);")}
+
+	m, err := New(db, source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+
+	if err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+
+	assert.Len(t, applied, 2)
+	assert.True(t, tableExists(t, db, "posts"))
+	assert.False(t, tableExists(t, db, "comments"))
+
+	statuses, err := m.Status()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.False(t, statuses[2].Applied)
+}
+
+func TestTheLatestMigrationCanBeRolledBack(t *testing.T) {
+
+	db := testDB(t)
+
+	m, err := New(db, testMigrations())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	migration, rolledBack, err := m.Down()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, rolledBack)
+	assert.Equal(t, 2, migration.Version)
+	assert.False(t, tableExists(t, db, "posts"))
+	assert.True(t, tableExists(t, db, "users"))
+
+	statuses, err := m.Status()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[0].AppliedAt.IsZero())
+	assert.False(t, statuses[1].Applied)
+
+	if _, _, err = m.Down(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, rolledBack, err = m.Down()
+
+	assert.NoError(t, err)
+	assert.False(t, rolledBack)
+}
+
+func TestAMigrationWithoutADownFileCannotBeRolledBack(t *testing.T) {
+
+	db := testDB(t)
+
+	source := testMigrations()
+
+	delete(source, "0002_create_posts.down.sql")
+
+	m, err := New(db, source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = m.Down()
+
+	assert.Equal(t, ErrNoDownMigration, err)
+	assert.True(t, tableExists(t, db, "posts"))
+}
+
+func TestADatabaseAheadOfTheBinaryIsRefused(t *testing.T) {
+
+	db := testDB(t)
+
+	source := testMigrations()
+
+	source["0003_create_comments.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE comments (id INTEGER PRIMARY KEY);")}
+
+	newer, err := New(db, source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = newer.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	older, err := New(db, testMigrations())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, ErrDatabaseAhead, older.Check())
+
+	_, err = older.Up()
+
+	assert.Equal(t, ErrDatabaseAhead, err)
+
+	_, _, err = older.Down()
+
+	assert.Equal(t, ErrDatabaseAhead, err)
+	assert.True(t, tableExists(t, db, "comments"))
+}
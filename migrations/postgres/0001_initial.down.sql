@@ -0,0 +1,3 @@
+DROP TABLE posts;
+DROP TABLE collaborator_tokens;
+DROP TABLE users;
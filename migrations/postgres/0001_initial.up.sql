@@ -0,0 +1,38 @@
+CREATE TABLE users
+(
+    id SERIAL PRIMARY KEY,
+    moniker VARCHAR(255) NOT NULL,
+    type INT DEFAULT 0 NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    about TEXT DEFAULT 'Writing awesome contents at Reblog' NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE collaborator_tokens
+(
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(225) NOT NULL,
+    token VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX collaborator_tokens_email_uindex ON collaborator_tokens (email);
+CREATE UNIQUE INDEX collaborator_tokens_token_uindex ON collaborator_tokens (token);
+
+CREATE TABLE posts
+(
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    content TEXT NOT NULL,
+    status INTEGER DEFAULT 0 NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    user_id INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX posts_slug_uindex ON posts (slug);
+CREATE UNIQUE INDEX posts_title_uindex ON posts(title);
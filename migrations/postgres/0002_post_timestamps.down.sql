@@ -0,0 +1,4 @@
+DROP INDEX posts_status_created_at_index;
+
+ALTER TABLE posts ALTER COLUMN created_at TYPE TEXT;
+ALTER TABLE posts ALTER COLUMN updated_at TYPE TEXT;
@@ -0,0 +1,5 @@
+-- Post timestamps were stored as text, they become dates so posts can be listed and filtered by date
+ALTER TABLE posts ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at::TIMESTAMPTZ;
+ALTER TABLE posts ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at::TIMESTAMPTZ;
+
+CREATE INDEX posts_status_created_at_index ON posts (status, created_at);
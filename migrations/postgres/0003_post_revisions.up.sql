@@ -0,0 +1,12 @@
+-- A copy of a post is kept every time it is written to, so older versions can be compared and restored
+CREATE TABLE post_revisions
+(
+    id SERIAL PRIMARY KEY,
+    post_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX post_revisions_post_id_index ON post_revisions (post_id);
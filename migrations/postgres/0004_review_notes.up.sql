@@ -0,0 +1,2 @@
+-- What the reviewer asked to change when sending a post back to its author
+ALTER TABLE posts ADD COLUMN review_note TEXT DEFAULT '' NOT NULL;
@@ -0,0 +1,4 @@
+-- Scheduled posts are published once their publishing date is due
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMPTZ;
+
+CREATE INDEX posts_status_publish_at_index ON posts (status, publish_at);
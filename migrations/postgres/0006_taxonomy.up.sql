@@ -0,0 +1,31 @@
+-- Posts can be given tags and a category. Categories can be nested
+ALTER TABLE posts ADD COLUMN category_id INTEGER;
+
+CREATE TABLE tags
+(
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX tags_name_uindex ON tags (name);
+CREATE UNIQUE INDEX tags_slug_uindex ON tags (slug);
+
+CREATE TABLE post_tags
+(
+    post_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, tag_id)
+);
+
+CREATE INDEX post_tags_tag_id_index ON post_tags (tag_id);
+
+CREATE TABLE categories
+(
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    parent_id INTEGER
+);
+
+CREATE UNIQUE INDEX categories_slug_uindex ON categories (slug);
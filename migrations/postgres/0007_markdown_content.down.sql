@@ -0,0 +1,4 @@
+ALTER TABLE posts DROP COLUMN reading_time;
+ALTER TABLE posts DROP COLUMN excerpt;
+ALTER TABLE posts DROP COLUMN content_html;
+ALTER TABLE posts RENAME COLUMN content_markdown TO content;
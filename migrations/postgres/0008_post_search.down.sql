@@ -0,0 +1 @@
+DROP INDEX posts_search_index;
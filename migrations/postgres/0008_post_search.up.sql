@@ -0,0 +1,3 @@
+-- Full text index over the posts' title and content.
+-- Search has to use this exact expression for the index to be picked
+CREATE INDEX posts_search_index ON posts USING GIN ((setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', content_markdown), 'B')));
@@ -0,0 +1,8 @@
+-- Invitations carry the type of user they sign up as and their own expiry.
+-- Those sent before were valid for 20 minutes
+ALTER TABLE collaborator_tokens ADD COLUMN type INT DEFAULT 0 NOT NULL;
+ALTER TABLE collaborator_tokens ADD COLUMN expires_at TIMESTAMPTZ;
+
+UPDATE collaborator_tokens SET expires_at = created_at + INTERVAL '20 minutes';
+
+ALTER TABLE collaborator_tokens ALTER COLUMN expires_at SET NOT NULL;
@@ -0,0 +1,19 @@
+-- Users can add an avatar and social links to their profile
+ALTER TABLE users ADD COLUMN avatar_url VARCHAR(255) DEFAULT '' NOT NULL;
+ALTER TABLE users ADD COLUMN links TEXT DEFAULT '{}' NOT NULL;
+
+-- A new email address is only saved once the user follows the link sent to it.
+-- Tokens are only stored hashed and can only be used once
+CREATE TABLE email_changes
+(
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX email_changes_token_hash_uindex ON email_changes (token_hash);
+CREATE INDEX email_changes_user_id_index ON email_changes (user_id);
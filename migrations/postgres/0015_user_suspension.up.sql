@@ -0,0 +1,2 @@
+-- Suspended users can't log in and their tokens are refused until they are reactivated
+ALTER TABLE users ADD COLUMN suspended_at TIMESTAMPTZ;
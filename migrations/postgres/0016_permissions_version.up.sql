@@ -0,0 +1,2 @@
+-- Bumped whenever the permissions of a user change, so the access tokens carrying the old ones are refused
+ALTER TABLE users ADD COLUMN permissions_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,20 @@
+CREATE TABLE posts_new
+(
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    content TEXT NOT NULL,
+    status INTEGER DEFAULT 0 NOT NULL,
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL,
+    user_id INTEGER NOT NULL
+);
+
+INSERT INTO posts_new (id, title, slug, content, status, created_at, updated_at, user_id)
+SELECT id, title, slug, content, status, created_at, updated_at, user_id FROM posts;
+
+DROP TABLE posts;
+ALTER TABLE posts_new RENAME TO posts;
+
+CREATE UNIQUE INDEX posts_slug_uindex ON posts (slug);
+CREATE UNIQUE INDEX posts_title_uindex ON posts(title);
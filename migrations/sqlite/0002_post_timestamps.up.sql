@@ -0,0 +1,28 @@
+-- Post timestamps were stored as text, they become dates so posts can be listed and filtered by date.
+-- They are converted to UTC like every date written from now on, so they compare correctly.
+-- SQLite can't change the type of a column, so the table is rebuilt
+CREATE TABLE posts_new
+(
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL,
+    slug TEXT NOT NULL,
+    content TEXT NOT NULL,
+    status INTEGER DEFAULT 0 NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    user_id INTEGER NOT NULL
+);
+
+INSERT INTO posts_new (id, title, slug, content, status, created_at, updated_at, user_id)
+SELECT id, title, slug, content, status,
+       COALESCE(strftime('%Y-%m-%d %H:%M:%f+00:00', created_at), created_at),
+       COALESCE(strftime('%Y-%m-%d %H:%M:%f+00:00', updated_at), updated_at),
+       user_id
+FROM posts;
+
+DROP TABLE posts;
+ALTER TABLE posts_new RENAME TO posts;
+
+CREATE UNIQUE INDEX posts_slug_uindex ON posts (slug);
+CREATE UNIQUE INDEX posts_title_uindex ON posts(title);
+CREATE INDEX posts_status_created_at_index ON posts (status, created_at);
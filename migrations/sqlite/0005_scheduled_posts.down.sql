@@ -0,0 +1,2 @@
+DROP INDEX posts_status_publish_at_index;
+ALTER TABLE posts DROP COLUMN publish_at;
@@ -0,0 +1,4 @@
+DROP TABLE categories;
+DROP TABLE post_tags;
+DROP TABLE tags;
+ALTER TABLE posts DROP COLUMN category_id;
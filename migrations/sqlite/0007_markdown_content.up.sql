@@ -0,0 +1,6 @@
+-- Posts are written in Markdown and rendered to HTML when saved, along with their excerpt and reading time.
+-- Posts written before are rendered the next time they are saved
+ALTER TABLE posts RENAME COLUMN content TO content_markdown;
+ALTER TABLE posts ADD COLUMN content_html TEXT DEFAULT '' NOT NULL;
+ALTER TABLE posts ADD COLUMN excerpt TEXT DEFAULT '' NOT NULL;
+ALTER TABLE posts ADD COLUMN reading_time INTEGER DEFAULT 0 NOT NULL;
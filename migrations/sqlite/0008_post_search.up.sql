@@ -0,0 +1,18 @@
+-- Full text index over the posts' title and content. Needs SQLite built with FTS5
+CREATE VIRTUAL TABLE posts_fts USING fts5(title, content_markdown, content='posts', content_rowid='id');
+
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts(rowid, title, content_markdown) VALUES (new.id, new.title, new.content_markdown);
+END;
+
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_fts(posts_fts, rowid, title, content_markdown) VALUES ('delete', old.id, old.title, old.content_markdown);
+END;
+
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, content_markdown ON posts BEGIN
+    INSERT INTO posts_fts(posts_fts, rowid, title, content_markdown) VALUES ('delete', old.id, old.title, old.content_markdown);
+    INSERT INTO posts_fts(rowid, title, content_markdown) VALUES (new.id, new.title, new.content_markdown);
+END;
+
+-- Index the posts written before search existed
+INSERT INTO posts_fts(posts_fts) VALUES ('rebuild');
@@ -0,0 +1,22 @@
+-- Refresh tokens are only stored hashed. Every token issued by rotating another one is in the same family,
+-- so a stolen token being reused can revoke the whole family
+CREATE TABLE refresh_tokens
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    family VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(255) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX refresh_tokens_token_hash_uindex ON refresh_tokens (token_hash);
+CREATE INDEX refresh_tokens_family_index ON refresh_tokens (family);
+
+-- Access tokens revoked before they expire, by jti. Rows are useless once the token has expired
+CREATE TABLE revoked_tokens
+(
+    jti VARCHAR(255) PRIMARY KEY,
+    expires_at DATETIME NOT NULL
+);
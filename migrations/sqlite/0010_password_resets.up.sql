@@ -0,0 +1,14 @@
+-- Password reset tokens are only stored hashed and can only be used once.
+-- Using one uses up every other token sent to the same user
+CREATE TABLE password_resets
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash VARCHAR(255) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE UNIQUE INDEX password_resets_token_hash_uindex ON password_resets (token_hash);
+CREATE INDEX password_resets_user_id_index ON password_resets (user_id);
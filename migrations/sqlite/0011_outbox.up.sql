@@ -0,0 +1,16 @@
+-- Emails waiting to be sent. They are retried until they go through, so none is lost when the mail server is down
+CREATE TABLE outbox
+(
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient VARCHAR(255) NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT DEFAULT '' NOT NULL,
+    attempts INTEGER DEFAULT 0 NOT NULL,
+    last_error TEXT DEFAULT '' NOT NULL,
+    next_attempt_at DATETIME NOT NULL,
+    sent_at DATETIME,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX outbox_next_attempt_at_index ON outbox (next_attempt_at);
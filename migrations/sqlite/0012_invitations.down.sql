@@ -0,0 +1,2 @@
+ALTER TABLE collaborator_tokens DROP COLUMN expires_at;
+ALTER TABLE collaborator_tokens DROP COLUMN type;
@@ -0,0 +1,11 @@
+-- Users able to manage other users become admins again, everyone else a collaborator
+ALTER TABLE collaborator_tokens ADD COLUMN type INT DEFAULT 0 NOT NULL;
+UPDATE collaborator_tokens SET type = 1 WHERE role IN (SELECT role FROM role_permissions WHERE permission = 'user:manage');
+ALTER TABLE collaborator_tokens DROP COLUMN role;
+
+ALTER TABLE users ADD COLUMN type INT DEFAULT 0 NOT NULL;
+UPDATE users SET type = 1 WHERE role IN (SELECT role FROM role_permissions WHERE permission = 'user:manage');
+ALTER TABLE users DROP COLUMN role;
+
+DROP TABLE role_permissions;
+DROP TABLE roles;
@@ -0,0 +1,71 @@
+-- Users get one of these roles instead of being an admin or a collaborator.
+-- The permissions of every role but the owner can be changed
+CREATE TABLE roles
+(
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT DEFAULT '' NOT NULL,
+    sort_order INT NOT NULL
+);
+
+CREATE TABLE role_permissions
+(
+    role VARCHAR(64) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description, sort_order) VALUES
+    ('owner', 'Runs the blog and decides what every other role can do', 1),
+    ('admin', 'Manages the blog and its users', 2),
+    ('editor', 'Reviews, publishes and edits every post', 3),
+    ('author', 'Writes and publishes their own posts', 4),
+    ('contributor', 'Writes posts and submits them for review', 5);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('owner', 'post:create'),
+    ('owner', 'post:edit:own'),
+    ('owner', 'post:edit:any'),
+    ('owner', 'post:read:any'),
+    ('owner', 'post:publish'),
+    ('owner', 'post:review'),
+    ('owner', 'post:unpublish'),
+    ('owner', 'post:delete:any'),
+    ('owner', 'taxonomy:manage'),
+    ('owner', 'user:invite'),
+    ('owner', 'user:manage'),
+    ('owner', 'role:manage'),
+    ('admin', 'post:create'),
+    ('admin', 'post:edit:own'),
+    ('admin', 'post:edit:any'),
+    ('admin', 'post:read:any'),
+    ('admin', 'post:publish'),
+    ('admin', 'post:review'),
+    ('admin', 'post:unpublish'),
+    ('admin', 'post:delete:any'),
+    ('admin', 'taxonomy:manage'),
+    ('admin', 'user:invite'),
+    ('admin', 'user:manage'),
+    ('editor', 'post:create'),
+    ('editor', 'post:edit:own'),
+    ('editor', 'post:edit:any'),
+    ('editor', 'post:read:any'),
+    ('editor', 'post:publish'),
+    ('editor', 'post:review'),
+    ('editor', 'post:unpublish'),
+    ('editor', 'post:delete:any'),
+    ('editor', 'taxonomy:manage'),
+    ('author', 'post:create'),
+    ('author', 'post:edit:own'),
+    ('author', 'post:publish'),
+    ('contributor', 'post:create'),
+    ('contributor', 'post:edit:own');
+
+-- Admins keep running the blog, the first of them becomes its owner. Collaborators become contributors
+ALTER TABLE users ADD COLUMN role VARCHAR(64) DEFAULT 'contributor' NOT NULL;
+UPDATE users SET role = 'admin' WHERE type = 1;
+UPDATE users SET role = 'owner' WHERE id = (SELECT MIN(id) FROM users WHERE type = 1);
+ALTER TABLE users DROP COLUMN type;
+
+ALTER TABLE collaborator_tokens ADD COLUMN role VARCHAR(64) DEFAULT 'contributor' NOT NULL;
+UPDATE collaborator_tokens SET role = 'admin' WHERE type = 1;
+ALTER TABLE collaborator_tokens DROP COLUMN type;
@@ -0,0 +1,4 @@
+DROP TABLE email_changes;
+
+ALTER TABLE users DROP COLUMN links;
+ALTER TABLE users DROP COLUMN avatar_url;
@@ -0,0 +1,161 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/adelowo/reblog/models"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//Number of published posts the benchmarks run against
+const BENCHMARK_POSTS = 200
+
+//benchmarkDB returns a SQLite database on disk holding BENCHMARK_POSTS published posts by the same author
+func benchmarkDB(b *testing.B) *models.DB {
+
+	dir, err := ioutil.TempDir("", "reblog")
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	db := models.MustNewDB(filepath.Join(dir, "reblog.db"))
+
+	b.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(dir)
+	})
+
+	migrate(b, db)
+
+	ctx := context.Background()
+
+	if err = db.CreateUser(ctx, &models.User{Moniker: "hades", Name: "Lanre Adelowo", Email: "hades@reblog.io", Password: "password"}); err != nil {
+		b.Fatal(err)
+	}
+
+	author, err := db.FindByMoniker(ctx, "hades")
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for i := 1; i <= BENCHMARK_POSTS; i++ {
+		p := &models.Post{
+			Title:   fmt.Sprintf("Go is awesome %d", i),
+			Slug:    fmt.Sprintf("Go-is-awesome-%d", i),
+			Content: fmt.Sprintf("Part %d of why Go, goroutines and channels are awesome", i),
+			Status:  models.PUBLISHED,
+			UserID:  author.ID,
+		}
+
+		if err = db.CreatePost(ctx, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+func BenchmarkFindPostByID(b *testing.B) {
+
+	db := benchmarkDB(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := db.FindPostByID(ctx, i%BENCHMARK_POSTS+1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindPostByIDInParallel(b *testing.B) {
+
+	db := benchmarkDB(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, err := db.FindPostByID(ctx, i%BENCHMARK_POSTS+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkFindPublishedPosts(b *testing.B) {
+
+	db := benchmarkDB(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		f := models.PostFilter{Page: i%(BENCHMARK_POSTS/models.DEFAULT_PER_PAGE) + 1}
+
+		if _, err := db.FindPublishedPosts(ctx, f); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := db.CountPublishedPosts(ctx, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindPublishedPostBySlug(b *testing.B) {
+
+	db := benchmarkDB(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := db.FindPublishedPostBySlug(ctx, fmt.Sprintf("Go-is-awesome-%d", i%BENCHMARK_POSTS+1)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+
+	db := benchmarkDB(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Search(ctx, models.SearchQuery{Terms: "goroutines channels"}, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreatePost(b *testing.B) {
+
+	db := benchmarkDB(b)
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		p := &models.Post{Title: fmt.Sprintf("Benchmarking Go %d", i), Slug: fmt.Sprintf("Benchmarking-Go-%d", i), Content: "Go is awesome", UserID: 1}
+
+		if err := db.CreatePost(ctx, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+//ErrCategoryExists is returned when a write would give two categories the same name or slug
+var ErrCategoryExists = errors.New("A category with the same name or slug already exists")
+
+type CategoryStore interface {
+	FindCategories(ctx context.Context) ([]Category, error)
+	FindCategoryBySlug(ctx context.Context, slug string) (Category, error)
+	CreateCategory(ctx context.Context, c *Category) error
+}
+
+//Category is a node in the category hierarchy. Top level categories have no parent.
+type Category struct {
+	ID       int    `db:"id"`
+	Name     string `db:"name"`
+	Slug     string `db:"slug"`
+	ParentID *int   `db:"parent_id"`
+}
+
+func (db *DB) FindCategories(ctx context.Context) ([]Category, error) {
+	var categories []Category
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM categories ORDER BY name")
+
+	if err != nil {
+		return categories, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.SelectContext(ctx, &categories); err != nil {
+		return categories, errors.Wrap(err, "Could not fetch categories")
+	}
+
+	return categories, nil
+}
+
+func (db *DB) FindCategoryBySlug(ctx context.Context, slug string) (Category, error) {
+	var c Category
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM categories WHERE slug=?")
+
+	if err != nil {
+		return c, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, slug).StructScan(&c); err != nil {
+		return c, errors.Wrap(err, "Category does not exist")
+	}
+
+	return c, nil
+}
+
+func (db *DB) CreateCategory(ctx context.Context, c *Category) error {
+
+	id, err := db.insert(ctx, "INSERT INTO categories(name, slug, parent_id) VALUES(?,?,?)", c.Name, c.Slug, c.ParentID)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrCategoryExists
+		}
+
+		return errors.Wrap(err, "Could not create category")
+	}
+
+	c.ID = id
+
+	return nil
+}
@@ -1,13 +1,283 @@
 package models
 
 import (
+	"context"
+	"fmt"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"strings"
+	"sync"
+	"time"
 )
 
-func MustNewDB(databaseName string) *DB {
+//Names of the database/sql drivers reblog can run on
+const (
+	SQLITE   = "sqlite3"
+	POSTGRES = "postgres"
+)
+
+//How long a SQLite connection waits for another one to release its lock before giving up
+const SQLITE_BUSY_TIMEOUT = 5 * time.Second
+
+//Driver picks the database driver for a dsn.
+//postgres:// and postgresql:// urls are PostgreSQL databases, anything else is the path to a SQLite database.
+func Driver(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return POSTGRES
+	}
+
+	return SQLITE
+}
+
+//sqliteDSN turns on WAL, so reads don't wait for writes, and makes connections wait SQLITE_BUSY_TIMEOUT for locks.
+//Settings already in the dsn win.
+func sqliteDSN(dsn string) string {
+
+	var params []string
+
+	settings := ""
+
+	if i := strings.Index(dsn, "?"); i != -1 {
+		settings = dsn[i:]
+	}
+
+	if !strings.Contains(settings, "_journal") {
+		params = append(params, "_journal_mode=WAL")
+	}
+
+	if !strings.Contains(settings, "_timeout") {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d", SQLITE_BUSY_TIMEOUT/time.Millisecond))
+	}
+
+	if len(params) == 0 {
+		return dsn
+	}
+
+	if settings != "" {
+		return dsn + "&" + strings.Join(params, "&")
+	}
+
+	return dsn + "?" + strings.Join(params, "&")
+}
+
+func connectionString(dsn string) (string, string) {
+	driver := Driver(dsn)
+
+	if driver == SQLITE {
+		dsn = sqliteDSN(dsn)
+	}
+
+	return driver, dsn
+}
+
+func NewDB(dsn string) (*DB, error) {
+
+	db, err := sqlx.Connect(connectionString(dsn))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not connect to the database")
+	}
+
+	return &DB{DB: db, stmts: newStatements()}, nil
+}
+
+func MustNewDB(dsn string) *DB {
+
+	db := sqlx.MustConnect(connectionString(dsn))
+
+	return &DB{DB: db, stmts: newStatements()}
+}
+
+//PoolOptions tunes the connection pool. Zero values keep database/sql's defaults
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func (db *DB) Configure(o PoolOptions) {
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+}
+
+//statements caches prepared statements by query, so every query is only prepared once.
+//The cache is shared by the DB and the copies of it handed to WithTx.
+type statements struct {
+	mu    sync.Mutex
+	cache map[string]*sqlx.Stmt
+}
+
+func newStatements() *statements {
+	return &statements{cache: make(map[string]*sqlx.Stmt)}
+}
+
+func (s *statements) get(query string) (*sqlx.Stmt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stmt, ok := s.cache[query]
+
+	return stmt, ok
+}
+
+func (s *statements) prepare(ctx context.Context, db *sqlx.DB, query string) (*sqlx.Stmt, error) {
+
+	if stmt, ok := s.get(query); ok {
+		return stmt, nil
+	}
+
+	stmt, err := db.PreparexContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	//Someone else prepared the same query in the meantime
+	if existing, ok := s.cache[query]; ok {
+		stmt.Close()
+		return existing, nil
+	}
+
+	s.cache[query] = stmt
+
+	return stmt, nil
+}
+
+func (s *statements) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+
+	for query, stmt := range s.cache {
+		if e := stmt.Close(); e != nil && err == nil {
+			err = e
+		}
+
+		delete(s.cache, query)
+	}
+
+	return err
+}
+
+//PreparexContext rewrites the ? placeholders used throughout the models into the ones the driver understands.
+//Statements are prepared once and kept until the DB is closed, so they must not be closed by the caller.
+//Statements prepared in a transaction run in it and are closed along with it.
+func (db *DB) PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	query = db.Rebind(query)
+
+	if db.tx != nil {
+		//Preparing the statement outside of the transaction would need another connection
+		if stmt, ok := db.stmts.get(query); ok {
+			return db.tx.StmtxContext(ctx, stmt), nil
+		}
+
+		return db.tx.PreparexContext(ctx, query)
+	}
+
+	return db.stmts.prepare(ctx, db.DB, query)
+}
+
+func (db *DB) Preparex(query string) (*sqlx.Stmt, error) {
+	return db.PreparexContext(context.Background(), query)
+}
+
+func (db *DB) WithTx(ctx context.Context, fn func(tx DataStore) error) error {
+
+	if db.tx != nil {
+		return fn(db)
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not start a transaction")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(&DB{DB: db.DB, tx: tx, stmts: db.stmts}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "Could not commit the transaction")
+}
+
+//Close closes the cached statements, then the database
+func (db *DB) Close() error {
+
+	err := db.stmts.close()
+
+	if e := db.DB.Close(); e != nil {
+		return e
+	}
+
+	return err
+}
+
+//insert runs an INSERT statement and returns the id of the new row.
+//The postgres driver doesn't support LastInsertId, so the id is read back with RETURNING instead.
+func (db *DB) insert(ctx context.Context, query string, args ...interface{}) (int, error) {
+
+	if db.DriverName() == POSTGRES {
+		stmt, err := db.PreparexContext(ctx, query+" RETURNING id")
+
+		if err != nil {
+			return 0, err
+		}
+
+		var id int
+
+		err = stmt.QueryRowxContext(ctx, args...).Scan(&id)
+
+		return id, err
+	}
+
+	stmt, err := db.PreparexContext(ctx, query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := stmt.ExecContext(ctx, args...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+
+	return int(id), err
+}
 
-	db := sqlx.MustConnect("sqlite3", databaseName)
+//Reports whether err was caused by a write that violates a unique index
+func isUniqueViolation(err error) bool {
+	switch e := errors.Cause(err).(type) {
+	case sqlite3.Error:
+		return e.ExtendedCode == sqlite3.ErrConstraintUnique
+	case *pq.Error:
+		return e.Code == "23505"
+	}
 
-	return &DB{db}
+	return false
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSQLiteIsAskedToUseWALAndWaitForLocks(t *testing.T) {
+
+	tests := map[string]string{
+		"reblog.db":                         "reblog.db?_journal_mode=WAL&_busy_timeout=5000",
+		"my_journal.db":                     "my_journal.db?_journal_mode=WAL&_busy_timeout=5000",
+		"file:reblog.db?cache=shared":       "file:reblog.db?cache=shared&_journal_mode=WAL&_busy_timeout=5000",
+		"reblog.db?_journal_mode=DELETE":    "reblog.db?_journal_mode=DELETE&_busy_timeout=5000",
+		"reblog.db?_journal=WAL&_timeout=1": "reblog.db?_journal=WAL&_timeout=1",
+	}
+
+	for dsn, expected := range tests {
+		assert.Equal(t, expected, sqliteDSN(dsn))
+	}
+}
+
+func TestStatementsArePreparedOnce(t *testing.T) {
+
+	db := MustNewDB(":memory:")
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+
+	first, err := db.PreparexContext(ctx, "SELECT 1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := db.PreparexContext(ctx, "SELECT 1")
+
+	assert.NoError(t, err)
+	assert.True(t, first == second)
+
+	err = db.WithTx(ctx, func(tx DataStore) error {
+
+		stmt, err := tx.(*DB).PreparexContext(ctx, "SELECT 1")
+
+		if err != nil {
+			return err
+		}
+
+		//The transaction gets its own copy of the statement
+		assert.False(t, stmt == first)
+
+		//Queries first seen in a transaction are not cached, as they would need another connection
+		_, err = tx.(*DB).PreparexContext(ctx, "SELECT 2")
+
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, db.stmts.cache, 1)
+
+	var one int
+
+	assert.NoError(t, first.Get(&one))
+	assert.Equal(t, 1, one)
+
+	assert.NoError(t, db.Close())
+	assert.Error(t, first.Get(&one))
+	assert.Empty(t, db.stmts.cache)
+}
@@ -0,0 +1,262 @@
+package models_test
+
+import (
+	"context"
+	"github.com/adelowo/reblog/migrations"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/storetest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func migrate(t testing.TB, db *models.DB) {
+
+	source, err := migrations.Source(db.DriverName())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrations.New(db.DB, source)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Up(); err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			t.Skip("SQLite was built without FTS5. Run the tests with -tags sqlite_fts5")
+		}
+
+		t.Fatal(err)
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+
+	storetest.Run(t, func(t *testing.T) models.DataStore {
+
+		db := models.MustNewDB(":memory:")
+
+		//Every connection to :memory: gets its own database
+		db.SetMaxOpenConns(1)
+
+		migrate(t, db)
+
+		return db
+	})
+}
+
+func TestACancelledContextAbortsTheQuery(t *testing.T) {
+
+	db := models.MustNewDB(":memory:")
+
+	db.SetMaxOpenConns(1)
+
+	migrate(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cancel()
+
+	if _, err := db.FindPostByID(ctx, 1); errors.Cause(err) != context.Canceled {
+		t.Fatalf("Expected the query to be cancelled. Got %v", err)
+	}
+
+	err := db.CreatePost(ctx, &models.Post{Title: "Go is awesome", Slug: "Go-is-awesome", Content: "Go is awesome", UserID: 1})
+
+	if errors.Cause(err) != context.Canceled {
+		t.Fatalf("Expected the insert to be cancelled. Got %v", err)
+	}
+}
+
+func TestAPostIsSavedTogetherWithItsTagsAndRevision(t *testing.T) {
+
+	db := models.MustNewDB(":memory:")
+
+	db.SetMaxOpenConns(1)
+
+	migrate(t, db)
+
+	//Every revision now fails to be written
+	db.MustExec("DROP TABLE post_revisions")
+
+	p := &models.Post{Title: "Go is awesome", Slug: "Go-is-awesome", Content: "Go is awesome", UserID: 1,
+		Tags: []models.Tag{{Name: "Go", Slug: "go"}}}
+
+	assert.Error(t, db.CreatePost(context.Background(), p))
+
+	var posts, tags int
+
+	assert.NoError(t, db.Get(&posts, "SELECT COUNT(*) FROM posts"))
+	assert.NoError(t, db.Get(&tags, "SELECT COUNT(*) FROM post_tags"))
+
+	assert.Equal(t, 0, posts)
+	assert.Equal(t, 0, tags)
+}
+
+func TestMergingTagsIsAllOrNothing(t *testing.T) {
+
+	db := models.MustNewDB(":memory:")
+
+	db.SetMaxOpenConns(1)
+
+	migrate(t, db)
+
+	db.MustExec("INSERT INTO post_tags(post_id, tag_id) VALUES(1, 1)")
+
+	//Deleting the merged tag now fails
+	db.MustExec("DROP TABLE tags")
+
+	assert.Error(t, db.MergeTags(context.Background(), models.Tag{ID: 1}, models.Tag{ID: 2}))
+
+	var tags int
+
+	assert.NoError(t, db.Get(&tags, "SELECT COUNT(*) FROM post_tags"))
+
+	assert.Equal(t, 1, tags)
+}
+
+func TestEmailBodiesAreForgottenOnceTheyAreNoLongerNeeded(t *testing.T) {
+
+	db := models.MustNewDB(":memory:")
+
+	db.SetMaxOpenConns(1)
+
+	migrate(t, db)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	sent := &models.Email{To: "hades@reblog.io", Subject: "Reset your password", Text: "token", HTML: "<p>token</p>"}
+	failed := &models.Email{To: "zeus@reblog.io", Subject: "Reset your password", Text: "token", HTML: "<p>token</p>"}
+
+	assert.NoError(t, db.QueueEmail(ctx, sent))
+	assert.NoError(t, db.QueueEmail(ctx, failed))
+
+	assert.NoError(t, db.ClaimEmail(ctx, *sent, now))
+	assert.NoError(t, db.MarkEmailSent(ctx, sent.ID, now))
+
+	body := func(id int) string {
+		var text, html string
+
+		assert.NoError(t, db.QueryRowx("SELECT text_body, html_body FROM outbox WHERE id=?", id).Scan(&text, &html))
+
+		return text + html
+	}
+
+	assert.Empty(t, body(sent.ID))
+
+	//A failed email keeps its body until it won't be tried again
+	for i := 0; i < models.MAX_EMAIL_ATTEMPTS; i++ {
+		e := *failed
+		e.Attempts = i
+
+		assert.NoError(t, db.ClaimEmail(ctx, e, now))
+		assert.NoError(t, db.MarkEmailFailed(ctx, failed.ID, "Connection refused", now))
+
+		if i < models.MAX_EMAIL_ATTEMPTS-1 {
+			assert.Equal(t, "token<p>token</p>", body(failed.ID))
+		}
+	}
+
+	assert.Empty(t, body(failed.ID))
+}
+
+//Runs against the database REBLOG_TEST_POSTGRES_URL points to.
+//Everything in its public schema is dropped before every test.
+func TestPostgresStore(t *testing.T) {
+
+	dsn := os.Getenv("REBLOG_TEST_POSTGRES_URL")
+
+	if dsn == "" {
+		t.Skip("Set REBLOG_TEST_POSTGRES_URL to run the store tests against PostgreSQL")
+	}
+
+	db, err := models.NewDB(dsn)
+
+	if err != nil {
+		t.Skipf("PostgreSQL is not available: %v", err)
+	}
+
+	db.Close()
+
+	storetest.Run(t, func(t *testing.T) models.DataStore {
+
+		//Every test gets its own DB, so no statement prepared against the dropped schema is reused
+		db := models.MustNewDB(dsn)
+
+		t.Cleanup(func() {
+			db.Close()
+		})
+
+		db.MustExec("DROP SCHEMA public CASCADE; CREATE SCHEMA public")
+
+		migrate(t, db)
+
+		return db
+	})
+}
+
+func TestTheDriverIsPickedFromTheDSN(t *testing.T) {
+
+	tests := map[string]string{
+		"reblog.db":                       models.SQLITE,
+		":memory:":                        models.SQLITE,
+		"file:reblog.db?cache=shared":     models.SQLITE,
+		"postgres://localhost/reblog":     models.POSTGRES,
+		"postgresql://u:p@db:5432/reblog": models.POSTGRES,
+	}
+
+	for dsn, driver := range tests {
+		if got := models.Driver(dsn); got != driver {
+			t.Errorf("Expected %s to use %s. Got %s", dsn, driver, got)
+		}
+	}
+}
+
+func TestSQLiteDatabasesUseWAL(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reblog")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	db := models.MustNewDB(filepath.Join(dir, "reblog.db"))
+
+	defer db.Close()
+
+	var mode string
+	var timeout int
+
+	assert.NoError(t, db.Get(&mode, "PRAGMA journal_mode"))
+	assert.NoError(t, db.Get(&timeout, "PRAGMA busy_timeout"))
+
+	assert.Equal(t, "wal", mode)
+	assert.Equal(t, int(models.SQLITE_BUSY_TIMEOUT/time.Millisecond), timeout)
+}
+
+func TestThePoolCanBeConfigured(t *testing.T) {
+
+	db := models.MustNewDB(":memory:")
+
+	defer db.Close()
+
+	db.Configure(models.PoolOptions{MaxOpenConns: 4, MaxIdleConns: 2, ConnMaxLifetime: time.Minute})
+
+	assert.Equal(t, 4, db.Stats().MaxOpenConnections)
+
+	//Zero values are left alone
+	db.Configure(models.PoolOptions{})
+
+	assert.Equal(t, 4, db.Stats().MaxOpenConnections)
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"time"
+)
+
+//How long the link to verify a new email address can be used for
+const EMAIL_CHANGE_TTL = 24 * time.Hour
+
+//ErrEmailChangeUsed is returned when an email change token that was already used is used again
+var ErrEmailChangeUsed = errors.New("The email change token has already been used")
+
+type EmailChangeStore interface {
+	CreateEmailChange(ctx context.Context, userID int, email string) (string, error)
+	FindEmailChange(ctx context.Context, token string) (EmailChange, error)
+	UseEmailChange(ctx context.Context, c EmailChange) error
+}
+
+//EmailChange is a single use token emailed to the new address of a user, who has to follow it before the address is changed.
+//Only its hash is stored.
+type EmailChange struct {
+	ID        int        `db:"id"`
+	UserID    int        `db:"user_id"`
+	Email     string     `db:"email"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+func (c EmailChange) Expired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+//CreateEmailChange issues a token to verify the new email address of the user
+func (db *DB) CreateEmailChange(ctx context.Context, userID int, email string) (string, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not generate the email change token")
+	}
+
+	now := time.Now()
+
+	stmt, err := db.PreparexContext(ctx, "INSERT INTO email_changes(user_id, email, token_hash, expires_at, created_at) VALUES(?,?,?,?,?)")
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, userID, email, HashToken(token), now.Add(EMAIL_CHANGE_TTL).UTC(), now.UTC()); err != nil {
+		return "", errors.Wrap(err, "Could not save the email change token")
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindEmailChange(ctx context.Context, token string) (EmailChange, error) {
+	var c EmailChange
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM email_changes WHERE token_hash=?")
+
+	if err != nil {
+		return c, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, HashToken(token)).StructScan(&c); err != nil {
+		return c, errors.Wrap(err, "Email change token does not exist")
+	}
+
+	return c, nil
+}
+
+//UseEmailChange uses up the token along with every other email change token the user was sent.
+//It fails with ErrEmailChangeUsed if the token was used in the meantime, so a token can only be used once.
+func (db *DB) UseEmailChange(ctx context.Context, c EmailChange) error {
+
+	now := time.Now().UTC()
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE email_changes SET used_at=? WHERE id=? AND used_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, now, c.ID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not use the email change token")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return ErrEmailChangeUsed
+	}
+
+	stmt, err = db.PreparexContext(ctx, "UPDATE email_changes SET used_at=? WHERE user_id=? AND used_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, now, c.UserID); err != nil {
+		return errors.Wrap(err, "Could not use the user's other email change tokens")
+	}
+
+	return nil
+}
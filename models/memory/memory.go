@@ -0,0 +1,1902 @@
+//Package memory is a models.DataStore that keeps everything in memory.
+//It is meant for tests and demos: it enforces the same unique constraints as the database schema
+//and is safe for concurrent use, but nothing survives a restart.
+//Nothing it does can be slow, so contexts are only checked when a transaction starts.
+package memory
+
+import (
+	"context"
+	"github.com/adelowo/gotils/hasher"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+//What the database fills in for users who haven't said anything about themselves
+const DEFAULT_ABOUT = "Writing awesome contents at Reblog"
+
+//Number of words in a search snippet
+const SNIPPET_WORDS = 16
+
+type Store struct {
+	mu sync.RWMutex
+
+	//Set on the copy of the store handed to WithTx's function
+	inTx bool
+
+	data
+}
+
+//data is everything the store holds
+type data struct {
+	//Every slice is kept in the order rows were created, so by id
+	users         []models.User
+	collaborators []models.Collaborator
+	posts         []models.Post
+	revisions     []models.Revision
+	tags          []models.Tag
+	categories    []models.Category
+	refreshTokens []models.RefreshToken
+	resets        []models.PasswordReset
+	emailChanges  []models.EmailChange
+	outbox        []models.Email
+	roles         []models.Role
+
+	//When every revoked access token expires, by jti
+	revokedTokens map[string]time.Time
+
+	//The ids of the tags on every post
+	postTags map[int][]int
+
+	//The last id handed out for each kind of row
+	lastID map[string]int
+}
+
+//New returns an empty store, apart from the default roles every database starts with
+func New() *Store {
+	roles := make([]models.Role, 0, len(models.DefaultRoles))
+
+	for _, r := range models.DefaultRoles {
+		r.Permissions, _ = models.ValidatePermissions(r.Permissions)
+		roles = append(roles, r)
+	}
+
+	return &Store{data: data{roles: roles, postTags: make(map[int][]int), revokedTokens: make(map[string]time.Time), lastID: make(map[string]int)}}
+}
+
+//copy returns a copy of d that can be changed without changing d.
+//Rows are never changed through their pointers, so copying the slices is enough.
+func (d data) copy() data {
+	c := data{
+		users:         append([]models.User(nil), d.users...),
+		collaborators: append([]models.Collaborator(nil), d.collaborators...),
+		posts:         append([]models.Post(nil), d.posts...),
+		revisions:     append([]models.Revision(nil), d.revisions...),
+		tags:          append([]models.Tag(nil), d.tags...),
+		categories:    append([]models.Category(nil), d.categories...),
+		refreshTokens: append([]models.RefreshToken(nil), d.refreshTokens...),
+		resets:        append([]models.PasswordReset(nil), d.resets...),
+		emailChanges:  append([]models.EmailChange(nil), d.emailChanges...),
+		outbox:        append([]models.Email(nil), d.outbox...),
+		roles:         append([]models.Role(nil), d.roles...),
+		revokedTokens: make(map[string]time.Time, len(d.revokedTokens)),
+		postTags:      make(map[int][]int, len(d.postTags)),
+		lastID:        make(map[string]int, len(d.lastID)),
+	}
+
+	for postID, ids := range d.postTags {
+		c.postTags[postID] = append([]int(nil), ids...)
+	}
+
+	for jti, expiresAt := range d.revokedTokens {
+		c.revokedTokens[jti] = expiresAt
+	}
+
+	for kind, id := range d.lastID {
+		c.lastID[kind] = id
+	}
+
+	return c
+}
+
+//WithTx runs fn against a copy of the store, which replaces the store only if fn succeeds.
+//Everyone else waits for the transaction to be over, so fn must not use the store itself.
+func (s *Store) WithTx(ctx context.Context, fn func(tx models.DataStore) error) error {
+
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "Could not start a transaction")
+	}
+
+	tx := &Store{inTx: true, data: s.data.copy()}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.data = tx.data
+
+	return nil
+}
+
+func (s *Store) nextID(kind string) int {
+	s.lastID[kind]++
+
+	return s.lastID[kind]
+}
+
+func (s *Store) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return models.User{}, errors.New("Could not find a user with the specified email address")
+}
+
+func (s *Store) FindByMoniker(ctx context.Context, moniker string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Moniker == moniker {
+			return u, nil
+		}
+	}
+
+	return models.User{}, errors.New("Could not find a user with the specified username")
+}
+
+func (s *Store) FindByID(ctx context.Context, id int) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+
+	return models.User{}, errors.New("Could not find a user with the specified id")
+}
+
+func (s *Store) DoesUserExist(ctx context.Context, email, moniker string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Email == email || u.Moniker == moniker {
+			return true
+		}
+	}
+
+	return false
+}
+
+//CreateUser hashes the user's password before saving it.
+//Like the database, it ignores the user's type and about, new users are always collaborators.
+func (s *Store) CreateUser(ctx context.Context, u *models.User) error {
+
+	hashed, err := hasher.NewBcryptHasher(bcrypt.DefaultCost).Hash(u.Password)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not hash the user's password")
+	}
+
+	if u.Role == "" {
+		u.Role = models.CONTRIBUTOR
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	s.users = append(s.users, models.User{
+		ID:        s.nextID("users"),
+		Moniker:   u.Moniker,
+		Role:      u.Role,
+		Name:      u.Name,
+		About:     DEFAULT_ABOUT,
+		Links:     models.Links{},
+		Email:     u.Email,
+		Password:  hashed,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+
+	return nil
+}
+
+//UpdateProfile saves the user's name, about, avatar and links.
+//The store keeps its own copy of the links.
+func (s *Store) UpdateProfile(ctx context.Context, u models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links := make(models.Links, len(u.Links))
+
+	for name, url := range u.Links {
+		links[name] = url
+	}
+
+	for i, existing := range s.users {
+		if existing.ID == u.ID {
+			s.users[i].Name = u.Name
+			s.users[i].About = u.About
+			s.users[i].AvatarURL = u.AvatarURL
+			s.users[i].Links = links
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return errors.New("Could not find a user with the specified id")
+}
+
+func (s *Store) UpdateEmail(ctx context.Context, userID int, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.users {
+		if u.ID == userID {
+			s.users[i].Email = email
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return errors.New("Could not find a user with the specified id")
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, userID int, password string) error {
+
+	hashed, err := hasher.NewBcryptHasher(bcrypt.DefaultCost).Hash(password)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not hash the user's password")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.users {
+		if u.ID == userID {
+			s.users[i].Password = hashed
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return errors.New("Could not find a user with the specified id")
+}
+
+func (s *Store) DeleteUser(ctx context.Context, u models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.users {
+		if existing.ID == u.ID {
+			s.users = append(s.users[:i], s.users[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("Could not find a user with the specified id")
+}
+
+//matches tells if the user is one of those the filter asks for
+func matches(u models.User, f models.UserFilter) bool {
+	q := strings.ToLower(f.Query)
+
+	switch {
+	case f.Role != "" && u.Role != f.Role:
+	case f.Status == models.USER_ACTIVE && u.Suspended():
+	case f.Status == models.USER_SUSPENDED && !u.Suspended():
+	case q != "" && !strings.Contains(strings.ToLower(u.Moniker), q) &&
+		!strings.Contains(strings.ToLower(u.Name), q) && !strings.Contains(strings.ToLower(u.Email), q):
+	default:
+		return true
+	}
+
+	return false
+}
+
+//FindUsers lists the users matching the filter, oldest first
+func (s *Store) FindUsers(ctx context.Context, f models.UserFilter) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []models.User{}
+
+	for _, u := range s.users {
+		if matches(u, f) {
+			users = append(users, u)
+		}
+	}
+
+	start, end := page(models.PostFilter{Page: f.Page, PerPage: f.PerPage}, len(users))
+
+	return users[start:end], nil
+}
+
+func (s *Store) CountUsers(ctx context.Context, f models.UserFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+
+	for _, u := range s.users {
+		if matches(u, f) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+//updateUser applies change to the user with the given id and bumps their updated_at timestamp
+func (s *Store) updateUser(userID int, change func(u *models.User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, u := range s.users {
+		if u.ID == userID {
+			change(&s.users[i])
+			s.users[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return errors.New("Could not find a user with the specified id")
+}
+
+func (s *Store) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	return s.updateUser(userID, func(u *models.User) {
+		u.Role = role
+		u.PermissionsVersion++
+	})
+}
+
+//SuspendUser keeps the user from logging in from at onwards. Suspending a suspended user keeps the first date
+func (s *Store) SuspendUser(ctx context.Context, userID int, at time.Time) error {
+	at = at.UTC()
+
+	return s.updateUser(userID, func(u *models.User) {
+		if u.SuspendedAt == nil {
+			u.SuspendedAt = &at
+		}
+	})
+}
+
+func (s *Store) ReactivateUser(ctx context.Context, userID int) error {
+	return s.updateUser(userID, func(u *models.User) {
+		u.SuspendedAt = nil
+	})
+}
+
+//IsUserSuspended tells if the user whose id is subject is suspended. Users that no longer exist are reported as suspended too
+func (s *Store) IsUserSuspended(ctx context.Context, subject string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if strconv.Itoa(u.ID) == subject {
+			return u.Suspended(), nil
+		}
+	}
+
+	return true, nil
+}
+
+//ArePermissionsOutdated tells if the permissions of the user whose id is subject have changed since version.
+//Users that no longer exist are reported as outdated too
+func (s *Store) ArePermissionsOutdated(ctx context.Context, subject string, version int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if strconv.Itoa(u.ID) == subject {
+			return u.PermissionsVersion != version, nil
+		}
+	}
+
+	return true, nil
+}
+
+//CreateCollaborator adds a collaborator with a fresh token, or replaces the token, role and expiry of one with the same email
+func (s *Store) CreateCollaborator(ctx context.Context, email, role string, expiresAt time.Time) (models.Collaborator, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return models.Collaborator{}, errors.Wrap(err, "Could not generate token for collaborator")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := -1
+
+	for i, c := range s.collaborators {
+		if c.Token == token {
+			return models.Collaborator{}, errors.New("Could not add the collaborator as the token is already in use")
+		}
+
+		if c.Email == email {
+			existing = i
+		}
+	}
+
+	now := time.Now()
+
+	if existing != -1 {
+		s.collaborators[existing].Token = token
+		s.collaborators[existing].Role = role
+		s.collaborators[existing].ExpiresAt = expiresAt
+		s.collaborators[existing].CreatedAt = now
+		return s.collaborators[existing], nil
+	}
+
+	c := models.Collaborator{ID: s.nextID("collaborators"), Email: email, Token: token, Role: role, ExpiresAt: expiresAt, CreatedAt: now}
+
+	s.collaborators = append(s.collaborators, c)
+
+	return c, nil
+}
+
+func (s *Store) FindCollaborators(ctx context.Context) ([]models.Collaborator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	collaborators := append([]models.Collaborator{}, s.collaborators...)
+
+	sort.SliceStable(collaborators, func(i, j int) bool {
+		if !collaborators[i].CreatedAt.Equal(collaborators[j].CreatedAt) {
+			return collaborators[i].CreatedAt.After(collaborators[j].CreatedAt)
+		}
+
+		return collaborators[i].ID > collaborators[j].ID
+	})
+
+	return collaborators, nil
+}
+
+func (s *Store) FindCollaboratorByID(ctx context.Context, id int) (models.Collaborator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.collaborators {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+
+	return models.Collaborator{}, errors.New("Collaborator not found")
+}
+
+func (s *Store) FindCollaboratorByToken(ctx context.Context, token string) (models.Collaborator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.collaborators {
+		if c.Token == token {
+			return c, nil
+		}
+	}
+
+	return models.Collaborator{}, errors.New("Collaborator not found")
+}
+
+//RotateCollaboratorToken gives the invitation a fresh token valid until expiresAt
+func (s *Store) RotateCollaboratorToken(ctx context.Context, id int, expiresAt time.Time) (models.Collaborator, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return models.Collaborator{}, errors.Wrap(err, "Could not generate token for collaborator")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.collaborators {
+		if c.ID == id {
+			s.collaborators[i].Token = token
+			s.collaborators[i].ExpiresAt = expiresAt
+			s.collaborators[i].CreatedAt = time.Now()
+			return s.collaborators[i], nil
+		}
+	}
+
+	return models.Collaborator{}, errors.New("Collaborator not found")
+}
+
+func (s *Store) DeleteCollaborator(ctx context.Context, c models.Collaborator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.collaborators {
+		if existing.Email == c.Email {
+			s.collaborators = append(s.collaborators[:i], s.collaborators[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("An error occured while we tried deleting the collaborator")
+}
+
+//copyPost returns a copy of p that doesn't share its publishing date or category id.
+//Stored posts are only handed out as copies, so callers can't change them behind the store's back.
+func copyPost(p models.Post) models.Post {
+	if p.PublishAt != nil {
+		publishAt := *p.PublishAt
+		p.PublishAt = &publishAt
+	}
+
+	if p.CategoryID != nil {
+		categoryID := *p.CategoryID
+		p.CategoryID = &categoryID
+	}
+
+	return p
+}
+
+//row is what the posts table would hold for p
+func row(p models.Post) models.Post {
+	p = copyPost(p)
+
+	p.User = models.User{}
+	p.Category = models.Category{}
+	p.Tags = nil
+
+	if p.PublishAt != nil {
+		*p.PublishAt = p.PublishAt.UTC()
+	}
+
+	return p
+}
+
+func (s *Store) postIndex(id int) int {
+	for i, p := range s.posts {
+		if p.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//titleOrSlugTaken reports whether a post other than p has its title or slug
+func (s *Store) titleOrSlugTaken(p models.Post) bool {
+	for _, existing := range s.posts {
+		if existing.ID != p.ID && (existing.Title == p.Title || existing.Slug == p.Slug) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//withAuthor adds the author and category to a post, like the database's joins do.
+//Posts whose author doesn't exist are left out of those joins, so ok is false for them.
+func (s *Store) withAuthor(p models.Post) (post models.Post, ok bool) {
+	post = copyPost(p)
+
+	for _, u := range s.users {
+		if u.ID == p.UserID {
+			post.User = models.User{ID: u.ID, Moniker: u.Moniker, Name: u.Name, About: u.About}
+			ok = true
+		}
+	}
+
+	if p.CategoryID != nil {
+		for _, c := range s.categories {
+			if c.ID == *p.CategoryID {
+				post.Category = copyCategory(c)
+			}
+		}
+	}
+
+	return post, ok
+}
+
+//CreatePost saves a new post written by the user p.UserID and sets its id and timestamps
+func (s *Store) CreatePost(ctx context.Context, p *models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.titleOrSlugTaken(models.Post{Title: p.Title, Slug: p.Slug}) {
+		return models.ErrPostExists
+	}
+
+	now := time.Now()
+
+	p.ID = s.nextID("posts")
+	p.CreatedAt = now
+	p.UpdatedAt = now
+
+	s.posts = append(s.posts, row(*p))
+
+	s.setPostTags(p.ID, p.Tags)
+	s.snapshot(p.ID)
+
+	return nil
+}
+
+func (s *Store) findPost(match func(p models.Post) bool) (models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.posts {
+		if match(p) {
+			return copyPost(p), nil
+		}
+	}
+
+	return models.Post{}, errors.New("Post does not exists")
+}
+
+func (s *Store) FindPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	return s.findPost(func(p models.Post) bool { return p.Slug == slug })
+}
+
+func (s *Store) FindPostByTitle(ctx context.Context, title string) (models.Post, error) {
+	return s.findPost(func(p models.Post) bool { return p.Title == title })
+}
+
+func (s *Store) FindPostByID(ctx context.Context, id int) (models.Post, error) {
+	return s.findPost(func(p models.Post) bool { return p.ID == id })
+}
+
+func (s *Store) DeletePost(ctx context.Context, p models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.postIndex(p.ID)
+
+	if i == -1 {
+		return errors.New("Post could not be deleted")
+	}
+
+	s.posts = append(s.posts[:i], s.posts[i+1:]...)
+
+	delete(s.postTags, p.ID)
+
+	var revisions []models.Revision
+
+	for _, r := range s.revisions {
+		if r.PostID != p.ID {
+			revisions = append(revisions, r)
+		}
+	}
+
+	s.revisions = revisions
+
+	return nil
+}
+
+func (s *Store) ReassignPosts(ctx context.Context, fromUserID, toUserID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, p := range s.posts {
+		if p.UserID == fromUserID {
+			s.posts[i].UserID = toUserID
+		}
+	}
+
+	return nil
+}
+
+//DeleteUserPosts deletes every post written by the user along with their tags and revisions
+func (s *Store) DeleteUserPosts(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := make(map[int]bool)
+	var posts []models.Post
+
+	for _, p := range s.posts {
+		if p.UserID == userID {
+			deleted[p.ID] = true
+			delete(s.postTags, p.ID)
+			continue
+		}
+
+		posts = append(posts, p)
+	}
+
+	var revisions []models.Revision
+
+	for _, r := range s.revisions {
+		if !deleted[r.PostID] {
+			revisions = append(revisions, r)
+		}
+	}
+
+	s.posts = posts
+	s.revisions = revisions
+
+	return nil
+}
+
+//UnpublishPost takes a published post back to being a draft
+func (s *Store) UnpublishPost(ctx context.Context, p models.Post) error {
+	return s.TransitionPost(ctx, &p, models.DRAFT, "")
+}
+
+//UpdatePost saves the post's title, slug, content (both Markdown and rendered), publishing date and category and bumps its updated_at timestamp.
+//The post's tags are replaced by p.Tags unless it is nil.
+func (s *Store) UpdatePost(ctx context.Context, p *models.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.postIndex(p.ID)
+
+	if i == -1 {
+		return errors.New("Could not update post")
+	}
+
+	if s.titleOrSlugTaken(*p) {
+		return models.ErrPostExists
+	}
+
+	p.UpdatedAt = time.Now()
+
+	updated := row(*p)
+	existing := &s.posts[i]
+
+	existing.Title = updated.Title
+	existing.Slug = updated.Slug
+	existing.Content = updated.Content
+	existing.ContentHTML = updated.ContentHTML
+	existing.Excerpt = updated.Excerpt
+	existing.ReadingTime = updated.ReadingTime
+	existing.PublishAt = updated.PublishAt
+	existing.CategoryID = updated.CategoryID
+	existing.UpdatedAt = updated.UpdatedAt
+
+	if p.Tags != nil {
+		s.setPostTags(p.ID, p.Tags)
+	}
+
+	s.snapshot(p.ID)
+
+	return nil
+}
+
+//TransitionPost moves a post to a new status, recording the reviewer's note if any.
+//It fails with ErrInvalidTransition if the move is not allowed from the post's current status
+//or the post's status was changed by someone else in the meantime.
+func (s *Store) TransitionPost(ctx context.Context, p *models.Post, status int, note string) error {
+
+	if !models.CanTransition(p.Status, status) {
+		return models.ErrInvalidTransition
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.postIndex(p.ID)
+
+	if i == -1 || s.posts[i].Status != p.Status {
+		return models.ErrInvalidTransition
+	}
+
+	now := time.Now()
+
+	s.posts[i].Status = status
+	s.posts[i].ReviewNote = note
+	s.posts[i].UpdatedAt = now
+
+	p.Status = status
+	p.ReviewNote = note
+	p.UpdatedAt = now
+
+	s.snapshot(p.ID)
+
+	return nil
+}
+
+//FindPostsAwaitingReview lists posts submitted for review, the ones waiting the longest first
+func (s *Store) FindPostsAwaitingReview(ctx context.Context) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var posts []models.Post
+
+	for _, p := range s.posts {
+		if p.Status != models.IN_REVIEW {
+			continue
+		}
+
+		if post, ok := s.withAuthor(p); ok {
+			posts = append(posts, post)
+		}
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].UpdatedAt.Before(posts[j].UpdatedAt)
+	})
+
+	return posts, nil
+}
+
+//FindDuePosts lists scheduled posts whose publishing date is not after now
+func (s *Store) FindDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var posts []models.Post
+
+	for _, p := range s.posts {
+		if p.Status == models.SCHEDULED && p.PublishAt != nil && !p.PublishAt.After(now) {
+			posts = append(posts, copyPost(p))
+		}
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].PublishAt.Before(*posts[j].PublishAt)
+	})
+
+	return posts, nil
+}
+
+func (s *Store) hasTag(postID int, slug string) bool {
+	for _, id := range s.postTags[postID] {
+		for _, t := range s.tags {
+			if t.ID == id && t.Slug == slug {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+//published lists the published posts matching the filter in the order it asks for, ignoring pagination
+func (s *Store) published(f models.PostFilter) []models.Post {
+	var posts []models.Post
+
+	for _, p := range s.posts {
+		post, ok := s.withAuthor(p)
+
+		switch {
+		case !ok || p.Status != models.PUBLISHED:
+		case f.Author != "" && post.User.Moniker != f.Author:
+		case !f.From.IsZero() && p.CreatedAt.Before(f.From):
+		case !f.To.IsZero() && p.CreatedAt.After(f.To):
+		case f.Tag != "" && !s.hasTag(p.ID, f.Tag):
+		default:
+			posts = append(posts, post)
+		}
+	}
+
+	date := func(p models.Post) time.Time {
+		if f.SortBy == models.SORT_UPDATED_AT {
+			return p.UpdatedAt
+		}
+
+		return p.CreatedAt
+	}
+
+	//Posts are already ordered by id, so only the dates need comparing
+	sort.SliceStable(posts, func(i, j int) bool {
+		return date(posts[i]).Before(date(posts[j]))
+	})
+
+	if !f.Ascending {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+
+	return posts
+}
+
+//page cuts out the rows the filter's page and per page ask for
+func page(f models.PostFilter, count int) (int, int) {
+	start := f.Offset()
+
+	if start > count {
+		start = count
+	}
+
+	end := start + f.Limit()
+
+	if end > count {
+		end = count
+	}
+
+	return start, end
+}
+
+func (s *Store) FindPublishedPosts(ctx context.Context, f models.PostFilter) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := s.published(f)
+
+	start, end := page(f, len(posts))
+
+	if start == end {
+		return nil, nil
+	}
+
+	return posts[start:end], nil
+}
+
+func (s *Store) CountPublishedPosts(ctx context.Context, f models.PostFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.published(f)), nil
+}
+
+func (s *Store) FindPublishedPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, p := range s.posts {
+		if p.Slug != slug || p.Status != models.PUBLISHED {
+			continue
+		}
+
+		post, ok := s.withAuthor(p)
+
+		if !ok {
+			break
+		}
+
+		post.Tags = s.tagsOf(p.ID)
+
+		return post, nil
+	}
+
+	return models.Post{}, errors.New("Post does not exists")
+}
+
+//words splits text into lower cased words, the way the full text index tokenizes it
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+//hits counts the words in text that are search terms
+func hits(text string, terms map[string]bool) int {
+	count := 0
+
+	for _, word := range words(text) {
+		if terms[word] {
+			count++
+		}
+	}
+
+	return count
+}
+
+//snippet picks the part of the post around the first match, marking every matched word
+func snippet(p models.Post, terms map[string]bool) string {
+	text := p.Content
+
+	if hits(text, terms) == 0 {
+		text = p.Title
+	}
+
+	fields := strings.Fields(text)
+
+	first := 0
+
+	for i, field := range fields {
+		if hits(field, terms) > 0 {
+			first = i
+			break
+		}
+	}
+
+	start := first - SNIPPET_WORDS/2
+
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + SNIPPET_WORDS
+
+	if end > len(fields) {
+		end = len(fields)
+	}
+
+	marked := make([]string, 0, end-start)
+
+	for _, field := range fields[start:end] {
+		marked = append(marked, mark(field, terms))
+	}
+
+	result := strings.Join(marked, " ")
+
+	if start > 0 {
+		result = "…" + result
+	}
+
+	if end < len(fields) {
+		result += "…"
+	}
+
+	return result
+}
+
+//mark wraps the words of field that are search terms in the highlight markers, leaving punctuation around them alone
+func mark(field string, terms map[string]bool) string {
+	var b strings.Builder
+
+	runes := []rune(field)
+
+	for i := 0; i < len(runes); {
+		j := i
+
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsNumber(runes[j])) {
+			j++
+		}
+
+		if j == i {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		word := string(runes[i:j])
+
+		if terms[strings.ToLower(word)] {
+			word = models.HIGHLIGHT_START + word + models.HIGHLIGHT_END
+		}
+
+		b.WriteString(word)
+		i = j
+	}
+
+	return b.String()
+}
+
+//Search looks for posts whose title or content contain every word of the query.
+//Matches in the title count five times as much as matches in the content.
+func (s *Store) Search(ctx context.Context, q models.SearchQuery, pageNumber int) ([]models.SearchResult, error) {
+	var results []models.SearchResult
+
+	if strings.TrimSpace(strings.Replace(q.Terms, `"`, "", -1)) == "" {
+		return results, models.ErrEmptySearch
+	}
+
+	terms := make(map[string]bool)
+
+	for _, word := range words(q.Terms) {
+		terms[word] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	scores := make(map[int]int)
+
+	for _, p := range s.posts {
+		switch {
+		case q.IncludeUnpublished && q.UserID == 0:
+		case p.Status == models.PUBLISHED:
+		case q.IncludeUnpublished && p.UserID == q.UserID:
+		default:
+			continue
+		}
+
+		post, ok := s.withAuthor(p)
+
+		if !ok || len(terms) == 0 {
+			continue
+		}
+
+		found := words(p.Title + " " + p.Content)
+
+		matchesAll := true
+
+		for term := range terms {
+			matchesAll = matchesAll && contains(found, term)
+		}
+
+		if !matchesAll {
+			continue
+		}
+
+		scores[p.ID] = 5*hits(p.Title, terms) + hits(p.Content, terms)
+
+		results = append(results, models.SearchResult{Post: post, Snippet: snippet(p, terms)})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if scores[results[i].ID] != scores[results[j].ID] {
+			return scores[results[i].ID] > scores[results[j].ID]
+		}
+
+		return results[i].ID > results[j].ID
+	})
+
+	start, end := page(models.PostFilter{Page: pageNumber}, len(results))
+
+	if start == end {
+		return nil, nil
+	}
+
+	return results[start:end], nil
+}
+
+func contains(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+
+	return false
+}
+
+//snapshot records the current state of a post as a new revision, dated when the post was last updated
+func (s *Store) snapshot(postID int) {
+	p := s.posts[s.postIndex(postID)]
+
+	s.revisions = append(s.revisions, models.Revision{
+		ID:        s.nextID("revisions"),
+		PostID:    p.ID,
+		Title:     p.Title,
+		Content:   p.Content,
+		Status:    p.Status,
+		CreatedAt: p.UpdatedAt,
+	})
+}
+
+func (s *Store) FindRevisionsByPost(ctx context.Context, postID int) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var revisions []models.Revision
+
+	//Newest first
+	for i := len(s.revisions) - 1; i >= 0; i-- {
+		if s.revisions[i].PostID == postID {
+			revisions = append(revisions, s.revisions[i])
+		}
+	}
+
+	return revisions, nil
+}
+
+func (s *Store) FindRevisionByID(ctx context.Context, id int) (models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.revisions {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+
+	return models.Revision{}, errors.New("Revision does not exist")
+}
+
+func byName(tags []models.Tag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+}
+
+//FindTags lists every tag along with how many published posts use it
+func (s *Store) FindTags(ctx context.Context) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tags []models.Tag
+
+	for _, t := range s.tags {
+		for _, p := range s.posts {
+			if p.Status == models.PUBLISHED && s.hasTag(p.ID, t.Slug) {
+				t.Posts++
+			}
+		}
+
+		tags = append(tags, t)
+	}
+
+	byName(tags)
+
+	return tags, nil
+}
+
+func (s *Store) FindTagBySlug(ctx context.Context, slug string) (models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, t := range s.tags {
+		if t.Slug == slug {
+			return t, nil
+		}
+	}
+
+	return models.Tag{}, errors.New("Tag does not exist")
+}
+
+func (s *Store) tagsOf(postID int) []models.Tag {
+	var tags []models.Tag
+
+	for _, id := range s.postTags[postID] {
+		for _, t := range s.tags {
+			if t.ID == id {
+				tags = append(tags, t)
+			}
+		}
+	}
+
+	byName(tags)
+
+	return tags
+}
+
+func (s *Store) FindTagsByPost(ctx context.Context, postID int) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.tagsOf(postID), nil
+}
+
+func (s *Store) tagIndex(id int) int {
+	for i, t := range s.tags {
+		if t.ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+//RenameTag saves the tag's new name and slug
+func (s *Store) RenameTag(ctx context.Context, t *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.tagIndex(t.ID)
+
+	if i == -1 {
+		return errors.New("Could not rename tag")
+	}
+
+	for _, existing := range s.tags {
+		if existing.ID != t.ID && (existing.Name == t.Name || existing.Slug == t.Slug) {
+			return models.ErrTagExists
+		}
+	}
+
+	s.tags[i].Name = t.Name
+	s.tags[i].Slug = t.Slug
+
+	return nil
+}
+
+//MergeTags moves every post tagged with from over to into, then deletes from
+func (s *Store) MergeTags(ctx context.Context, from, into models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for postID, ids := range s.postTags {
+		if containsID(ids, from.ID) && !containsID(ids, into.ID) {
+			s.postTags[postID] = append(ids, into.ID)
+		}
+	}
+
+	return s.deleteTag(from)
+}
+
+//DeleteTag deletes a tag and removes it from every post
+func (s *Store) DeleteTag(ctx context.Context, t models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deleteTag(t)
+}
+
+func (s *Store) deleteTag(t models.Tag) error {
+
+	for postID, ids := range s.postTags {
+		s.postTags[postID] = removeID(ids, t.ID)
+	}
+
+	i := s.tagIndex(t.ID)
+
+	if i == -1 {
+		return errors.New("Tag does not exist")
+	}
+
+	s.tags = append(s.tags[:i], s.tags[i+1:]...)
+
+	return nil
+}
+
+//setPostTags replaces the tags of a post.
+//Tags are matched by slug and created if no tag has their name or slug yet.
+func (s *Store) setPostTags(postID int, tags []models.Tag) {
+
+	s.postTags[postID] = nil
+
+	for _, t := range tags {
+
+		taken := false
+
+		for _, existing := range s.tags {
+			taken = taken || existing.Name == t.Name || existing.Slug == t.Slug
+		}
+
+		if !taken {
+			s.tags = append(s.tags, models.Tag{ID: s.nextID("tags"), Name: t.Name, Slug: t.Slug})
+		}
+
+		for _, existing := range s.tags {
+			if existing.Slug == t.Slug && !containsID(s.postTags[postID], existing.ID) {
+				s.postTags[postID] = append(s.postTags[postID], existing.ID)
+			}
+		}
+	}
+}
+
+func containsID(ids []int, id int) bool {
+	for _, existing := range ids {
+		if existing == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func removeID(ids []int, id int) []int {
+	var kept []int
+
+	for _, existing := range ids {
+		if existing != id {
+			kept = append(kept, existing)
+		}
+	}
+
+	return kept
+}
+
+func copyCategory(c models.Category) models.Category {
+	if c.ParentID != nil {
+		parentID := *c.ParentID
+		c.ParentID = &parentID
+	}
+
+	return c
+}
+
+func (s *Store) FindCategories(ctx context.Context) ([]models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var categories []models.Category
+
+	for _, c := range s.categories {
+		categories = append(categories, copyCategory(c))
+	}
+
+	sort.SliceStable(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+
+	return categories, nil
+}
+
+func (s *Store) FindCategoryBySlug(ctx context.Context, slug string) (models.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.categories {
+		if c.Slug == slug {
+			return copyCategory(c), nil
+		}
+	}
+
+	return models.Category{}, errors.New("Category does not exist")
+}
+
+func (s *Store) CreateCategory(ctx context.Context, c *models.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.categories {
+		if existing.Slug == c.Slug {
+			return models.ErrCategoryExists
+		}
+	}
+
+	c.ID = s.nextID("categories")
+
+	s.categories = append(s.categories, copyCategory(*c))
+
+	return nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, userID int, family string) (string, error) {
+
+	token, family, err := models.NewRefreshToken(family)
+
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := models.HashRefreshToken(token)
+
+	for _, t := range s.refreshTokens {
+		if t.TokenHash == hash {
+			return "", errors.New("Could not save the refresh token")
+		}
+	}
+
+	now := time.Now()
+
+	s.refreshTokens = append(s.refreshTokens, models.RefreshToken{
+		ID:        s.nextID("refresh_tokens"),
+		UserID:    userID,
+		Family:    family,
+		TokenHash: hash,
+		ExpiresAt: now.Add(models.REFRESH_TOKEN_TTL),
+		CreatedAt: now,
+	})
+
+	return token, nil
+}
+
+func (s *Store) FindRefreshToken(ctx context.Context, token string) (models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash := models.HashRefreshToken(token)
+
+	for _, t := range s.refreshTokens {
+		if t.TokenHash == hash {
+			return copyRefreshToken(t), nil
+		}
+	}
+
+	return models.RefreshToken{}, errors.New("Refresh token does not exist")
+}
+
+//copyRefreshToken returns a copy of t that doesn't share its revocation date
+func copyRefreshToken(t models.RefreshToken) models.RefreshToken {
+	if t.RevokedAt != nil {
+		revokedAt := *t.RevokedAt
+		t.RevokedAt = &revokedAt
+	}
+
+	return t
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, t models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.refreshTokens {
+		if existing.ID == t.ID && existing.RevokedAt == nil {
+			now := time.Now()
+			s.refreshTokens[i].RevokedAt = &now
+			return nil
+		}
+	}
+
+	return models.ErrRefreshTokenRevoked
+}
+
+func (s *Store) RevokeTokenFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for i, t := range s.refreshTokens {
+		if t.Family == family && t.RevokedAt == nil {
+			revokedAt := now
+			s.refreshTokens[i].RevokedAt = &revokedAt
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for i, t := range s.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			revokedAt := now
+			s.refreshTokens[i].RevokedAt = &revokedAt
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeRoleRefreshTokens(ctx context.Context, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holders := make(map[int]bool)
+
+	for _, u := range s.users {
+		if u.Role == role {
+			holders[u.ID] = true
+		}
+	}
+
+	now := time.Now()
+
+	for i, t := range s.refreshTokens {
+		if holders[t.UserID] && t.RevokedAt == nil {
+			revokedAt := now
+			s.refreshTokens[i].RevokedAt = &revokedAt
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for revoked, until := range s.revokedTokens {
+		if until.Before(now) {
+			delete(s.revokedTokens, revoked)
+		}
+	}
+
+	if _, ok := s.revokedTokens[jti]; !ok && expiresAt.After(now) {
+		s.revokedTokens[jti] = expiresAt
+	}
+
+	return nil
+}
+
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revokedTokens[jti]
+
+	return ok, nil
+}
+
+func (s *Store) CreatePasswordReset(ctx context.Context, userID int) (string, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not generate the password reset token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := models.HashToken(token)
+
+	for _, r := range s.resets {
+		if r.TokenHash == hash {
+			return "", errors.New("Could not save the password reset token")
+		}
+	}
+
+	now := time.Now()
+
+	s.resets = append(s.resets, models.PasswordReset{
+		ID:        s.nextID("password_resets"),
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: now.Add(models.PASSWORD_RESET_TTL),
+		CreatedAt: now,
+	})
+
+	return token, nil
+}
+
+func (s *Store) FindPasswordReset(ctx context.Context, token string) (models.PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash := models.HashToken(token)
+
+	for _, r := range s.resets {
+		if r.TokenHash == hash {
+			if r.UsedAt != nil {
+				usedAt := *r.UsedAt
+				r.UsedAt = &usedAt
+			}
+
+			return r, nil
+		}
+	}
+
+	return models.PasswordReset{}, errors.New("Password reset token does not exist")
+}
+
+func (s *Store) UsePasswordReset(ctx context.Context, r models.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+
+	for _, existing := range s.resets {
+		if existing.ID == r.ID && existing.UsedAt == nil {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return models.ErrPasswordResetUsed
+	}
+
+	now := time.Now()
+
+	for i, existing := range s.resets {
+		if existing.UserID == r.UserID && existing.UsedAt == nil {
+			usedAt := now
+			s.resets[i].UsedAt = &usedAt
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) CreateEmailChange(ctx context.Context, userID int, email string) (string, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not generate the email change token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := models.HashToken(token)
+
+	for _, c := range s.emailChanges {
+		if c.TokenHash == hash {
+			return "", errors.New("Could not save the email change token")
+		}
+	}
+
+	now := time.Now()
+
+	s.emailChanges = append(s.emailChanges, models.EmailChange{
+		ID:        s.nextID("email_changes"),
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hash,
+		ExpiresAt: now.Add(models.EMAIL_CHANGE_TTL),
+		CreatedAt: now,
+	})
+
+	return token, nil
+}
+
+func (s *Store) FindEmailChange(ctx context.Context, token string) (models.EmailChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hash := models.HashToken(token)
+
+	for _, c := range s.emailChanges {
+		if c.TokenHash == hash {
+			if c.UsedAt != nil {
+				usedAt := *c.UsedAt
+				c.UsedAt = &usedAt
+			}
+
+			return c, nil
+		}
+	}
+
+	return models.EmailChange{}, errors.New("Email change token does not exist")
+}
+
+func (s *Store) UseEmailChange(ctx context.Context, c models.EmailChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := false
+
+	for _, existing := range s.emailChanges {
+		if existing.ID == c.ID && existing.UsedAt == nil {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return models.ErrEmailChangeUsed
+	}
+
+	now := time.Now()
+
+	for i, existing := range s.emailChanges {
+		if existing.UserID == c.UserID && existing.UsedAt == nil {
+			usedAt := now
+			s.emailChanges[i].UsedAt = &usedAt
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) QueueEmail(ctx context.Context, e *models.Email) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	e.ID = s.nextID("outbox")
+	e.Attempts = 0
+	e.LastError = ""
+	e.NextAttemptAt = now
+	e.SentAt = nil
+	e.CreatedAt = now
+
+	s.outbox = append(s.outbox, *e)
+
+	return nil
+}
+
+func (s *Store) FindDueEmails(ctx context.Context, now time.Time, limit int) ([]models.Email, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	emails := []models.Email{}
+
+	for _, e := range s.outbox {
+		if len(emails) == limit {
+			break
+		}
+
+		if e.SentAt == nil && e.Attempts < models.MAX_EMAIL_ATTEMPTS && !e.NextAttemptAt.After(now) {
+			emails = append(emails, e)
+		}
+	}
+
+	return emails, nil
+}
+
+func (s *Store) ClaimEmail(ctx context.Context, e models.Email, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, existing := range s.outbox {
+		if existing.ID == e.ID && existing.Attempts == e.Attempts && existing.SentAt == nil {
+			s.outbox[i].Attempts++
+			s.outbox[i].NextAttemptAt = until
+			return nil
+		}
+	}
+
+	return models.ErrEmailClaimed
+}
+
+func (s *Store) MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, e := range s.outbox {
+		if e.ID == id {
+			s.outbox[i].SentAt = &sentAt
+			s.outbox[i].LastError = ""
+			s.outbox[i].Text = ""
+			s.outbox[i].HTML = ""
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) MarkEmailFailed(ctx context.Context, id int, reason string, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, e := range s.outbox {
+		if e.ID == id {
+			s.outbox[i].LastError = reason
+			s.outbox[i].NextAttemptAt = retryAt
+
+			if e.Attempts >= models.MAX_EMAIL_ATTEMPTS {
+				s.outbox[i].Text = ""
+				s.outbox[i].HTML = ""
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) FindRoles(ctx context.Context) ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]models.Role{}, s.roles...), nil
+}
+
+func (s *Store) FindRole(ctx context.Context, name string) (models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, r := range s.roles {
+		if r.Name == name {
+			return r, nil
+		}
+	}
+
+	return models.Role{}, errors.New("Role not found")
+}
+
+//UpdateRolePermissions replaces every permission of the role.
+//The role is given a new slice of permissions, the one handed out before isn't changed.
+func (s *Store) UpdateRolePermissions(ctx context.Context, name string, permissions []string) error {
+
+	if name == models.OWNER {
+		return models.ErrRoleLocked
+	}
+
+	permissions, err := models.ValidatePermissions(permissions)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.roles {
+		if r.Name != name {
+			continue
+		}
+
+		s.roles[i].Permissions = permissions
+
+		for j, u := range s.users {
+			if u.Role == name {
+				s.users[j].PermissionsVersion++
+			}
+		}
+
+		return nil
+	}
+
+	return errors.New("Role not found")
+}
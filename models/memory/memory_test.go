@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/storetest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+var ctx = context.Background()
+
+func TestStore(t *testing.T) {
+
+	storetest.Run(t, func(t *testing.T) models.DataStore {
+		return New()
+	})
+}
+
+func TestOnlyOneOfManyConcurrentPostsWithTheSameTitleIsCreated(t *testing.T) {
+
+	s := New()
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 20)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs <- s.CreatePost(ctx, &models.Post{Title: "Go is awesome", Slug: "Go-is-awesome", Content: "Go is awesome", UserID: 1})
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	created := 0
+
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+
+		assert.Equal(t, models.ErrPostExists, err)
+	}
+
+	assert.Equal(t, 1, created)
+
+	revisions, err := s.FindRevisionsByPost(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
+
+func TestStoredPostsCannotBeChangedFromOutside(t *testing.T) {
+
+	s := New()
+
+	category := 1
+
+	p := &models.Post{Title: "Go is awesome", Slug: "Go-is-awesome", Content: "Go is awesome", CategoryID: &category}
+
+	if err := s.CreatePost(ctx, p); err != nil {
+		t.Fatal(err)
+	}
+
+	category = 2
+
+	found, err := s.FindPostByID(ctx, p.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	*found.CategoryID = 3
+
+	found, _ = s.FindPostByID(ctx, p.ID)
+
+	assert.Equal(t, 1, *found.CategoryID)
+}
@@ -2,19 +2,210 @@ package mocks
 
 import mock "github.com/stretchr/testify/mock"
 import models "github.com/adelowo/reblog/models"
+import context "context"
+import time "time"
 
 // DataStore is an autogenerated mock type for the DataStore type
 type DataStore struct {
 	mock.Mock
 }
 
-// CreateCollaborator provides a mock function with given fields: email
-func (_m *DataStore) CreateCollaborator(email string) error {
-	ret := _m.Called(email)
+// ArePermissionsOutdated provides a mock function with given fields: ctx, subject, version
+func (_m *DataStore) ArePermissionsOutdated(ctx context.Context, subject string, version int) (bool, error) {
+	ret := _m.Called(ctx, subject, version)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, int) bool); ok {
+		r0 = rf(ctx, subject, version)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int) error); ok {
+		r1 = rf(ctx, subject, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimEmail provides a mock function with given fields: ctx, e, until
+func (_m *DataStore) ClaimEmail(ctx context.Context, e models.Email, until time.Time) error {
+	ret := _m.Called(ctx, e, until)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Email, time.Time) error); ok {
+		r0 = rf(ctx, e, until)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CountPublishedPosts provides a mock function with given fields: ctx, f
+func (_m *DataStore) CountPublishedPosts(ctx context.Context, f models.PostFilter) (int, error) {
+	ret := _m.Called(ctx, f)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, models.PostFilter) int); ok {
+		r0 = rf(ctx, f)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.PostFilter) error); ok {
+		r1 = rf(ctx, f)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountUsers provides a mock function with given fields: ctx, f
+func (_m *DataStore) CountUsers(ctx context.Context, f models.UserFilter) (int, error) {
+	ret := _m.Called(ctx, f)
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func(context.Context, models.UserFilter) int); ok {
+		r0 = rf(ctx, f)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.UserFilter) error); ok {
+		r1 = rf(ctx, f)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateCategory provides a mock function with given fields: ctx, c
+func (_m *DataStore) CreateCategory(ctx context.Context, c *models.Category) error {
+	ret := _m.Called(ctx, c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Category) error); ok {
+		r0 = rf(ctx, c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateCollaborator provides a mock function with given fields: ctx, email, role, expiresAt
+func (_m *DataStore) CreateCollaborator(ctx context.Context, email string, role string, expiresAt time.Time) (models.Collaborator, error) {
+	ret := _m.Called(ctx, email, role, expiresAt)
+
+	var r0 models.Collaborator
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time) models.Collaborator); ok {
+		r0 = rf(ctx, email, role, expiresAt)
+	} else {
+		r0 = ret.Get(0).(models.Collaborator)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time) error); ok {
+		r1 = rf(ctx, email, role, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateEmailChange provides a mock function with given fields: ctx, userID, email
+func (_m *DataStore) CreateEmailChange(ctx context.Context, userID int, email string) (string, error) {
+	ret := _m.Called(ctx, userID, email)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) string); ok {
+		r0 = rf(ctx, userID, email)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, userID, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreatePasswordReset provides a mock function with given fields: ctx, userID
+func (_m *DataStore) CreatePasswordReset(ctx context.Context, userID int) (string, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, int) string); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreatePost provides a mock function with given fields: ctx, p
+func (_m *DataStore) CreatePost(ctx context.Context, p *models.Post) error {
+	ret := _m.Called(ctx, p)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Post) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateRefreshToken provides a mock function with given fields: ctx, userID, family
+func (_m *DataStore) CreateRefreshToken(ctx context.Context, userID int, family string) (string, error) {
+	ret := _m.Called(ctx, userID, family)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) string); ok {
+		r0 = rf(ctx, userID, family)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, string) error); ok {
+		r1 = rf(ctx, userID, family)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateUser provides a mock function with given fields: ctx, u
+func (_m *DataStore) CreateUser(ctx context.Context, u *models.User) error {
+	ret := _m.Called(ctx, u)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(email)
+	if rf, ok := ret.Get(0).(func(context.Context, *models.User) error); ok {
+		r0 = rf(ctx, u)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -22,13 +213,13 @@ func (_m *DataStore) CreateCollaborator(email string) error {
 	return r0
 }
 
-// CreatePost provides a mock function with given fields: p, userType
-func (_m *DataStore) CreatePost(p models.Post, userType int) error {
-	ret := _m.Called(p, userType)
+// DeleteCollaborator provides a mock function with given fields: ctx, c
+func (_m *DataStore) DeleteCollaborator(ctx context.Context, c models.Collaborator) error {
+	ret := _m.Called(ctx, c)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Post, int) error); ok {
-		r0 = rf(p, userType)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Collaborator) error); ok {
+		r0 = rf(ctx, c)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -36,13 +227,13 @@ func (_m *DataStore) CreatePost(p models.Post, userType int) error {
 	return r0
 }
 
-// CreateUser provides a mock function with given fields: u
-func (_m *DataStore) CreateUser(u *models.User) error {
-	ret := _m.Called(u)
+// DeletePost provides a mock function with given fields: ctx, p
+func (_m *DataStore) DeletePost(ctx context.Context, p models.Post) error {
+	ret := _m.Called(ctx, p)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*models.User) error); ok {
-		r0 = rf(u)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Post) error); ok {
+		r0 = rf(ctx, p)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -50,13 +241,13 @@ func (_m *DataStore) CreateUser(u *models.User) error {
 	return r0
 }
 
-// DeleteCollaborator provides a mock function with given fields: c
-func (_m *DataStore) DeleteCollaborator(c models.Collaborator) error {
-	ret := _m.Called(c)
+// DeleteTag provides a mock function with given fields: ctx, t
+func (_m *DataStore) DeleteTag(ctx context.Context, t models.Tag) error {
+	ret := _m.Called(ctx, t)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Collaborator) error); ok {
-		r0 = rf(c)
+	if rf, ok := ret.Get(0).(func(context.Context, models.Tag) error); ok {
+		r0 = rf(ctx, t)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -64,13 +255,13 @@ func (_m *DataStore) DeleteCollaborator(c models.Collaborator) error {
 	return r0
 }
 
-// DeletePost provides a mock function with given fields: p
-func (_m *DataStore) DeletePost(p models.Post) error {
-	ret := _m.Called(p)
+// DeleteUser provides a mock function with given fields: ctx, u
+func (_m *DataStore) DeleteUser(ctx context.Context, u models.User) error {
+	ret := _m.Called(ctx, u)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Post) error); ok {
-		r0 = rf(p)
+	if rf, ok := ret.Get(0).(func(context.Context, models.User) error); ok {
+		r0 = rf(ctx, u)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -78,13 +269,13 @@ func (_m *DataStore) DeletePost(p models.Post) error {
 	return r0
 }
 
-// DeleteUser provides a mock function with given fields: u
-func (_m *DataStore) DeleteUser(u models.User) error {
-	ret := _m.Called(u)
+// DeleteUserPosts provides a mock function with given fields: ctx, userID
+func (_m *DataStore) DeleteUserPosts(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.User) error); ok {
-		r0 = rf(u)
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -92,13 +283,13 @@ func (_m *DataStore) DeleteUser(u models.User) error {
 	return r0
 }
 
-// DoesUserExist provides a mock function with given fields: email, moniker
-func (_m *DataStore) DoesUserExist(email string, moniker string) bool {
-	ret := _m.Called(email, moniker)
+// DoesUserExist provides a mock function with given fields: ctx, email, moniker
+func (_m *DataStore) DoesUserExist(ctx context.Context, email string, moniker string) bool {
+	ret := _m.Called(ctx, email, moniker)
 
 	var r0 bool
-	if rf, ok := ret.Get(0).(func(string, string) bool); ok {
-		r0 = rf(email, moniker)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, email, moniker)
 	} else {
 		r0 = ret.Get(0).(bool)
 	}
@@ -106,20 +297,41 @@ func (_m *DataStore) DoesUserExist(email string, moniker string) bool {
 	return r0
 }
 
-// FindByEmail provides a mock function with given fields: email
-func (_m *DataStore) FindByEmail(email string) (models.User, error) {
-	ret := _m.Called(email)
+// FindByEmail provides a mock function with given fields: ctx, email
+func (_m *DataStore) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	ret := _m.Called(ctx, email)
+
+	var r0 models.User
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.User); ok {
+		r0 = rf(ctx, email)
+	} else {
+		r0 = ret.Get(0).(models.User)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindByID(ctx context.Context, id int) (models.User, error) {
+	ret := _m.Called(ctx, id)
 
 	var r0 models.User
-	if rf, ok := ret.Get(0).(func(string) models.User); ok {
-		r0 = rf(email)
+	if rf, ok := ret.Get(0).(func(context.Context, int) models.User); ok {
+		r0 = rf(ctx, id)
 	} else {
 		r0 = ret.Get(0).(models.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(email)
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -127,20 +339,85 @@ func (_m *DataStore) FindByEmail(email string) (models.User, error) {
 	return r0, r1
 }
 
-// FindByMoniker provides a mock function with given fields: moniker
-func (_m *DataStore) FindByMoniker(moniker string) (models.User, error) {
-	ret := _m.Called(moniker)
+// FindByMoniker provides a mock function with given fields: ctx, moniker
+func (_m *DataStore) FindByMoniker(ctx context.Context, moniker string) (models.User, error) {
+	ret := _m.Called(ctx, moniker)
 
 	var r0 models.User
-	if rf, ok := ret.Get(0).(func(string) models.User); ok {
-		r0 = rf(moniker)
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.User); ok {
+		r0 = rf(ctx, moniker)
 	} else {
 		r0 = ret.Get(0).(models.User)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(moniker)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, moniker)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindCategories provides a mock function with given fields: ctx
+func (_m *DataStore) FindCategories(ctx context.Context) ([]models.Category, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []models.Category
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Category); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Category)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindCategoryBySlug provides a mock function with given fields: ctx, slug
+func (_m *DataStore) FindCategoryBySlug(ctx context.Context, slug string) (models.Category, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 models.Category
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Category); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.Category)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindCollaboratorByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindCollaboratorByID(ctx context.Context, id int) (models.Collaborator, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 models.Collaborator
+	if rf, ok := ret.Get(0).(func(context.Context, int) models.Collaborator); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Collaborator)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -148,20 +425,152 @@ func (_m *DataStore) FindByMoniker(moniker string) (models.User, error) {
 	return r0, r1
 }
 
-// FindCollaboratorByToken provides a mock function with given fields: token
-func (_m *DataStore) FindCollaboratorByToken(token string) (models.Collaborator, error) {
-	ret := _m.Called(token)
+// FindCollaboratorByToken provides a mock function with given fields: ctx, token
+func (_m *DataStore) FindCollaboratorByToken(ctx context.Context, token string) (models.Collaborator, error) {
+	ret := _m.Called(ctx, token)
 
 	var r0 models.Collaborator
-	if rf, ok := ret.Get(0).(func(string) models.Collaborator); ok {
-		r0 = rf(token)
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Collaborator); ok {
+		r0 = rf(ctx, token)
 	} else {
 		r0 = ret.Get(0).(models.Collaborator)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(token)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindCollaborators provides a mock function with given fields: ctx
+func (_m *DataStore) FindCollaborators(ctx context.Context) ([]models.Collaborator, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []models.Collaborator
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Collaborator); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Collaborator)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDueEmails provides a mock function with given fields: ctx, now, limit
+func (_m *DataStore) FindDueEmails(ctx context.Context, now time.Time, limit int) ([]models.Email, error) {
+	ret := _m.Called(ctx, now, limit)
+
+	var r0 []models.Email
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, int) []models.Email); ok {
+		r0 = rf(ctx, now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Email)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, int) error); ok {
+		r1 = rf(ctx, now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindDuePosts provides a mock function with given fields: ctx, now
+func (_m *DataStore) FindDuePosts(ctx context.Context, now time.Time) ([]models.Post, error) {
+	ret := _m.Called(ctx, now)
+
+	var r0 []models.Post
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []models.Post); ok {
+		r0 = rf(ctx, now)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Post)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindEmailChange provides a mock function with given fields: ctx, token
+func (_m *DataStore) FindEmailChange(ctx context.Context, token string) (models.EmailChange, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 models.EmailChange
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.EmailChange); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(models.EmailChange)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindPasswordReset provides a mock function with given fields: ctx, token
+func (_m *DataStore) FindPasswordReset(ctx context.Context, token string) (models.PasswordReset, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 models.PasswordReset
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.PasswordReset); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(models.PasswordReset)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindPostByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindPostByID(ctx context.Context, id int) (models.Post, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 models.Post
+	if rf, ok := ret.Get(0).(func(context.Context, int) models.Post); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Post)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -169,20 +578,20 @@ func (_m *DataStore) FindCollaboratorByToken(token string) (models.Collaborator,
 	return r0, r1
 }
 
-// FindPostByID provides a mock function with given fields: id
-func (_m *DataStore) FindPostByID(id int) (models.Post, error) {
-	ret := _m.Called(id)
+// FindPostBySlug provides a mock function with given fields: ctx, slug
+func (_m *DataStore) FindPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	ret := _m.Called(ctx, slug)
 
 	var r0 models.Post
-	if rf, ok := ret.Get(0).(func(int) models.Post); ok {
-		r0 = rf(id)
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Post); ok {
+		r0 = rf(ctx, slug)
 	} else {
 		r0 = ret.Get(0).(models.Post)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(int) error); ok {
-		r1 = rf(id)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -190,20 +599,43 @@ func (_m *DataStore) FindPostByID(id int) (models.Post, error) {
 	return r0, r1
 }
 
-// FindPostBySlug provides a mock function with given fields: slug
-func (_m *DataStore) FindPostBySlug(slug string) (models.Post, error) {
-	ret := _m.Called(slug)
+// FindPostByTitle provides a mock function with given fields: ctx, title
+func (_m *DataStore) FindPostByTitle(ctx context.Context, title string) (models.Post, error) {
+	ret := _m.Called(ctx, title)
 
 	var r0 models.Post
-	if rf, ok := ret.Get(0).(func(string) models.Post); ok {
-		r0 = rf(slug)
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Post); ok {
+		r0 = rf(ctx, title)
 	} else {
 		r0 = ret.Get(0).(models.Post)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(slug)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, title)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindPostsAwaitingReview provides a mock function with given fields: ctx
+func (_m *DataStore) FindPostsAwaitingReview(ctx context.Context) ([]models.Post, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []models.Post
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Post); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Post)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -211,20 +643,20 @@ func (_m *DataStore) FindPostBySlug(slug string) (models.Post, error) {
 	return r0, r1
 }
 
-// FindPostByTitle provides a mock function with given fields: title
-func (_m *DataStore) FindPostByTitle(title string) (models.Post, error) {
-	ret := _m.Called(title)
+// FindPublishedPostBySlug provides a mock function with given fields: ctx, slug
+func (_m *DataStore) FindPublishedPostBySlug(ctx context.Context, slug string) (models.Post, error) {
+	ret := _m.Called(ctx, slug)
 
 	var r0 models.Post
-	if rf, ok := ret.Get(0).(func(string) models.Post); ok {
-		r0 = rf(title)
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Post); ok {
+		r0 = rf(ctx, slug)
 	} else {
 		r0 = ret.Get(0).(models.Post)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(title)
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -232,13 +664,643 @@ func (_m *DataStore) FindPostByTitle(title string) (models.Post, error) {
 	return r0, r1
 }
 
-// UnpublishPost provides a mock function with given fields: p
-func (_m *DataStore) UnpublishPost(p models.Post) error {
-	ret := _m.Called(p)
+// FindPublishedPosts provides a mock function with given fields: ctx, f
+func (_m *DataStore) FindPublishedPosts(ctx context.Context, f models.PostFilter) ([]models.Post, error) {
+	ret := _m.Called(ctx, f)
+
+	var r0 []models.Post
+	if rf, ok := ret.Get(0).(func(context.Context, models.PostFilter) []models.Post); ok {
+		r0 = rf(ctx, f)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Post)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.PostFilter) error); ok {
+		r1 = rf(ctx, f)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRefreshToken provides a mock function with given fields: ctx, token
+func (_m *DataStore) FindRefreshToken(ctx context.Context, token string) (models.RefreshToken, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 models.RefreshToken
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.RefreshToken); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(models.RefreshToken)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRevisionByID provides a mock function with given fields: ctx, id
+func (_m *DataStore) FindRevisionByID(ctx context.Context, id int) (models.Revision, error) {
+	ret := _m.Called(ctx, id)
+
+	var r0 models.Revision
+	if rf, ok := ret.Get(0).(func(context.Context, int) models.Revision); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(models.Revision)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRevisionsByPost provides a mock function with given fields: ctx, postID
+func (_m *DataStore) FindRevisionsByPost(ctx context.Context, postID int) ([]models.Revision, error) {
+	ret := _m.Called(ctx, postID)
+
+	var r0 []models.Revision
+	if rf, ok := ret.Get(0).(func(context.Context, int) []models.Revision); ok {
+		r0 = rf(ctx, postID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Revision)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, postID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRole provides a mock function with given fields: ctx, name
+func (_m *DataStore) FindRole(ctx context.Context, name string) (models.Role, error) {
+	ret := _m.Called(ctx, name)
+
+	var r0 models.Role
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Role); ok {
+		r0 = rf(ctx, name)
+	} else {
+		r0 = ret.Get(0).(models.Role)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindRoles provides a mock function with given fields: ctx
+func (_m *DataStore) FindRoles(ctx context.Context) ([]models.Role, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []models.Role
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Role); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Role)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTagBySlug provides a mock function with given fields: ctx, slug
+func (_m *DataStore) FindTagBySlug(ctx context.Context, slug string) (models.Tag, error) {
+	ret := _m.Called(ctx, slug)
+
+	var r0 models.Tag
+	if rf, ok := ret.Get(0).(func(context.Context, string) models.Tag); ok {
+		r0 = rf(ctx, slug)
+	} else {
+		r0 = ret.Get(0).(models.Tag)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, slug)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTags provides a mock function with given fields: ctx
+func (_m *DataStore) FindTags(ctx context.Context) ([]models.Tag, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []models.Tag
+	if rf, ok := ret.Get(0).(func(context.Context) []models.Tag); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Tag)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindTagsByPost provides a mock function with given fields: ctx, postID
+func (_m *DataStore) FindTagsByPost(ctx context.Context, postID int) ([]models.Tag, error) {
+	ret := _m.Called(ctx, postID)
+
+	var r0 []models.Tag
+	if rf, ok := ret.Get(0).(func(context.Context, int) []models.Tag); ok {
+		r0 = rf(ctx, postID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Tag)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, postID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindUsers provides a mock function with given fields: ctx, f
+func (_m *DataStore) FindUsers(ctx context.Context, f models.UserFilter) ([]models.User, error) {
+	ret := _m.Called(ctx, f)
+
+	var r0 []models.User
+	if rf, ok := ret.Get(0).(func(context.Context, models.UserFilter) []models.User); ok {
+		r0 = rf(ctx, f)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.UserFilter) error); ok {
+		r1 = rf(ctx, f)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsAccessTokenRevoked provides a mock function with given fields: ctx, jti
+func (_m *DataStore) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	ret := _m.Called(ctx, jti)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, jti)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, jti)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// IsUserSuspended provides a mock function with given fields: ctx, subject
+func (_m *DataStore) IsUserSuspended(ctx context.Context, subject string) (bool, error) {
+	ret := _m.Called(ctx, subject)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, subject)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, subject)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkEmailFailed provides a mock function with given fields: ctx, id, reason, retryAt
+func (_m *DataStore) MarkEmailFailed(ctx context.Context, id int, reason string, retryAt time.Time) error {
+	ret := _m.Called(ctx, id, reason, retryAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string, time.Time) error); ok {
+		r0 = rf(ctx, id, reason, retryAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkEmailSent provides a mock function with given fields: ctx, id, sentAt
+func (_m *DataStore) MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error {
+	ret := _m.Called(ctx, id, sentAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) error); ok {
+		r0 = rf(ctx, id, sentAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MergeTags provides a mock function with given fields: ctx, from, into
+func (_m *DataStore) MergeTags(ctx context.Context, from models.Tag, into models.Tag) error {
+	ret := _m.Called(ctx, from, into)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Tag, models.Tag) error); ok {
+		r0 = rf(ctx, from, into)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// QueueEmail provides a mock function with given fields: ctx, e
+func (_m *DataStore) QueueEmail(ctx context.Context, e *models.Email) error {
+	ret := _m.Called(ctx, e)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Email) error); ok {
+		r0 = rf(ctx, e)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReactivateUser provides a mock function with given fields: ctx, userID
+func (_m *DataStore) ReactivateUser(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReassignPosts provides a mock function with given fields: ctx, fromUserID, toUserID
+func (_m *DataStore) ReassignPosts(ctx context.Context, fromUserID int, toUserID int) error {
+	ret := _m.Called(ctx, fromUserID, toUserID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, int) error); ok {
+		r0 = rf(ctx, fromUserID, toUserID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RenameTag provides a mock function with given fields: ctx, t
+func (_m *DataStore) RenameTag(ctx context.Context, t *models.Tag) error {
+	ret := _m.Called(ctx, t)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Tag) error); ok {
+		r0 = rf(ctx, t)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeAccessToken provides a mock function with given fields: ctx, jti, expiresAt
+func (_m *DataStore) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ret := _m.Called(ctx, jti, expiresAt)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) error); ok {
+		r0 = rf(ctx, jti, expiresAt)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeRefreshToken provides a mock function with given fields: ctx, t
+func (_m *DataStore) RevokeRefreshToken(ctx context.Context, t models.RefreshToken) error {
+	ret := _m.Called(ctx, t)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.RefreshToken) error); ok {
+		r0 = rf(ctx, t)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeRoleRefreshTokens provides a mock function with given fields: ctx, role
+func (_m *DataStore) RevokeRoleRefreshTokens(ctx context.Context, role string) error {
+	ret := _m.Called(ctx, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeTokenFamily provides a mock function with given fields: ctx, family
+func (_m *DataStore) RevokeTokenFamily(ctx context.Context, family string) error {
+	ret := _m.Called(ctx, family)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, family)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeUserRefreshTokens provides a mock function with given fields: ctx, userID
+func (_m *DataStore) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RotateCollaboratorToken provides a mock function with given fields: ctx, id, expiresAt
+func (_m *DataStore) RotateCollaboratorToken(ctx context.Context, id int, expiresAt time.Time) (models.Collaborator, error) {
+	ret := _m.Called(ctx, id, expiresAt)
+
+	var r0 models.Collaborator
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) models.Collaborator); ok {
+		r0 = rf(ctx, id, expiresAt)
+	} else {
+		r0 = ret.Get(0).(models.Collaborator)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Time) error); ok {
+		r1 = rf(ctx, id, expiresAt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Search provides a mock function with given fields: ctx, q, page
+func (_m *DataStore) Search(ctx context.Context, q models.SearchQuery, page int) ([]models.SearchResult, error) {
+	ret := _m.Called(ctx, q, page)
+
+	var r0 []models.SearchResult
+	if rf, ok := ret.Get(0).(func(context.Context, models.SearchQuery, int) []models.SearchResult); ok {
+		r0 = rf(ctx, q, page)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.SearchResult)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, models.SearchQuery, int) error); ok {
+		r1 = rf(ctx, q, page)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SuspendUser provides a mock function with given fields: ctx, userID, at
+func (_m *DataStore) SuspendUser(ctx context.Context, userID int, at time.Time) error {
+	ret := _m.Called(ctx, userID, at)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Time) error); ok {
+		r0 = rf(ctx, userID, at)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TransitionPost provides a mock function with given fields: ctx, p, status, note
+func (_m *DataStore) TransitionPost(ctx context.Context, p *models.Post, status int, note string) error {
+	ret := _m.Called(ctx, p, status, note)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Post, int, string) error); ok {
+		r0 = rf(ctx, p, status, note)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UnpublishPost provides a mock function with given fields: ctx, p
+func (_m *DataStore) UnpublishPost(ctx context.Context, p models.Post) error {
+	ret := _m.Called(ctx, p)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.Post) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateEmail provides a mock function with given fields: ctx, userID, email
+func (_m *DataStore) UpdateEmail(ctx context.Context, userID int, email string) error {
+	ret := _m.Called(ctx, userID, email)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, email)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePassword provides a mock function with given fields: ctx, userID, password
+func (_m *DataStore) UpdatePassword(ctx context.Context, userID int, password string) error {
+	ret := _m.Called(ctx, userID, password)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, password)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePost provides a mock function with given fields: ctx, p
+func (_m *DataStore) UpdatePost(ctx context.Context, p *models.Post) error {
+	ret := _m.Called(ctx, p)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *models.Post) error); ok {
+		r0 = rf(ctx, p)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateProfile provides a mock function with given fields: ctx, u
+func (_m *DataStore) UpdateProfile(ctx context.Context, u models.User) error {
+	ret := _m.Called(ctx, u)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.User) error); ok {
+		r0 = rf(ctx, u)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateRolePermissions provides a mock function with given fields: ctx, name, permissions
+func (_m *DataStore) UpdateRolePermissions(ctx context.Context, name string, permissions []string) error {
+	ret := _m.Called(ctx, name, permissions)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, []string) error); ok {
+		r0 = rf(ctx, name, permissions)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateUserRole provides a mock function with given fields: ctx, userID, role
+func (_m *DataStore) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	ret := _m.Called(ctx, userID, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, string) error); ok {
+		r0 = rf(ctx, userID, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UseEmailChange provides a mock function with given fields: ctx, c
+func (_m *DataStore) UseEmailChange(ctx context.Context, c models.EmailChange) error {
+	ret := _m.Called(ctx, c)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.EmailChange) error); ok {
+		r0 = rf(ctx, c)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UsePasswordReset provides a mock function with given fields: ctx, r
+func (_m *DataStore) UsePasswordReset(ctx context.Context, r models.PasswordReset) error {
+	ret := _m.Called(ctx, r)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.PasswordReset) error); ok {
+		r0 = rf(ctx, r)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// WithTx provides a mock function with given fields: ctx, fn
+func (_m *DataStore) WithTx(ctx context.Context, fn func(tx models.DataStore) error) error {
+	ret := _m.Called(ctx, fn)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Post) error); ok {
-		r0 = rf(p)
+	if rf, ok := ret.Get(0).(func(context.Context, func(tx models.DataStore) error) error); ok {
+		r0 = rf(ctx, fn)
 	} else {
 		r0 = ret.Error(0)
 	}
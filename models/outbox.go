@@ -0,0 +1,136 @@
+package models
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"time"
+)
+
+//How many times an email is tried before it is given up on
+const MAX_EMAIL_ATTEMPTS = 10
+
+//ErrEmailClaimed is returned when claiming an email another sender has claimed or sent in the meantime
+var ErrEmailClaimed = errors.New("The email has been claimed by another sender")
+
+//OutboxStore keeps the emails waiting to be sent, so none is lost when the mail server can't be reached
+type OutboxStore interface {
+	QueueEmail(ctx context.Context, e *Email) error
+	FindDueEmails(ctx context.Context, now time.Time, limit int) ([]Email, error)
+	ClaimEmail(ctx context.Context, e Email, until time.Time) error
+	MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error
+	MarkEmailFailed(ctx context.Context, id int, reason string, retryAt time.Time) error
+}
+
+//Email is a message in the outbox.
+//It is due once NextAttemptAt has passed, until it is sent or has been tried MAX_EMAIL_ATTEMPTS times.
+//Its body is blanked after that since it may hold links with tokens in them.
+type Email struct {
+	ID            int        `db:"id"`
+	To            string     `db:"recipient"`
+	Subject       string     `db:"subject"`
+	Text          string     `db:"text_body"`
+	HTML          string     `db:"html_body"`
+	Attempts      int        `db:"attempts"`
+	LastError     string     `db:"last_error"`
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	SentAt        *time.Time `db:"sent_at"`
+	CreatedAt     time.Time  `db:"created_at"`
+}
+
+//QueueEmail adds e to the outbox, due right away
+func (db *DB) QueueEmail(ctx context.Context, e *Email) error {
+
+	now := time.Now().UTC()
+
+	id, err := db.insert(ctx, `INSERT INTO outbox(recipient, subject, text_body, html_body, attempts, last_error, next_attempt_at, created_at)
+VALUES(?,?,?,?,0,'',?,?)`, e.To, e.Subject, e.Text, e.HTML, now, now)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not queue the email")
+	}
+
+	e.ID = id
+	e.Attempts = 0
+	e.LastError = ""
+	e.NextAttemptAt = now
+	e.SentAt = nil
+	e.CreatedAt = now
+
+	return nil
+}
+
+//FindDueEmails returns up to limit emails due at now, oldest first
+func (db *DB) FindDueEmails(ctx context.Context, now time.Time, limit int) ([]Email, error) {
+
+	stmt, err := db.PreparexContext(ctx, `SELECT * FROM outbox WHERE sent_at IS NULL AND attempts<? AND next_attempt_at<=?
+ORDER BY id LIMIT ?`)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	emails := []Email{}
+
+	if err = stmt.SelectContext(ctx, &emails, MAX_EMAIL_ATTEMPTS, now.UTC(), limit); err != nil {
+		return nil, errors.Wrap(err, "Could not fetch the due emails")
+	}
+
+	return emails, nil
+}
+
+//ClaimEmail counts an attempt at sending e and keeps other senders away from it until then.
+//It fails with ErrEmailClaimed if e has been claimed or sent since it was fetched, so an email is only tried by one sender at a time.
+func (db *DB) ClaimEmail(ctx context.Context, e Email, until time.Time) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE outbox SET attempts=attempts+1, next_attempt_at=? WHERE id=? AND attempts=? AND sent_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, until.UTC(), e.ID, e.Attempts)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not claim the email")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return ErrEmailClaimed
+	}
+
+	return nil
+}
+
+//MarkEmailSent records that the email went through and blanks its body
+func (db *DB) MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE outbox SET sent_at=?, last_error='', text_body='', html_body='' WHERE id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, sentAt.UTC(), id); err != nil {
+		return errors.Wrap(err, "Could not mark the email as sent")
+	}
+
+	return nil
+}
+
+//MarkEmailFailed records why the email could not be sent and when to try again.
+//The body of an email that has been tried MAX_EMAIL_ATTEMPTS times is blanked, as it won't be tried again.
+func (db *DB) MarkEmailFailed(ctx context.Context, id int, reason string, retryAt time.Time) error {
+
+	stmt, err := db.PreparexContext(ctx, `UPDATE outbox SET last_error=?, next_attempt_at=?,
+text_body=CASE WHEN attempts>=? THEN '' ELSE text_body END, html_body=CASE WHEN attempts>=? THEN '' ELSE html_body END WHERE id=?`)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, reason, retryAt.UTC(), MAX_EMAIL_ATTEMPTS, MAX_EMAIL_ATTEMPTS, id); err != nil {
+		return errors.Wrap(err, "Could not record the failed attempt")
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"time"
+)
+
+//How long a password reset link can be used for
+const PASSWORD_RESET_TTL = time.Hour
+
+//ErrPasswordResetUsed is returned when a password reset token that was already used is used again
+var ErrPasswordResetUsed = errors.New("The password reset token has already been used")
+
+type PasswordResetStore interface {
+	CreatePasswordReset(ctx context.Context, userID int) (string, error)
+	FindPasswordReset(ctx context.Context, token string) (PasswordReset, error)
+	UsePasswordReset(ctx context.Context, r PasswordReset) error
+}
+
+//PasswordReset is a single use token emailed to a user who forgot their password, only its hash is stored
+type PasswordReset struct {
+	ID        int        `db:"id"`
+	UserID    int        `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+func (r PasswordReset) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+//CreatePasswordReset issues a password reset token to the user
+func (db *DB) CreatePasswordReset(ctx context.Context, userID int) (string, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not generate the password reset token")
+	}
+
+	now := time.Now()
+
+	stmt, err := db.PreparexContext(ctx, "INSERT INTO password_resets(user_id, token_hash, expires_at, created_at) VALUES(?,?,?,?)")
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, userID, HashToken(token), now.Add(PASSWORD_RESET_TTL).UTC(), now.UTC()); err != nil {
+		return "", errors.Wrap(err, "Could not save the password reset token")
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindPasswordReset(ctx context.Context, token string) (PasswordReset, error) {
+	var r PasswordReset
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM password_resets WHERE token_hash=?")
+
+	if err != nil {
+		return r, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, HashToken(token)).StructScan(&r); err != nil {
+		return r, errors.Wrap(err, "Password reset token does not exist")
+	}
+
+	return r, nil
+}
+
+//UsePasswordReset uses up the token along with every other token the user was sent.
+//It fails with ErrPasswordResetUsed if the token was used in the meantime, so a token can only be used once.
+func (db *DB) UsePasswordReset(ctx context.Context, r PasswordReset) error {
+
+	now := time.Now().UTC()
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE password_resets SET used_at=? WHERE id=? AND used_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, now, r.ID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not use the password reset token")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return ErrPasswordResetUsed
+	}
+
+	stmt, err = db.PreparexContext(ctx, "UPDATE password_resets SET used_at=? WHERE user_id=? AND used_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, now, r.UserID); err != nil {
+		return errors.Wrap(err, "Could not use the user's other password reset tokens")
+	}
+
+	return nil
+}
@@ -1,74 +1,253 @@
 package models
 
 import (
+	"context"
+	"fmt"
 	"github.com/pkg/errors"
+	"strings"
 	"time"
 )
 
 const (
-	UNPUBLISHED = iota
+	DRAFT = iota
 	PUBLISHED
+	IN_REVIEW
+	CHANGES_REQUESTED
+	ARCHIVED
+	SCHEDULED
 )
 
+//Posts that were not published before the review workflow existed are drafts
+const UNPUBLISHED = DRAFT
+
+//ErrInvalidTransition is returned when a post cannot be moved from its current status to the requested one
+var ErrInvalidTransition = errors.New("The post cannot be moved to the requested status")
+
+//The statuses a post may move to, keyed by its current status
+var transitions = map[int][]int{
+	DRAFT:             {IN_REVIEW, PUBLISHED, SCHEDULED, ARCHIVED},
+	IN_REVIEW:         {PUBLISHED, SCHEDULED, CHANGES_REQUESTED, ARCHIVED},
+	CHANGES_REQUESTED: {IN_REVIEW, ARCHIVED},
+	PUBLISHED:         {DRAFT, ARCHIVED},
+	ARCHIVED:          {DRAFT},
+	SCHEDULED:         {PUBLISHED, DRAFT, ARCHIVED},
+}
+
+//CanTransition reports whether a post with status from may be moved to status to
+func CanTransition(from, to int) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+//StatusName returns a human readable name for a post status
+func StatusName(status int) string {
+	switch status {
+	case DRAFT:
+		return "draft"
+	case PUBLISHED:
+		return "published"
+	case IN_REVIEW:
+		return "in_review"
+	case CHANGES_REQUESTED:
+		return "changes_requested"
+	case ARCHIVED:
+		return "archived"
+	case SCHEDULED:
+		return "scheduled"
+	}
+
+	return "unknown"
+}
+
+//ErrPostExists is returned when a write would violate the unique title or slug indexes
+var ErrPostExists = errors.New("A post with the same title or slug already exists")
+
 type PostStore interface {
-	CreatePost(p Post, userType int) error
-	FindPostBySlug(slug string) (Post, error)
-	FindPostByTitle(title string) (Post, error)
-	FindPostByID(id int) (Post, error)
-	DeletePost(p Post) error
-	UnpublishPost(p Post) error
+	CreatePost(ctx context.Context, p *Post) error
+	FindPostBySlug(ctx context.Context, slug string) (Post, error)
+	FindPostByTitle(ctx context.Context, title string) (Post, error)
+	FindPostByID(ctx context.Context, id int) (Post, error)
+	DeletePost(ctx context.Context, p Post) error
+	ReassignPosts(ctx context.Context, fromUserID, toUserID int) error
+	DeleteUserPosts(ctx context.Context, userID int) error
+	UnpublishPost(ctx context.Context, p Post) error
+	UpdatePost(ctx context.Context, p *Post) error
+	TransitionPost(ctx context.Context, p *Post, status int, note string) error
+	FindPostsAwaitingReview(ctx context.Context) ([]Post, error)
+	FindDuePosts(ctx context.Context, now time.Time) ([]Post, error)
+	FindPublishedPosts(ctx context.Context, f PostFilter) ([]Post, error)
+	CountPublishedPosts(ctx context.Context, f PostFilter) (int, error)
+	FindPublishedPostBySlug(ctx context.Context, slug string) (Post, error)
+	Search(ctx context.Context, q SearchQuery, page int) ([]SearchResult, error)
 }
 
+//Content is the Markdown source of a post.
+//ContentHTML, Excerpt and ReadingTime (in minutes) are derived from it whenever it is written.
 type Post struct {
-	ID        int       `db:"id"`
-	Title     string    `db:"title"`
-	Slug      string    `db:"slug"`
-	Content   string    `db:"content"`
-	Status    int       `db:"status"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-	User      User      `db:"user_id"`
+	ID          int        `db:"id"`
+	Title       string     `db:"title"`
+	Slug        string     `db:"slug"`
+	Content     string     `db:"content_markdown"`
+	ContentHTML string     `db:"content_html"`
+	Excerpt     string     `db:"excerpt"`
+	ReadingTime int        `db:"reading_time"`
+	Status      int        `db:"status"`
+	ReviewNote  string     `db:"review_note"`
+	PublishAt   *time.Time `db:"publish_at"`
+	CategoryID  *int       `db:"category_id"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+	UserID      int        `db:"user_id"`
+	User        User       `db:"user"`
+	Category    Category   `db:"category"`
+	//Tags aren't part of the posts table. Nil when they haven't been loaded
+	Tags []Tag `db:"-"`
 }
 
-func (db *DB) CreatePost(p Post, userType int) error {
+const (
+	SORT_CREATED_AT = "created_at"
+	SORT_UPDATED_AT = "updated_at"
+)
+
+const DEFAULT_PER_PAGE = 20
+
+//PostFilter describes which published posts should be listed and in what order.
+//Zero values are ignored, so an empty filter lists every published post, newest first.
+type PostFilter struct {
+	Page      int
+	PerPage   int
+	SortBy    string
+	Ascending bool
+	Author    string
+	Tag       string
+	From      time.Time
+	To        time.Time
+}
 
-	now := time.Now()
+//Offset returns the number of rows to skip for the requested page
+func (f PostFilter) Offset() int {
+	if f.Page < 1 {
+		return 0
+	}
+
+	return (f.Page - 1) * f.Limit()
+}
+
+func (f PostFilter) Limit() int {
+	if f.PerPage < 1 {
+		return DEFAULT_PER_PAGE
+	}
+
+	return f.PerPage
+}
+
+//Only allow sorting on known columns since this ends up in the query as is
+func (f PostFilter) orderBy() string {
+	column := SORT_CREATED_AT
+
+	if f.SortBy == SORT_UPDATED_AT {
+		column = SORT_UPDATED_AT
+	}
+
+	direction := "DESC"
+
+	if f.Ascending {
+		direction = "ASC"
+	}
+
+	return fmt.Sprintf("posts.%s %s, posts.id %s", column, direction, direction)
+}
+
+func (f PostFilter) where() (string, []interface{}) {
+	conditions := []string{"posts.status=?"}
+	args := []interface{}{PUBLISHED}
+
+	if f.Author != "" {
+		conditions = append(conditions, "users.moniker=?")
+		args = append(args, f.Author)
+	}
+
+	if !f.From.IsZero() {
+		conditions = append(conditions, "posts.created_at>=?")
+		args = append(args, f.From.UTC())
+	}
+
+	if !f.To.IsZero() {
+		conditions = append(conditions, "posts.created_at<=?")
+		args = append(args, f.To.UTC())
+	}
+
+	if f.Tag != "" {
+		conditions = append(conditions, `posts.id IN (SELECT post_tags.post_id FROM post_tags
+INNER JOIN tags ON tags.id=post_tags.tag_id WHERE tags.slug=?)`)
+		args = append(args, f.Tag)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+//The author's and category's details are selected into the nested User and Category structs.
+//Posts without a category get an empty one.
+const selectPostsWithAuthor = "SELECT " + postWithAuthorColumns + " FROM " + postWithAuthorTables
+
+const postWithAuthorColumns = `posts.*, users.id AS "user.id", users.moniker AS "user.moniker",
+users.full_name AS "user.full_name", users.about AS "user.about",
+COALESCE(categories.id, 0) AS "category.id", COALESCE(categories.name, '') AS "category.name",
+COALESCE(categories.slug, '') AS "category.slug", categories.parent_id AS "category.parent_id"`
+
+const postWithAuthorTables = `posts INNER JOIN users ON users.id=posts.user_id LEFT JOIN categories ON categories.id=posts.category_id`
+
+//CreatePost saves a new post written by the user p.UserID and sets its id and timestamps.
+//The post, its tags and first revision are saved together or not at all.
+func (db *DB) CreatePost(ctx context.Context, p *Post) error {
+	return db.WithTx(ctx, func(tx DataStore) error {
+		return tx.(*DB).createPost(ctx, p)
+	})
+}
+
+func (db *DB) createPost(ctx context.Context, p *Post) error {
+
+	now := time.Now().UTC()
 
 	p.CreatedAt = now
 	p.UpdatedAt = now
 
-	stmt, err := db.Preparex("INSERT INTO posts(title, slug, content, status, created_at, updated_at, user_id")
+	id, err := db.insert(ctx, `INSERT INTO posts(title, slug, content_markdown, content_html, excerpt, reading_time, status, publish_at, category_id, created_at, updated_at, user_id)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?)`,
+		p.Title, p.Slug, p.Content, p.ContentHTML, p.Excerpt, p.ReadingTime, p.Status, utc(p.PublishAt), p.CategoryID, p.CreatedAt, p.UpdatedAt, p.UserID)
 
-	if err != nil {
-		return errors.Wrap(err, "An error occurred while we tried preparing the statement")
-	}
+	if err == nil {
+		p.ID = id
 
-	r, err := stmt.MustExec(p.Title, p.Slug, p.Content, p.Status, p.CreatedAt, p.UpdatedAt, userType).
-		RowsAffected()
+		if err = db.setPostTags(ctx, id, p.Tags); err != nil {
+			return err
+		}
 
-	if err == nil && r == 1 {
-		return nil
+		return db.snapshot(ctx, id)
+	}
+
+	if isUniqueViolation(err) {
+		return ErrPostExists
 	}
 
 	return errors.Wrap(err, "An error occurred while we tried creating the post")
 }
 
-func (db *DB) FindPostBySlug(slug string) (Post, error) {
+func (db *DB) FindPostBySlug(ctx context.Context, slug string) (Post, error) {
 	var p Post
 
-	stmt, err := db.Preparex("SELECT * FROM posts WHERE slug=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM posts WHERE slug=?")
 
 	if err != nil {
 		return p, errors.Wrap(err, "COuld not prepare statement")
 	}
 
-	rows, err := stmt.Queryx(slug)
-
-	if err != nil {
-		return p, errors.Wrap(err, "An error occurred while trying to replace the prepared statement placeholder")
-	}
-
-	err = rows.StructScan(&p)
+	err = stmt.QueryRowxContext(ctx, slug).StructScan(&p)
 
 	if err != nil {
 		return p, errors.Wrap(err, "Post does not exists")
@@ -77,22 +256,16 @@ func (db *DB) FindPostBySlug(slug string) (Post, error) {
 	return p, nil
 }
 
-func (db *DB) FindPostByTitle(title string) (Post, error) {
+func (db *DB) FindPostByTitle(ctx context.Context, title string) (Post, error) {
 	var p Post
 
-	stmt, err := db.Preparex("SELECT * FROM posts WHERE title=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM posts WHERE title=?")
 
 	if err != nil {
 		return p, errors.Wrap(err, "COuld not prepare statement")
 	}
 
-	rows, err := stmt.Queryx(title)
-
-	if err != nil {
-		return p, errors.Wrap(err, "An error occurred while trying to replace the prepared statement placeholder")
-	}
-
-	err = rows.StructScan(&p)
+	err = stmt.QueryRowxContext(ctx, title).StructScan(&p)
 
 	if err != nil {
 		return p, errors.Wrap(err, "Post does not exists")
@@ -101,16 +274,16 @@ func (db *DB) FindPostByTitle(title string) (Post, error) {
 	return p, nil
 }
 
-func (db *DB) FindPostByID(id int) (Post, error) {
+func (db *DB) FindPostByID(ctx context.Context, id int) (Post, error) {
 	var p Post
 
-	stmt, err := db.Preparex("SELECT * FROM posts WHERE id=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM posts WHERE id=?")
 
 	if err != nil {
 		return p, errors.Wrap(err, "Could not prepare the statement")
 	}
 
-	err = stmt.QueryRowx(id).StructScan(&p)
+	err = stmt.QueryRowxContext(ctx, id).StructScan(&p)
 
 	if err != nil {
 		return p, errors.Wrap(err, "Post does not exists")
@@ -119,36 +292,264 @@ func (db *DB) FindPostByID(id int) (Post, error) {
 	return p, nil
 }
 
-func (db *DB) DeletePost(p Post) error {
+//DeletePost deletes a post along with its tags and revisions
+func (db *DB) DeletePost(ctx context.Context, p Post) error {
+	return db.WithTx(ctx, func(tx DataStore) error {
+
+		for _, query := range []string{"DELETE FROM post_tags WHERE post_id=?", "DELETE FROM post_revisions WHERE post_id=?"} {
+			stmt, err := tx.(*DB).PreparexContext(ctx, query)
+
+			if err != nil {
+				return errors.Wrap(err, "Could not prepare statement")
+			}
+
+			if _, err = stmt.ExecContext(ctx, p.ID); err != nil {
+				return errors.Wrap(err, "Post could not be deleted")
+			}
+		}
+
+		stmt, err := tx.(*DB).PreparexContext(ctx, "DELETE FROM posts WHERE id=?")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		res, err := stmt.ExecContext(ctx, p.ID)
+
+		if err != nil {
+			return errors.Wrap(err, "Post could not be deleted")
+		}
+
+		if r, err := res.RowsAffected(); err != nil || r != 1 {
+			return errors.New("Post could not be deleted")
+		}
+
+		return nil
+	})
+}
+
+//ReassignPosts makes toUserID the author of every post written by fromUserID
+func (db *DB) ReassignPosts(ctx context.Context, fromUserID, toUserID int) error {
 
-	stmt, err := db.Preparex("DELETE FROM posts WHERE id=?")
+	stmt, err := db.PreparexContext(ctx, "UPDATE posts SET user_id=? WHERE user_id=?")
 
 	if err != nil {
 		return errors.Wrap(err, "Could not prepare statement")
 	}
 
-	r, err := stmt.MustExec(p.ID).RowsAffected()
+	if _, err = stmt.ExecContext(ctx, toUserID, fromUserID); err != nil {
+		return errors.Wrap(err, "Could not reassign the user's posts")
+	}
+
+	return nil
+}
+
+//DeleteUserPosts deletes every post written by the user along with their tags and revisions
+func (db *DB) DeleteUserPosts(ctx context.Context, userID int) error {
+	return db.WithTx(ctx, func(tx DataStore) error {
+
+		queries := []string{
+			"DELETE FROM post_tags WHERE post_id IN (SELECT id FROM posts WHERE user_id=?)",
+			"DELETE FROM post_revisions WHERE post_id IN (SELECT id FROM posts WHERE user_id=?)",
+			"DELETE FROM posts WHERE user_id=?",
+		}
+
+		for _, query := range queries {
+			stmt, err := tx.(*DB).PreparexContext(ctx, query)
+
+			if err != nil {
+				return errors.Wrap(err, "Could not prepare statement")
+			}
+
+			if _, err = stmt.ExecContext(ctx, userID); err != nil {
+				return errors.Wrap(err, "Could not delete the user's posts")
+			}
+		}
 
-	if r == 1 && err == nil {
 		return nil
+	})
+}
+
+//UnpublishPost takes a published post back to being a draft
+func (db *DB) UnpublishPost(ctx context.Context, p Post) error {
+	return db.TransitionPost(ctx, &p, DRAFT, "")
+}
+
+//TransitionPost moves a post to a new status, recording the reviewer's note if any.
+//It fails with ErrInvalidTransition if the move is not allowed from the post's current status
+//or the post's status was changed by someone else in the meantime.
+func (db *DB) TransitionPost(ctx context.Context, p *Post, status int, note string) error {
+
+	if !CanTransition(p.Status, status) {
+		return ErrInvalidTransition
+	}
+
+	now := time.Now().UTC()
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE posts SET status=?, review_note=?, updated_at=? WHERE id=? AND status=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, status, note, now, p.ID, p.Status)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not update post")
+	}
+
+	if r, err := res.RowsAffected(); err != nil || r != 1 {
+		return ErrInvalidTransition
+	}
+
+	p.Status = status
+	p.ReviewNote = note
+	p.UpdatedAt = now
+
+	return db.snapshot(ctx, p.ID)
+}
+
+//FindPostsAwaitingReview lists posts submitted for review, the ones waiting the longest first
+func (db *DB) FindPostsAwaitingReview(ctx context.Context) ([]Post, error) {
+	var posts []Post
+
+	stmt, err := db.PreparexContext(ctx, selectPostsWithAuthor+" WHERE posts.status=? ORDER BY posts.updated_at ASC, posts.id ASC")
+
+	if err != nil {
+		return posts, errors.Wrap(err, "Could not prepare statement")
 	}
 
-	return errors.Wrap(err, "Post could not be deleted")
+	if err = stmt.SelectContext(ctx, &posts, IN_REVIEW); err != nil {
+		return posts, errors.Wrap(err, "Could not fetch posts awaiting review")
+	}
+
+	return posts, nil
 }
 
-func (db *DB) UnpublishPost(p Post) error {
+//UpdatePost saves the post's title, slug, content (both Markdown and rendered), publishing date and category and bumps its updated_at timestamp.
+//The post's tags are replaced by p.Tags unless it is nil. The post, its tags and new revision are saved together or not at all.
+func (db *DB) UpdatePost(ctx context.Context, p *Post) error {
+	return db.WithTx(ctx, func(tx DataStore) error {
+		return tx.(*DB).updatePost(ctx, p)
+	})
+}
 
-	stmt, err := db.Preparex("UPDATE posts SET status=? WHERE id=?")
+func (db *DB) updatePost(ctx context.Context, p *Post) error {
+
+	p.UpdatedAt = time.Now().UTC()
+
+	stmt, err := db.PreparexContext(ctx, `UPDATE posts SET title=?, slug=?, content_markdown=?, content_html=?, excerpt=?, reading_time=?,
+publish_at=?, category_id=?, updated_at=? WHERE id=?`)
 
 	if err != nil {
 		return errors.Wrap(err, "Could not prepare statement")
 	}
 
-	r, err := stmt.MustExec(PUBLISHED).RowsAffected()
+	res, err := stmt.ExecContext(ctx, p.Title, p.Slug, p.Content, p.ContentHTML, p.Excerpt, p.ReadingTime, utc(p.PublishAt), p.CategoryID, p.UpdatedAt, p.ID)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrPostExists
+		}
+
+		return errors.Wrap(err, "Could not update post")
+	}
+
+	if r, err := res.RowsAffected(); err != nil || r != 1 {
+		return errors.New("Could not update post")
+	}
+
+	if p.Tags != nil {
+		if err = db.setPostTags(ctx, p.ID, p.Tags); err != nil {
+			return err
+		}
+	}
+
+	return db.snapshot(ctx, p.ID)
+}
+
+func (db *DB) FindPublishedPosts(ctx context.Context, f PostFilter) ([]Post, error) {
+	var posts []Post
+
+	where, args := f.where()
+
+	stmt, err := db.PreparexContext(ctx, selectPostsWithAuthor+" WHERE "+where+" ORDER BY "+f.orderBy()+" LIMIT ? OFFSET ?")
+
+	if err != nil {
+		return posts, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	args = append(args, f.Limit(), f.Offset())
+
+	if err = stmt.SelectContext(ctx, &posts, args...); err != nil {
+		return posts, errors.Wrap(err, "Could not fetch published posts")
+	}
+
+	return posts, nil
+}
+
+func (db *DB) CountPublishedPosts(ctx context.Context, f PostFilter) (int, error) {
+	var count int
+
+	where, args := f.where()
+
+	stmt, err := db.PreparexContext(ctx, "SELECT COUNT(*) FROM posts INNER JOIN users ON users.id=posts.user_id WHERE "+where)
+
+	if err != nil {
+		return count, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.GetContext(ctx, &count, args...); err != nil {
+		return count, errors.Wrap(err, "Could not count published posts")
+	}
+
+	return count, nil
+}
+
+func (db *DB) FindPublishedPostBySlug(ctx context.Context, slug string) (Post, error) {
+	var p Post
+
+	stmt, err := db.PreparexContext(ctx, selectPostsWithAuthor+" WHERE posts.slug=? AND posts.status=?")
+
+	if err != nil {
+		return p, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	err = stmt.QueryRowxContext(ctx, slug, PUBLISHED).StructScan(&p)
+
+	if err != nil {
+		return p, errors.Wrap(err, "Post does not exists")
+	}
+
+	p.Tags, err = db.FindTagsByPost(ctx, p.ID)
+
+	return p, err
+}
 
-	if err == nil && r == 1 {
+//FindDuePosts lists scheduled posts whose publishing date is not after now
+func (db *DB) FindDuePosts(ctx context.Context, now time.Time) ([]Post, error) {
+	var posts []Post
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM posts WHERE status=? AND publish_at<=? ORDER BY publish_at ASC, id ASC")
+
+	if err != nil {
+		return posts, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.SelectContext(ctx, &posts, SCHEDULED, now.UTC()); err != nil {
+		return posts, errors.Wrap(err, "Could not fetch due posts")
+	}
+
+	return posts, nil
+}
+
+//Dates are stored in UTC so they compare correctly in the database
+func utc(t *time.Time) *time.Time {
+	if t == nil {
 		return nil
 	}
 
-	return errors.Wrap(err, "Could not update post")
+	u := t.UTC()
+
+	return &u
 }
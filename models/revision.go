@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"time"
+)
+
+//RevisionStore gives access to the snapshots taken whenever a post is written to.
+//Snapshots are created by the PostStore methods themselves, so there is no way to add one directly.
+type RevisionStore interface {
+	FindRevisionsByPost(ctx context.Context, postID int) ([]Revision, error)
+	FindRevisionByID(ctx context.Context, id int) (Revision, error)
+}
+
+//Revision is a copy of a post's title, content and status at a point in time
+type Revision struct {
+	ID        int       `db:"id"`
+	PostID    int       `db:"post_id"`
+	Title     string    `db:"title"`
+	Content   string    `db:"content"`
+	Status    int       `db:"status"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (db *DB) FindRevisionsByPost(ctx context.Context, postID int) ([]Revision, error) {
+	var revisions []Revision
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM post_revisions WHERE post_id=? ORDER BY id DESC")
+
+	if err != nil {
+		return revisions, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.SelectContext(ctx, &revisions, postID); err != nil {
+		return revisions, errors.Wrap(err, "Could not fetch the post's revisions")
+	}
+
+	return revisions, nil
+}
+
+func (db *DB) FindRevisionByID(ctx context.Context, id int) (Revision, error) {
+	var r Revision
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM post_revisions WHERE id=?")
+
+	if err != nil {
+		return r, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, id).StructScan(&r); err != nil {
+		return r, errors.Wrap(err, "Revision does not exist")
+	}
+
+	return r, nil
+}
+
+//snapshot records the current state of a post as a new revision, dated when the post was last updated.
+//It has to be called after every write to the posts table.
+func (db *DB) snapshot(ctx context.Context, postID int) error {
+
+	stmt, err := db.PreparexContext(ctx, `INSERT INTO post_revisions(post_id, title, content, status, created_at)
+SELECT id, title, content_markdown, status, updated_at FROM posts WHERE id=?`)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, postID); err != nil {
+		return errors.Wrap(err, "Could not create a revision of the post")
+	}
+
+	return nil
+}
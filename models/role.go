@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"sort"
+)
+
+//Roles, from the most to the least powerful
+const (
+	OWNER       = "owner"
+	ADMIN       = "admin"
+	EDITOR      = "editor"
+	AUTHOR      = "author"
+	CONTRIBUTOR = "contributor"
+)
+
+//Permissions a role can be given.
+//Permissions ending in :own only apply to what the user wrote, :any to everything.
+const (
+	POST_CREATE     = "post:create"
+	POST_EDIT_OWN   = "post:edit:own"
+	POST_EDIT_ANY   = "post:edit:any"
+	POST_READ_ANY   = "post:read:any"
+	POST_PUBLISH    = "post:publish"
+	POST_REVIEW     = "post:review"
+	POST_UNPUBLISH  = "post:unpublish"
+	POST_DELETE_ANY = "post:delete:any"
+	TAXONOMY_MANAGE = "taxonomy:manage"
+	USER_INVITE     = "user:invite"
+	USER_MANAGE     = "user:manage"
+	ROLE_MANAGE     = "role:manage"
+)
+
+//Every permission there is
+var AllPermissions = []string{
+	POST_CREATE, POST_EDIT_OWN, POST_EDIT_ANY, POST_READ_ANY, POST_PUBLISH, POST_REVIEW,
+	POST_UNPUBLISH, POST_DELETE_ANY, TAXONOMY_MANAGE, USER_INVITE, USER_MANAGE, ROLE_MANAGE,
+}
+
+//The roles a new database starts with. The migrations adding the roles table insert the same ones.
+var DefaultRoles = []Role{
+	{Name: OWNER, Description: "Runs the blog and decides what every other role can do", Permissions: AllPermissions},
+	{Name: ADMIN, Description: "Manages the blog and its users", Permissions: []string{
+		POST_CREATE, POST_EDIT_OWN, POST_EDIT_ANY, POST_READ_ANY, POST_PUBLISH, POST_REVIEW,
+		POST_UNPUBLISH, POST_DELETE_ANY, TAXONOMY_MANAGE, USER_INVITE, USER_MANAGE,
+	}},
+	{Name: EDITOR, Description: "Reviews, publishes and edits every post", Permissions: []string{
+		POST_CREATE, POST_EDIT_OWN, POST_EDIT_ANY, POST_READ_ANY, POST_PUBLISH, POST_REVIEW,
+		POST_UNPUBLISH, POST_DELETE_ANY, TAXONOMY_MANAGE,
+	}},
+	{Name: AUTHOR, Description: "Writes and publishes their own posts", Permissions: []string{
+		POST_CREATE, POST_EDIT_OWN, POST_PUBLISH,
+	}},
+	{Name: CONTRIBUTOR, Description: "Writes posts and submits them for review", Permissions: []string{
+		POST_CREATE, POST_EDIT_OWN,
+	}},
+}
+
+//ErrRoleLocked is returned when changing the permissions of the owner role, the owner can always do everything
+var ErrRoleLocked = errors.New("The owner role can't be changed")
+
+//ErrUnknownPermission is returned when giving a role a permission that doesn't exist
+var ErrUnknownPermission = errors.New("Unknown permission")
+
+type RoleStore interface {
+	FindRoles(ctx context.Context) ([]Role, error)
+	FindRole(ctx context.Context, name string) (Role, error)
+	UpdateRolePermissions(ctx context.Context, name string, permissions []string) error
+}
+
+//Role is what a user is allowed to do. Every user has exactly one role
+type Role struct {
+	Name        string   `db:"name"`
+	Description string   `db:"description"`
+	Permissions []string `db:"-"`
+}
+
+//Can tells if the role has the permission
+func (r Role) Can(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+//IsPermission tells if there is such a permission
+func IsPermission(permission string) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+//ValidatePermissions checks that every permission exists and returns them sorted, without duplicates
+func ValidatePermissions(permissions []string) ([]string, error) {
+
+	seen := make(map[string]bool, len(permissions))
+	valid := make([]string, 0, len(permissions))
+
+	for _, p := range permissions {
+		if !IsPermission(p) {
+			return nil, errors.Wrap(ErrUnknownPermission, p)
+		}
+
+		if !seen[p] {
+			seen[p] = true
+			valid = append(valid, p)
+		}
+	}
+
+	sort.Strings(valid)
+
+	return valid, nil
+}
+
+//FindRoles returns every role, from the most to the least powerful
+func (db *DB) FindRoles(ctx context.Context) ([]Role, error) {
+
+	stmt, err := db.PreparexContext(ctx, "SELECT name, description FROM roles ORDER BY sort_order")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	roles := []Role{}
+
+	if err = stmt.SelectContext(ctx, &roles); err != nil {
+		return nil, errors.Wrap(err, "Could not fetch the roles")
+	}
+
+	for i := range roles {
+		if roles[i].Permissions, err = db.rolePermissions(ctx, roles[i].Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return roles, nil
+}
+
+func (db *DB) FindRole(ctx context.Context, name string) (Role, error) {
+
+	var r Role
+
+	stmt, err := db.PreparexContext(ctx, "SELECT name, description FROM roles WHERE name=?")
+
+	if err != nil {
+		return Role{}, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, name).StructScan(&r); err != nil {
+		return Role{}, errors.Wrap(err, "Role not found")
+	}
+
+	if r.Permissions, err = db.rolePermissions(ctx, name); err != nil {
+		return Role{}, err
+	}
+
+	return r, nil
+}
+
+func (db *DB) rolePermissions(ctx context.Context, name string) ([]string, error) {
+
+	stmt, err := db.PreparexContext(ctx, "SELECT permission FROM role_permissions WHERE role=? ORDER BY permission")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	permissions := []string{}
+
+	if err = stmt.SelectContext(ctx, &permissions, name); err != nil {
+		return nil, errors.Wrap(err, "Could not fetch the role's permissions")
+	}
+
+	return permissions, nil
+}
+
+//UpdateRolePermissions replaces every permission of the role.
+//The access tokens issued before to the users holding it are outdated
+func (db *DB) UpdateRolePermissions(ctx context.Context, name string, permissions []string) error {
+
+	if name == OWNER {
+		return ErrRoleLocked
+	}
+
+	permissions, err := ValidatePermissions(permissions)
+
+	if err != nil {
+		return err
+	}
+
+	return db.WithTx(ctx, func(tx DataStore) error {
+
+		if _, err := tx.FindRole(ctx, name); err != nil {
+			return err
+		}
+
+		stmt, err := tx.(*DB).PreparexContext(ctx, "DELETE FROM role_permissions WHERE role=?")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		if _, err = stmt.ExecContext(ctx, name); err != nil {
+			return errors.Wrap(err, "Could not remove the role's permissions")
+		}
+
+		stmt, err = tx.(*DB).PreparexContext(ctx, "INSERT INTO role_permissions(role, permission) VALUES(?,?)")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		for _, p := range permissions {
+			if _, err = stmt.ExecContext(ctx, name, p); err != nil {
+				return errors.Wrap(err, "Could not give the role its permissions")
+			}
+		}
+
+		stmt, err = tx.(*DB).PreparexContext(ctx, "UPDATE users SET permissions_version=permissions_version+1 WHERE role=?")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		if _, err = stmt.ExecContext(ctx, name); err != nil {
+			return errors.Wrap(err, "Could not outdate the permissions of the role's users")
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+//Matched terms in a search snippet are wrapped in these.
+//Control characters are stripped from posts before they are saved, so the snippet can be safely escaped before these are turned into markup.
+const (
+	HIGHLIGHT_START = "\x02"
+	HIGHLIGHT_END   = "\x03"
+)
+
+//ErrEmptySearch is returned when a search query has no terms to look for
+var ErrEmptySearch = errors.New("Please provide something to search for")
+
+//SearchQuery describes what to look for.
+//Only published posts are searched unless IncludeUnpublished is set,
+//in which case UserID (if not zero) limits the unpublished posts to the ones written by that user.
+type SearchQuery struct {
+	Terms              string
+	IncludeUnpublished bool
+	UserID             int
+}
+
+//SearchResult is a post matching a search along with the part of it that matched
+type SearchResult struct {
+	Post
+	Snippet string `db:"snippet"`
+}
+
+//match turns the user's query into an FTS5 query.
+//Every word is quoted so that FTS5 operators and syntax in the query are searched for literally.
+func (q SearchQuery) match() string {
+	var terms []string
+
+	for _, term := range strings.Fields(q.Terms) {
+		term = strings.Replace(term, `"`, "", -1)
+
+		if term != "" {
+			terms = append(terms, `"`+term+`"`)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+func (q SearchQuery) where() (string, []interface{}) {
+	if !q.IncludeUnpublished {
+		return "posts.status=?", []interface{}{PUBLISHED}
+	}
+
+	if q.UserID != 0 {
+		return "(posts.status=? OR posts.user_id=?)", []interface{}{PUBLISHED, q.UserID}
+	}
+
+	return "1=1", nil
+}
+
+//Search looks for posts whose title or content match every word of the query, best matches first.
+//Pages start at 1 and hold DEFAULT_PER_PAGE results.
+func (db *DB) Search(ctx context.Context, q SearchQuery, page int) ([]SearchResult, error) {
+	var results []SearchResult
+
+	match := q.match()
+
+	if match == "" {
+		return results, ErrEmptySearch
+	}
+
+	f := PostFilter{Page: page}
+
+	where, args := q.where()
+
+	query := sqliteSearch
+
+	if db.DriverName() == POSTGRES {
+		//PostgreSQL parses the words itself, so the query doesn't need quoting
+		query = postgresSearch
+		match = q.Terms
+	}
+
+	stmt, err := db.PreparexContext(ctx, fmt.Sprintf(query, where))
+
+	if err != nil {
+		return results, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	args = append([]interface{}{match}, args...)
+	args = append(args, f.Limit(), f.Offset())
+
+	if err = stmt.SelectContext(ctx, &results, args...); err != nil {
+		return results, errors.Wrap(err, "Could not search posts")
+	}
+
+	return results, nil
+}
+
+//Ranks with bm25, weighing matches in the title five times as much as matches in the content
+const sqliteSearch = `SELECT ` + postWithAuthorColumns + `,
+snippet(posts_fts, -1, '` + HIGHLIGHT_START + `', '` + HIGHLIGHT_END + `', '…', 16) AS snippet
+FROM ` + postWithAuthorTables + ` INNER JOIN posts_fts ON posts_fts.rowid=posts.id
+WHERE posts_fts MATCH ? AND %s ORDER BY bm25(posts_fts, 5.0, 1.0), posts.id DESC LIMIT ? OFFSET ?`
+
+//Has to stay the same as the expression posts_search_index is built on, or the index won't be used
+const postgresSearchDocument = `(setweight(to_tsvector('english', posts.title), 'A') || setweight(to_tsvector('english', posts.content_markdown), 'B'))`
+
+const postgresSearch = `SELECT ` + postWithAuthorColumns + `,
+ts_headline('english', posts.title || ' ' || posts.content_markdown, query, 'StartSel=` + HIGHLIGHT_START + `, StopSel=` + HIGHLIGHT_END + `, MaxWords=16, MinWords=8') AS snippet
+FROM ` + postWithAuthorTables + ` CROSS JOIN plainto_tsquery('english', ?) AS query
+WHERE ` + postgresSearchDocument + ` @@ query AND %s ORDER BY ts_rank(` + postgresSearchDocument + `, query) DESC, posts.id DESC LIMIT ? OFFSET ?`
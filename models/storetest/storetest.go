@@ -0,0 +1,1277 @@
+//Package storetest is a conformance suite for models.DataStore implementations.
+//Every backend is expected to behave the same way, whatever SQL dialect it speaks.
+package storetest
+
+import (
+	"context"
+	"github.com/adelowo/gotils/hasher"
+	"github.com/adelowo/reblog/models"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var ctx = context.Background()
+
+//Run runs the suite, calling newStore for an empty store before every test
+func Run(t *testing.T, newStore func(t *testing.T) models.DataStore) {
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, s models.DataStore)
+	}{
+		{"Users", testUsers},
+		{"Collaborators", testCollaborators},
+		{"CreatedPostsCanBeFound", testCreatedPostsCanBeFound},
+		{"TitlesAndSlugsAreUnique", testTitlesAndSlugsAreUnique},
+		{"PublishedPostsAreListed", testPublishedPostsAreListed},
+		{"Transitions", testTransitions},
+		{"Unpublishing", testUnpublishing},
+		{"DuePosts", testDuePosts},
+		{"Revisions", testRevisions},
+		{"Tags", testTags},
+		{"Categories", testCategories},
+		{"Search", testSearch},
+		{"Transactions", testTransactions},
+		{"Tokens", testTokens},
+		{"PasswordResets", testPasswordResets},
+		{"Outbox", testOutbox},
+		{"Roles", testRoles},
+		{"Profiles", testProfiles},
+		{"EmailChanges", testEmailChanges},
+		{"UserManagement", testUserManagement},
+		{"UserPosts", testUserPosts},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s models.DataStore, moniker string) models.User {
+
+	u := &models.User{Moniker: moniker, Name: "Lanre " + moniker, Email: moniker + "@reblog.io", Password: "password"}
+
+	if err := s.CreateUser(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.FindByMoniker(ctx, moniker)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return found
+}
+
+//createPost saves p as written by author and returns it as stored
+func createPost(t *testing.T, s models.DataStore, author models.User, p models.Post) models.Post {
+
+	if p.Slug == "" {
+		p.Slug = strings.Replace(p.Title, " ", "-", -1)
+	}
+
+	if p.Content == "" {
+		p.Content = "Some content about " + p.Title
+	}
+
+	p.UserID = author.ID
+
+	if err := s.CreatePost(ctx, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.FindPostByID(ctx, p.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return found
+}
+
+func titles(posts []models.Post) []string {
+	found := []string{}
+
+	for _, p := range posts {
+		found = append(found, p.Title)
+	}
+
+	return found
+}
+
+func testUsers(t *testing.T, s models.DataStore) {
+
+	u := createUser(t, s, "hades")
+
+	assert.NotZero(t, u.ID)
+	assert.Equal(t, "Lanre hades", u.Name)
+	assert.WithinDuration(t, time.Now(), u.CreatedAt, time.Minute)
+
+	//Passwords are hashed before they are stored
+	assert.NotEqual(t, "password", u.Password)
+
+	byEmail, err := s.FindByEmail(ctx, "hades@reblog.io")
+
+	assert.NoError(t, err)
+	assert.Equal(t, u.ID, byEmail.ID)
+
+	byID, err := s.FindByID(ctx, u.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hades@reblog.io", byID.Email)
+
+	_, err = s.FindByID(ctx, u.ID+1)
+
+	assert.Error(t, err)
+
+	assert.True(t, s.DoesUserExist(ctx, "hades@reblog.io", "someone"))
+	assert.True(t, s.DoesUserExist(ctx, "someone@reblog.io", "hades"))
+	assert.False(t, s.DoesUserExist(ctx, "someone@reblog.io", "someone"))
+
+	_, err = s.FindByMoniker(ctx, "someone")
+
+	assert.Error(t, err)
+
+	//Users are contributors unless they are created with another role
+	assert.Equal(t, models.CONTRIBUTOR, u.Role)
+	assert.NoError(t, s.CreateUser(ctx, &models.User{Moniker: "zeus", Name: "Lanre zeus", Email: "zeus@reblog.io", Password: "password", Role: models.EDITOR}))
+
+	zeus, err := s.FindByMoniker(ctx, "zeus")
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.EDITOR, zeus.Role)
+
+	assert.NoError(t, s.DeleteUser(ctx, u))
+
+	_, err = s.FindByEmail(ctx, "hades@reblog.io")
+
+	assert.Error(t, err)
+	assert.Error(t, s.DeleteUser(ctx, u))
+}
+
+func testCollaborators(t *testing.T, s models.DataStore) {
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	hades, err := s.CreateCollaborator(ctx, "hades@reblog.io", models.CONTRIBUTOR, expiresAt)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, hades.Token)
+	assert.Equal(t, "hades@reblog.io", hades.Email)
+	assert.True(t, expiresAt.Equal(hades.ExpiresAt))
+
+	zeus, err := s.CreateCollaborator(ctx, "zeus@reblog.io", models.ADMIN, expiresAt)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, hades.ID, zeus.ID)
+
+	//Inviting someone again replaces their token instead of adding them twice
+	again, err := s.CreateCollaborator(ctx, "hades@reblog.io", models.AUTHOR, expiresAt.Add(time.Hour))
+
+	assert.NoError(t, err)
+	assert.Equal(t, hades.ID, again.ID)
+	assert.NotEqual(t, hades.Token, again.Token)
+
+	found, err := s.FindCollaboratorByToken(ctx, again.Token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hades@reblog.io", found.Email)
+	assert.Equal(t, models.AUTHOR, found.Role)
+	assert.True(t, expiresAt.Add(time.Hour).Equal(found.ExpiresAt))
+	assert.False(t, found.Expired(expiresAt))
+	assert.True(t, found.Expired(expiresAt.Add(time.Hour)))
+
+	_, err = s.FindCollaboratorByToken(ctx, hades.Token)
+
+	assert.Error(t, err)
+
+	_, err = s.FindCollaboratorByToken(ctx, "not-a-token")
+
+	assert.Error(t, err)
+
+	all, err := s.FindCollaborators(ctx)
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, all, 2) {
+		//The latest invitation comes first
+		assert.Equal(t, "hades@reblog.io", all[0].Email)
+		assert.Equal(t, "zeus@reblog.io", all[1].Email)
+	}
+
+	byID, err := s.FindCollaboratorByID(ctx, zeus.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, zeus.Token, byID.Token)
+
+	_, err = s.FindCollaboratorByID(ctx, zeus.ID+hades.ID)
+
+	assert.Error(t, err)
+
+	//Rotating the token of an invitation renews it
+	rotated, err := s.RotateCollaboratorToken(ctx, zeus.ID, expiresAt.Add(2*time.Hour))
+
+	assert.NoError(t, err)
+	assert.Equal(t, zeus.ID, rotated.ID)
+	assert.Equal(t, models.ADMIN, rotated.Role)
+	assert.NotEqual(t, zeus.Token, rotated.Token)
+	assert.True(t, expiresAt.Add(2*time.Hour).Equal(rotated.ExpiresAt))
+
+	_, err = s.FindCollaboratorByToken(ctx, zeus.Token)
+
+	assert.Error(t, err)
+
+	found, err = s.FindCollaboratorByToken(ctx, rotated.Token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, zeus.ID, found.ID)
+
+	_, err = s.RotateCollaboratorToken(ctx, zeus.ID+hades.ID, expiresAt)
+
+	assert.Error(t, err)
+
+	assert.NoError(t, s.DeleteCollaborator(ctx, models.Collaborator{Email: "hades@reblog.io"}))
+	assert.Error(t, s.DeleteCollaborator(ctx, models.Collaborator{Email: "hades@reblog.io"}))
+	assert.NoError(t, s.DeleteCollaborator(ctx, models.Collaborator{Email: "zeus@reblog.io"}))
+
+	all, err = s.FindCollaborators(ctx)
+
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func testCreatedPostsCanBeFound(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	publishAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	p := createPost(t, s, author, models.Post{
+		Title:       "Hello world",
+		Content:     "# Hello",
+		ContentHTML: "<h1>Hello</h1>\n",
+		Excerpt:     "Hello",
+		ReadingTime: 1,
+		Status:      models.SCHEDULED,
+		PublishAt:   &publishAt,
+	})
+
+	assert.NotZero(t, p.ID)
+	assert.Equal(t, "Hello-world", p.Slug)
+	assert.Equal(t, "# Hello", p.Content)
+	assert.Equal(t, "<h1>Hello</h1>\n", p.ContentHTML)
+	assert.Equal(t, 1, p.ReadingTime)
+	assert.Equal(t, author.ID, p.UserID)
+	assert.Nil(t, p.CategoryID)
+	assert.WithinDuration(t, time.Now(), p.CreatedAt, time.Minute)
+
+	if assert.NotNil(t, p.PublishAt) {
+		assert.True(t, publishAt.Equal(*p.PublishAt))
+	}
+
+	byID, err := s.FindPostByID(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, p.Title, byID.Title)
+
+	bySlug, err := s.FindPostBySlug(ctx, "Hello-world")
+
+	assert.NoError(t, err)
+	assert.Equal(t, p.ID, bySlug.ID)
+
+	_, err = s.FindPostByID(ctx, p.ID+1)
+
+	assert.Error(t, err)
+
+	assert.NoError(t, s.DeletePost(ctx, p))
+
+	_, err = s.FindPostByID(ctx, p.ID)
+
+	assert.Error(t, err)
+}
+
+func testTitlesAndSlugsAreUnique(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	first := createPost(t, s, author, models.Post{Title: "First post"})
+	createPost(t, s, author, models.Post{Title: "Second post"})
+
+	assert.Equal(t, models.ErrPostExists, s.CreatePost(ctx, &models.Post{Title: "First post", Slug: "another-slug", Content: "content", UserID: author.ID}))
+	assert.Equal(t, models.ErrPostExists, s.CreatePost(ctx, &models.Post{Title: "Another title", Slug: "First-post", Content: "content", UserID: author.ID}))
+
+	first.Title = "Second post"
+
+	assert.Equal(t, models.ErrPostExists, s.UpdatePost(ctx, &first))
+}
+
+func testPublishedPostsAreListed(t *testing.T, s models.DataStore) {
+
+	hades := createUser(t, s, "hades")
+	zeus := createUser(t, s, "zeus")
+
+	for _, title := range []string{"One", "Two", "Three"} {
+		createPost(t, s, hades, models.Post{Title: title, Status: models.PUBLISHED})
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	createPost(t, s, zeus, models.Post{Title: "Four", Status: models.PUBLISHED})
+	createPost(t, s, hades, models.Post{Title: "Draft", Status: models.DRAFT})
+
+	posts, err := s.FindPublishedPosts(ctx, models.PostFilter{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Four", "Three", "Two", "One"}, titles(posts))
+	assert.Equal(t, "zeus", posts[0].User.Moniker)
+	assert.Empty(t, posts[0].Category.Slug)
+
+	posts, err = s.FindPublishedPosts(ctx, models.PostFilter{Author: "hades", PerPage: 2, Page: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"One"}, titles(posts))
+
+	posts, err = s.FindPublishedPosts(ctx, models.PostFilter{Ascending: true, PerPage: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"One", "Two"}, titles(posts))
+
+	posts, err = s.FindPublishedPosts(ctx, models.PostFilter{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)})
+
+	assert.NoError(t, err)
+	assert.Len(t, posts, 4)
+
+	posts, err = s.FindPublishedPosts(ctx, models.PostFilter{From: time.Now().Add(time.Hour)})
+
+	assert.NoError(t, err)
+	assert.Empty(t, posts)
+
+	//Boundaries are inclusive and compared as instants, whatever their time zone
+	two, err := s.FindPublishedPostBySlug(ctx, "Two")
+
+	assert.NoError(t, err)
+
+	posts, err = s.FindPublishedPosts(ctx, models.PostFilter{From: two.CreatedAt.In(time.FixedZone("UTC+5", 5*60*60))})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Four", "Three", "Two"}, titles(posts))
+
+	posts, err = s.FindPublishedPosts(ctx, models.PostFilter{To: two.CreatedAt.In(time.FixedZone("UTC-5", -5*60*60))})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Two", "One"}, titles(posts))
+
+	count, err := s.CountPublishedPosts(ctx, models.PostFilter{Author: "hades"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	p, err := s.FindPublishedPostBySlug(ctx, "Two")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hades", p.User.Moniker)
+
+	_, err = s.FindPublishedPostBySlug(ctx, "Draft")
+
+	assert.Error(t, err)
+}
+
+func testTransitions(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	p := createPost(t, s, author, models.Post{Title: "Under review"})
+	createPost(t, s, author, models.Post{Title: "Still a draft"})
+
+	assert.NoError(t, s.TransitionPost(ctx, &p, models.IN_REVIEW, ""))
+
+	awaiting, err := s.FindPostsAwaitingReview(ctx)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Under review"}, titles(awaiting))
+
+	assert.NoError(t, s.TransitionPost(ctx, &p, models.CHANGES_REQUESTED, "Needs an introduction"))
+
+	found, err := s.FindPostByID(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.CHANGES_REQUESTED, found.Status)
+	assert.Equal(t, "Needs an introduction", found.ReviewNote)
+
+	//The post is no longer in review, so this has to fail even though the move itself is allowed
+	stale := p
+	stale.Status = models.IN_REVIEW
+
+	assert.Equal(t, models.ErrInvalidTransition, s.TransitionPost(ctx, &stale, models.PUBLISHED, ""))
+	assert.Equal(t, models.ErrInvalidTransition, s.TransitionPost(ctx, &p, models.PUBLISHED, ""))
+}
+
+func testUnpublishing(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	p := createPost(t, s, author, models.Post{Title: "Published", Status: models.PUBLISHED})
+	draft := createPost(t, s, author, models.Post{Title: "Draft"})
+
+	assert.NoError(t, s.UnpublishPost(ctx, p))
+
+	found, err := s.FindPostByID(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.DRAFT, found.Status)
+
+	count, err := s.CountPublishedPosts(ctx, models.PostFilter{})
+
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+
+	assert.Equal(t, models.ErrInvalidTransition, s.UnpublishPost(ctx, draft))
+
+	//Nothing else was touched
+	found, err = s.FindPostByID(ctx, draft.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Draft", found.Title)
+	assert.Equal(t, models.DRAFT, found.Status)
+}
+
+func testDuePosts(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	//Publishing dates in another time zone still compare by the instant they describe
+	due := past.In(time.FixedZone("WAT", 3600))
+
+	createPost(t, s, author, models.Post{Title: "Due", Status: models.SCHEDULED, PublishAt: &due})
+	createPost(t, s, author, models.Post{Title: "Later", Status: models.SCHEDULED, PublishAt: &future})
+	createPost(t, s, author, models.Post{Title: "Draft", PublishAt: &past})
+
+	posts, err := s.FindDuePosts(ctx, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Due"}, titles(posts))
+
+	posts, err = s.FindDuePosts(ctx, now.Add(2*time.Hour))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Due", "Later"}, titles(posts))
+}
+
+func testRevisions(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	p := createPost(t, s, author, models.Post{Title: "Original", Content: "First draft"})
+
+	p.Title = "Edited"
+	p.Content = "Second draft"
+
+	assert.NoError(t, s.UpdatePost(ctx, &p))
+
+	revisions, err := s.FindRevisionsByPost(ctx, p.ID)
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, "Edited", revisions[0].Title)
+		assert.Equal(t, "Second draft", revisions[0].Content)
+		assert.Equal(t, "Original", revisions[1].Title)
+		assert.WithinDuration(t, time.Now(), revisions[1].CreatedAt, time.Minute)
+
+		r, err := s.FindRevisionByID(ctx, revisions[1].ID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "First draft", r.Content)
+	}
+}
+
+func testTags(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	golang := models.Tag{Name: "Go", Slug: "go"}
+	golang2 := models.Tag{Name: "Golang", Slug: "golang"}
+	databases := models.Tag{Name: "Databases", Slug: "databases"}
+
+	p := createPost(t, s, author, models.Post{Title: "Tagged", Status: models.PUBLISHED, Tags: []models.Tag{golang, databases}})
+	createPost(t, s, author, models.Post{Title: "Also tagged", Status: models.PUBLISHED, Tags: []models.Tag{golang2, golang}})
+	createPost(t, s, author, models.Post{Title: "Draft", Tags: []models.Tag{databases}})
+
+	tags, err := s.FindTags(ctx)
+
+	assert.NoError(t, err)
+
+	counts := map[string]int{}
+
+	for _, tag := range tags {
+		counts[tag.Slug] = tag.Posts
+	}
+
+	assert.Equal(t, map[string]int{"go": 2, "golang": 1, "databases": 1}, counts)
+
+	onPost, err := s.FindTagsByPost(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Len(t, onPost, 2)
+
+	posts, err := s.FindPublishedPosts(ctx, models.PostFilter{Tag: "databases"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Tagged"}, titles(posts))
+
+	from, err := s.FindTagBySlug(ctx, "golang")
+	assert.NoError(t, err)
+
+	into, err := s.FindTagBySlug(ctx, "go")
+	assert.NoError(t, err)
+
+	renamed := from
+	renamed.Name = "Go"
+	renamed.Slug = "go"
+
+	assert.Equal(t, models.ErrTagExists, s.RenameTag(ctx, &renamed))
+
+	//Both posts already have the tag merged into, so this can't create duplicates
+	assert.NoError(t, s.MergeTags(ctx, from, into))
+
+	_, err = s.FindTagBySlug(ctx, "golang")
+
+	assert.Error(t, err)
+
+	count, err := s.CountPublishedPosts(ctx, models.PostFilter{Tag: "go"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	into.Name = "The Go language"
+
+	assert.NoError(t, s.RenameTag(ctx, &into))
+	assert.NoError(t, s.DeleteTag(ctx, into))
+
+	onPost, err = s.FindTagsByPost(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Len(t, onPost, 1)
+
+	//A deleted post takes its tags and revisions with it
+	assert.NoError(t, s.DeletePost(ctx, p))
+
+	onPost, err = s.FindTagsByPost(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Empty(t, onPost)
+
+	revisions, err := s.FindRevisionsByPost(ctx, p.ID)
+
+	assert.NoError(t, err)
+	assert.Empty(t, revisions)
+
+	tags, err = s.FindTags(ctx)
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, tags, 1) {
+		assert.Equal(t, 0, tags[0].Posts)
+	}
+}
+
+func testCategories(t *testing.T, s models.DataStore) {
+
+	author := createUser(t, s, "hades")
+
+	parent := &models.Category{Name: "Programming", Slug: "programming"}
+
+	assert.NoError(t, s.CreateCategory(ctx, parent))
+	assert.NotZero(t, parent.ID)
+
+	child := &models.Category{Name: "Go", Slug: "go", ParentID: &parent.ID}
+
+	assert.NoError(t, s.CreateCategory(ctx, child))
+	assert.NotEqual(t, parent.ID, child.ID)
+
+	assert.Equal(t, models.ErrCategoryExists, s.CreateCategory(ctx, &models.Category{Name: "Golang", Slug: "go"}))
+
+	found, err := s.FindCategoryBySlug(ctx, "go")
+
+	assert.NoError(t, err)
+
+	if assert.NotNil(t, found.ParentID) {
+		assert.Equal(t, parent.ID, *found.ParentID)
+	}
+
+	categories, err := s.FindCategories(ctx)
+
+	assert.NoError(t, err)
+	assert.Len(t, categories, 2)
+
+	createPost(t, s, author, models.Post{Title: "Categorized", Status: models.PUBLISHED, CategoryID: &child.ID})
+
+	p, err := s.FindPublishedPostBySlug(ctx, "Categorized")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "go", p.Category.Slug)
+}
+
+func testSearch(t *testing.T, s models.DataStore) {
+
+	hades := createUser(t, s, "hades")
+	zeus := createUser(t, s, "zeus")
+
+	createPost(t, s, hades, models.Post{Title: "Databases", Content: "Writing a database driver in Go", Status: models.PUBLISHED})
+	createPost(t, s, hades, models.Post{Title: "Gardening", Content: "Growing tomatoes", Status: models.PUBLISHED})
+	createPost(t, s, hades, models.Post{Title: "Secret driver", Content: "Not ready yet", Status: models.DRAFT})
+	createPost(t, s, zeus, models.Post{Title: "Another driver", Content: "Also not ready", Status: models.DRAFT})
+
+	results, err := s.Search(ctx, models.SearchQuery{Terms: "driver"}, 1)
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "Databases", results[0].Title)
+		assert.Equal(t, "hades", results[0].User.Moniker)
+		assert.Contains(t, results[0].Snippet, models.HIGHLIGHT_START+"driver"+models.HIGHLIGHT_END)
+	}
+
+	//Titles rank above content
+	results, err = s.Search(ctx, models.SearchQuery{Terms: "driver", IncludeUnpublished: true}, 1)
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, "Databases", results[2].Title)
+	}
+
+	results, err = s.Search(ctx, models.SearchQuery{Terms: "driver", IncludeUnpublished: true, UserID: zeus.ID}, 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	results, err = s.Search(ctx, models.SearchQuery{Terms: "tomatoes driver"}, 1)
+
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	_, err = s.Search(ctx, models.SearchQuery{Terms: "  "}, 1)
+
+	assert.Equal(t, models.ErrEmptySearch, err)
+}
+
+func testTransactions(t *testing.T, s models.DataStore) {
+
+	err := s.WithTx(ctx, func(tx models.DataStore) error {
+		createUser(t, tx, "hades")
+		_, err := tx.CreateCollaborator(ctx, "zeus@reblog.io", models.CONTRIBUTOR, time.Now().Add(time.Hour))
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, s.DoesUserExist(ctx, "hades@reblog.io", "hades"))
+
+	//Nothing is kept when the function fails, and its error is returned as is
+	failed := errors.New("failed")
+
+	err = s.WithTx(ctx, func(tx models.DataStore) error {
+		createUser(t, tx, "zeus")
+		return failed
+	})
+
+	assert.Equal(t, failed, err)
+	assert.False(t, s.DoesUserExist(ctx, "zeus@reblog.io", "zeus"))
+
+	//Nor when it panics
+	assert.Panics(t, func() {
+		s.WithTx(ctx, func(tx models.DataStore) error {
+			createUser(t, tx, "zeus")
+			panic("failed")
+		})
+	})
+
+	assert.False(t, s.DoesUserExist(ctx, "zeus@reblog.io", "zeus"))
+
+	//A transaction started inside another one is part of it
+	err = s.WithTx(ctx, func(tx models.DataStore) error {
+		createUser(t, tx, "zeus")
+
+		tx.WithTx(ctx, func(tx models.DataStore) error {
+			createUser(t, tx, "athena")
+			return nil
+		})
+
+		return failed
+	})
+
+	assert.Equal(t, failed, err)
+	assert.False(t, s.DoesUserExist(ctx, "zeus@reblog.io", "zeus"))
+	assert.False(t, s.DoesUserExist(ctx, "athena@reblog.io", "athena"))
+
+	//The store can still be used after all of that
+	assert.True(t, s.DoesUserExist(ctx, "hades@reblog.io", "hades"))
+	createUser(t, s, "athena")
+}
+
+func testTokens(t *testing.T, s models.DataStore) {
+
+	u := createUser(t, s, "hades")
+
+	token, err := s.CreateRefreshToken(ctx, u.ID, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.FindRefreshToken(ctx, token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, u.ID, found.UserID)
+	assert.NotEmpty(t, found.Family)
+	assert.Nil(t, found.RevokedAt)
+	assert.WithinDuration(t, time.Now().Add(models.REFRESH_TOKEN_TTL), found.ExpiresAt, time.Minute)
+	assert.False(t, found.Expired(time.Now()))
+
+	//Only the token's hash is stored
+	assert.Equal(t, models.HashRefreshToken(token), found.TokenHash)
+
+	_, err = s.FindRefreshToken(ctx, found.TokenHash)
+
+	assert.Error(t, err)
+
+	//A token can only be revoked once
+	assert.NoError(t, s.RevokeRefreshToken(ctx, found))
+	assert.Equal(t, models.ErrRefreshTokenRevoked, s.RevokeRefreshToken(ctx, found))
+
+	found, err = s.FindRefreshToken(ctx, token)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, found.RevokedAt)
+
+	rotated, err := s.CreateRefreshToken(ctx, u.ID, found.Family)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := s.CreateRefreshToken(ctx, u.ID, "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, s.RevokeTokenFamily(ctx, found.Family))
+
+	revoked, _ := s.FindRefreshToken(ctx, rotated)
+	kept, _ := s.FindRefreshToken(ctx, other)
+
+	assert.Equal(t, found.Family, revoked.Family)
+	assert.NotNil(t, revoked.RevokedAt)
+	assert.NotEqual(t, found.Family, kept.Family)
+	assert.Nil(t, kept.RevokedAt)
+
+	isRevoked, err := s.IsAccessTokenRevoked(ctx, "jti")
+
+	assert.NoError(t, err)
+	assert.False(t, isRevoked)
+
+	assert.NoError(t, s.RevokeAccessToken(ctx, "jti", time.Now().Add(5*time.Minute)))
+	assert.NoError(t, s.RevokeAccessToken(ctx, "jti", time.Now().Add(5*time.Minute)))
+
+	isRevoked, err = s.IsAccessTokenRevoked(ctx, "jti")
+
+	assert.NoError(t, err)
+	assert.True(t, isRevoked)
+
+	//Tokens that have expired already can't be used anyway
+	assert.NoError(t, s.RevokeAccessToken(ctx, "expired", time.Now().Add(-time.Minute)))
+
+	isRevoked, _ = s.IsAccessTokenRevoked(ctx, "expired")
+
+	assert.False(t, isRevoked)
+}
+
+func testPasswordResets(t *testing.T, s models.DataStore) {
+
+	u := createUser(t, s, "hades")
+
+	first, err := s.CreatePasswordReset(ctx, u.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.CreatePasswordReset(ctx, u.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.FindPasswordReset(ctx, second)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, u.ID, found.UserID)
+	assert.Nil(t, found.UsedAt)
+	assert.WithinDuration(t, time.Now().Add(models.PASSWORD_RESET_TTL), found.ExpiresAt, time.Minute)
+	assert.False(t, found.Expired(time.Now()))
+
+	//Only the token's hash is stored
+	assert.Equal(t, models.HashToken(second), found.TokenHash)
+
+	_, err = s.FindPasswordReset(ctx, found.TokenHash)
+
+	assert.Error(t, err)
+
+	//A token can only be used once, and using one uses up the others
+	assert.NoError(t, s.UsePasswordReset(ctx, found))
+	assert.Equal(t, models.ErrPasswordResetUsed, s.UsePasswordReset(ctx, found))
+
+	other, err := s.FindPasswordReset(ctx, first)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, other.UsedAt)
+	assert.Equal(t, models.ErrPasswordResetUsed, s.UsePasswordReset(ctx, other))
+
+	assert.NoError(t, s.UpdatePassword(ctx, u.ID, "new password"))
+	assert.Error(t, s.UpdatePassword(ctx, u.ID+1, "new password"))
+
+	updated, err := s.FindByID(ctx, u.ID)
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, u.Password, updated.Password)
+	assert.NotEqual(t, "new password", updated.Password)
+	assert.True(t, hasher.NewBcryptHasher(bcrypt.DefaultCost).Verify(updated.Password, "new password"))
+
+	//Every refresh token of the user is revoked, whatever login it came from
+	firstLogin, _ := s.CreateRefreshToken(ctx, u.ID, "")
+	secondLogin, _ := s.CreateRefreshToken(ctx, u.ID, "")
+
+	someoneElse := createUser(t, s, "athena")
+	kept, _ := s.CreateRefreshToken(ctx, someoneElse.ID, "")
+
+	assert.NoError(t, s.RevokeUserRefreshTokens(ctx, u.ID))
+
+	for _, token := range []string{firstLogin, secondLogin} {
+		revoked, err := s.FindRefreshToken(ctx, token)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+	}
+
+	notRevoked, _ := s.FindRefreshToken(ctx, kept)
+
+	assert.Nil(t, notRevoked.RevokedAt)
+
+	//So is every refresh token of the users holding a role
+	assert.NoError(t, s.UpdateUserRole(ctx, someoneElse.ID, models.EDITOR))
+
+	fresh, _ := s.CreateRefreshToken(ctx, u.ID, "")
+
+	assert.NoError(t, s.RevokeRoleRefreshTokens(ctx, models.EDITOR))
+
+	revoked, _ := s.FindRefreshToken(ctx, kept)
+
+	assert.NotNil(t, revoked.RevokedAt)
+
+	notRevoked, _ = s.FindRefreshToken(ctx, fresh)
+
+	assert.Nil(t, notRevoked.RevokedAt)
+}
+
+func testOutbox(t *testing.T, s models.DataStore) {
+
+	first := &models.Email{To: "hades@reblog.io", Subject: "Welcome", Text: "Hello Hades", HTML: "<p>Hello Hades</p>"}
+	second := &models.Email{To: "zeus@reblog.io", Subject: "Welcome", Text: "Hello Zeus"}
+
+	assert.NoError(t, s.QueueEmail(ctx, first))
+	assert.NoError(t, s.QueueEmail(ctx, second))
+
+	assert.NotEqual(t, first.ID, second.ID)
+
+	now := time.Now().Add(time.Second)
+
+	due, err := s.FindDueEmails(ctx, now, 10)
+
+	assert.NoError(t, err)
+
+	if len(due) != 2 {
+		t.Fatalf("Expected 2 due emails. Got %d", len(due))
+	}
+
+	assert.Equal(t, first.ID, due[0].ID)
+	assert.Equal(t, "hades@reblog.io", due[0].To)
+	assert.Equal(t, "<p>Hello Hades</p>", due[0].HTML)
+	assert.Equal(t, 0, due[0].Attempts)
+
+	due, _ = s.FindDueEmails(ctx, now, 1)
+
+	assert.Len(t, due, 1)
+
+	//An email can only be claimed once per attempt
+	assert.NoError(t, s.ClaimEmail(ctx, due[0], now.Add(time.Minute)))
+	assert.Equal(t, models.ErrEmailClaimed, s.ClaimEmail(ctx, due[0], now.Add(time.Minute)))
+
+	due, _ = s.FindDueEmails(ctx, now, 10)
+
+	assert.Len(t, due, 1)
+	assert.Equal(t, second.ID, due[0].ID)
+
+	assert.NoError(t, s.MarkEmailFailed(ctx, first.ID, "Connection refused", now.Add(2*time.Minute)))
+
+	due, _ = s.FindDueEmails(ctx, now.Add(3*time.Minute), 10)
+
+	assert.Len(t, due, 2)
+	assert.Equal(t, 1, due[0].Attempts)
+	assert.Equal(t, "Connection refused", due[0].LastError)
+
+	assert.NoError(t, s.ClaimEmail(ctx, due[0], now.Add(4*time.Minute)))
+	assert.NoError(t, s.MarkEmailSent(ctx, first.ID, now.Add(3*time.Minute)))
+
+	//Sent emails are never due again, nor can they be claimed
+	due, _ = s.FindDueEmails(ctx, now.Add(time.Hour), 10)
+
+	assert.Len(t, due, 1)
+	assert.Equal(t, second.ID, due[0].ID)
+
+	sent := *first
+	sent.Attempts = 2
+
+	assert.Equal(t, models.ErrEmailClaimed, s.ClaimEmail(ctx, sent, now.Add(time.Hour)))
+
+	//Emails are given up on after too many attempts
+	for i := 0; i < models.MAX_EMAIL_ATTEMPTS; i++ {
+		due, _ = s.FindDueEmails(ctx, now.Add(time.Hour), 10)
+
+		if len(due) != 1 {
+			t.Fatalf("Expected the email to still be due after %d attempts", i)
+		}
+
+		assert.NoError(t, s.ClaimEmail(ctx, due[0], now))
+	}
+
+	due, _ = s.FindDueEmails(ctx, now.Add(time.Hour), 10)
+
+	assert.Empty(t, due)
+}
+
+func testRoles(t *testing.T, s models.DataStore) {
+
+	roles, err := s.FindRoles(ctx)
+
+	assert.NoError(t, err)
+
+	//Every store starts with the default roles, in the same order
+	if assert.Len(t, roles, len(models.DefaultRoles)) {
+		for i, expected := range models.DefaultRoles {
+			permissions, _ := models.ValidatePermissions(expected.Permissions)
+
+			assert.Equal(t, expected.Name, roles[i].Name)
+			assert.Equal(t, expected.Description, roles[i].Description)
+			assert.Equal(t, permissions, roles[i].Permissions)
+		}
+	}
+
+	author, err := s.FindRole(ctx, models.AUTHOR)
+
+	assert.NoError(t, err)
+	assert.True(t, author.Can(models.POST_PUBLISH))
+	assert.False(t, author.Can(models.POST_REVIEW))
+
+	_, err = s.FindRole(ctx, "janitor")
+
+	assert.Error(t, err)
+
+	hades := createUser(t, s, "hades")
+	zeus := createUser(t, s, "zeus")
+
+	assert.NoError(t, s.UpdateUserRole(ctx, hades.ID, models.AUTHOR))
+	assert.NoError(t, s.UpdateUserRole(ctx, zeus.ID, models.EDITOR))
+
+	hades, _ = s.FindByID(ctx, hades.ID)
+	zeus, _ = s.FindByID(ctx, zeus.ID)
+
+	//Permissions are replaced, duplicates are only saved once
+	assert.NoError(t, s.UpdateRolePermissions(ctx, models.AUTHOR, []string{models.POST_REVIEW, models.POST_CREATE, models.POST_REVIEW}))
+
+	author, err = s.FindRole(ctx, models.AUTHOR)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.POST_CREATE, models.POST_REVIEW}, author.Permissions)
+
+	//Only the tokens of the users holding the role are outdated
+	outdated, _ := s.ArePermissionsOutdated(ctx, strconv.Itoa(hades.ID), hades.PermissionsVersion)
+
+	assert.True(t, outdated)
+
+	outdated, _ = s.ArePermissionsOutdated(ctx, strconv.Itoa(zeus.ID), zeus.PermissionsVersion)
+
+	assert.False(t, outdated)
+
+	assert.NoError(t, s.UpdateRolePermissions(ctx, models.AUTHOR, nil))
+
+	author, _ = s.FindRole(ctx, models.AUTHOR)
+
+	assert.Empty(t, author.Permissions)
+
+	//The owner can always do everything
+	assert.Equal(t, models.ErrRoleLocked, s.UpdateRolePermissions(ctx, models.OWNER, nil))
+
+	owner, _ := s.FindRole(ctx, models.OWNER)
+
+	assert.Len(t, owner.Permissions, len(models.AllPermissions))
+
+	assert.Equal(t, models.ErrUnknownPermission, errors.Cause(s.UpdateRolePermissions(ctx, models.EDITOR, []string{"post:burn"})))
+
+	editor, _ := s.FindRole(ctx, models.EDITOR)
+
+	assert.True(t, editor.Can(models.POST_REVIEW))
+
+	assert.Error(t, s.UpdateRolePermissions(ctx, "janitor", []string{models.POST_CREATE}))
+}
+
+func testProfiles(t *testing.T, s models.DataStore) {
+
+	u := createUser(t, s, "hades")
+
+	assert.Equal(t, "", u.AvatarURL)
+	assert.Equal(t, models.Links{}, u.Links)
+
+	u.Name = "Hades"
+	u.About = "Ruler of the underworld"
+	u.AvatarURL = "https://reblog.io/hades.png"
+	u.Links = models.Links{"twitter": "https://twitter.com/hades", "github": "https://github.com/hades"}
+
+	assert.NoError(t, s.UpdateProfile(ctx, u))
+
+	found, err := s.FindByMoniker(ctx, "hades")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Hades", found.Name)
+	assert.Equal(t, "Ruler of the underworld", found.About)
+	assert.Equal(t, "https://reblog.io/hades.png", found.AvatarURL)
+	assert.Equal(t, u.Links, found.Links)
+
+	//Links are replaced, not merged
+	u.Links = nil
+
+	assert.NoError(t, s.UpdateProfile(ctx, u))
+
+	found, _ = s.FindByID(ctx, u.ID)
+
+	assert.Empty(t, found.Links)
+
+	u.ID++
+
+	assert.Error(t, s.UpdateProfile(ctx, u))
+
+	assert.NoError(t, s.UpdateEmail(ctx, found.ID, "hades@underworld.io"))
+	assert.Error(t, s.UpdateEmail(ctx, found.ID+1, "hades@underworld.io"))
+
+	found, err = s.FindByEmail(ctx, "hades@underworld.io")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hades", found.Moniker)
+}
+
+func testEmailChanges(t *testing.T, s models.DataStore) {
+
+	u := createUser(t, s, "hades")
+
+	first, err := s.CreateEmailChange(ctx, u.ID, "hades@olympus.io")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.CreateEmailChange(ctx, u.ID, "hades@underworld.io")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := s.FindEmailChange(ctx, second)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, u.ID, found.UserID)
+	assert.Equal(t, "hades@underworld.io", found.Email)
+	assert.Nil(t, found.UsedAt)
+	assert.WithinDuration(t, time.Now().Add(models.EMAIL_CHANGE_TTL), found.ExpiresAt, time.Minute)
+	assert.False(t, found.Expired(time.Now()))
+
+	//Only the token's hash is stored
+	assert.Equal(t, models.HashToken(second), found.TokenHash)
+
+	_, err = s.FindEmailChange(ctx, found.TokenHash)
+
+	assert.Error(t, err)
+
+	//A token can only be used once, and using one uses up the others
+	assert.NoError(t, s.UseEmailChange(ctx, found))
+	assert.Equal(t, models.ErrEmailChangeUsed, s.UseEmailChange(ctx, found))
+
+	other, err := s.FindEmailChange(ctx, first)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, other.UsedAt)
+	assert.Equal(t, models.ErrEmailChangeUsed, s.UseEmailChange(ctx, other))
+}
+
+func testUserManagement(t *testing.T, s models.DataStore) {
+
+	hades := createUser(t, s, "hades")
+	zeus := createUser(t, s, "zeus")
+	createUser(t, s, "poseidon")
+
+	assert.NoError(t, s.UpdateUserRole(ctx, zeus.ID, models.EDITOR))
+	assert.Error(t, s.UpdateUserRole(ctx, zeus.ID+100, models.EDITOR))
+
+	zeus, _ = s.FindByID(ctx, zeus.ID)
+
+	assert.Equal(t, models.EDITOR, zeus.Role)
+	assert.False(t, zeus.Suspended())
+
+	suspendedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	assert.NoError(t, s.SuspendUser(ctx, hades.ID, suspendedAt))
+
+	//Suspending a suspended user keeps the first date
+	assert.NoError(t, s.SuspendUser(ctx, hades.ID, time.Now()))
+
+	hades, _ = s.FindByID(ctx, hades.ID)
+
+	if assert.True(t, hades.Suspended()) {
+		assert.True(t, suspendedAt.Equal(*hades.SuspendedAt))
+	}
+
+	suspended, err := s.IsUserSuspended(ctx, strconv.Itoa(hades.ID))
+
+	assert.NoError(t, err)
+	assert.True(t, suspended)
+
+	suspended, _ = s.IsUserSuspended(ctx, strconv.Itoa(zeus.ID))
+
+	assert.False(t, suspended)
+
+	//Users that don't exist can't use their tokens either
+	suspended, _ = s.IsUserSuspended(ctx, strconv.Itoa(zeus.ID+100))
+
+	assert.True(t, suspended)
+
+	//Changing the role of a user outdates the tokens they were issued before
+	assert.NotEqual(t, hades.PermissionsVersion, zeus.PermissionsVersion)
+
+	outdated, err := s.ArePermissionsOutdated(ctx, strconv.Itoa(zeus.ID), zeus.PermissionsVersion)
+
+	assert.NoError(t, err)
+	assert.False(t, outdated)
+
+	outdated, _ = s.ArePermissionsOutdated(ctx, strconv.Itoa(zeus.ID), hades.PermissionsVersion)
+
+	assert.True(t, outdated)
+
+	outdated, _ = s.ArePermissionsOutdated(ctx, strconv.Itoa(zeus.ID+100), 0)
+
+	assert.True(t, outdated)
+
+	find := func(f models.UserFilter) []string {
+		users, err := s.FindUsers(ctx, f)
+
+		assert.NoError(t, err)
+
+		count, err := s.CountUsers(ctx, f)
+
+		assert.NoError(t, err)
+		assert.Equal(t, len(users), count)
+
+		monikers := []string{}
+
+		for _, u := range users {
+			monikers = append(monikers, u.Moniker)
+		}
+
+		return monikers
+	}
+
+	assert.Equal(t, []string{"hades", "zeus", "poseidon"}, find(models.UserFilter{}))
+	assert.Equal(t, []string{"hades"}, find(models.UserFilter{Status: models.USER_SUSPENDED}))
+	assert.Equal(t, []string{"zeus", "poseidon"}, find(models.UserFilter{Status: models.USER_ACTIVE}))
+	assert.Equal(t, []string{"zeus"}, find(models.UserFilter{Role: models.EDITOR}))
+	assert.Equal(t, []string{"poseidon"}, find(models.UserFilter{Query: "POSEI"}))
+	assert.Equal(t, []string{"zeus"}, find(models.UserFilter{Query: "zeus@reblog"}))
+	assert.Equal(t, []string{}, find(models.UserFilter{Query: "%"}))
+
+	users, err := s.FindUsers(ctx, models.UserFilter{Page: 2, PerPage: 2})
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "poseidon", users[0].Moniker)
+	}
+
+	count, _ := s.CountUsers(ctx, models.UserFilter{Page: 2, PerPage: 2})
+
+	assert.Equal(t, 3, count)
+
+	assert.NoError(t, s.ReactivateUser(ctx, hades.ID))
+	assert.Error(t, s.ReactivateUser(ctx, zeus.ID+100))
+
+	hades, _ = s.FindByID(ctx, hades.ID)
+
+	assert.False(t, hades.Suspended())
+}
+
+func testUserPosts(t *testing.T, s models.DataStore) {
+
+	hades := createUser(t, s, "hades")
+	zeus := createUser(t, s, "zeus")
+
+	first := createPost(t, s, hades, models.Post{Title: "First", Tags: []models.Tag{{Name: "Go", Slug: "go"}}})
+	second := createPost(t, s, hades, models.Post{Title: "Second"})
+	other := createPost(t, s, zeus, models.Post{Title: "Other"})
+
+	assert.NoError(t, s.ReassignPosts(ctx, hades.ID, zeus.ID))
+
+	for _, id := range []int{first.ID, second.ID} {
+		p, err := s.FindPostByID(ctx, id)
+
+		assert.NoError(t, err)
+		assert.Equal(t, zeus.ID, p.UserID)
+	}
+
+	assert.NoError(t, s.DeleteUserPosts(ctx, zeus.ID))
+
+	for _, id := range []int{first.ID, second.ID, other.ID} {
+		_, err := s.FindPostByID(ctx, id)
+
+		assert.Error(t, err)
+
+		revisions, err := s.FindRevisionsByPost(ctx, id)
+
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+	}
+
+	tags, err := s.FindTagsByPost(ctx, first.ID)
+
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	//Deleting a user without posts is fine
+	assert.NoError(t, s.DeleteUserPosts(ctx, hades.ID))
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+//ErrTagExists is returned when a write would give two tags the same name or slug
+var ErrTagExists = errors.New("A tag with the same name or slug already exists")
+
+type TagStore interface {
+	FindTags(ctx context.Context) ([]Tag, error)
+	FindTagBySlug(ctx context.Context, slug string) (Tag, error)
+	FindTagsByPost(ctx context.Context, postID int) ([]Tag, error)
+	RenameTag(ctx context.Context, t *Tag) error
+	MergeTags(ctx context.Context, from, into Tag) error
+	DeleteTag(ctx context.Context, t Tag) error
+}
+
+type Tag struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+	Slug string `db:"slug"`
+	//Number of published posts with this tag. Only filled by FindTags
+	Posts int `db:"posts"`
+}
+
+//FindTags lists every tag along with how many published posts use it
+func (db *DB) FindTags(ctx context.Context) ([]Tag, error) {
+	var tags []Tag
+
+	stmt, err := db.PreparexContext(ctx, `SELECT tags.*, COUNT(posts.id) AS posts FROM tags
+LEFT JOIN post_tags ON post_tags.tag_id=tags.id
+LEFT JOIN posts ON posts.id=post_tags.post_id AND posts.status=?
+GROUP BY tags.id ORDER BY tags.name`)
+
+	if err != nil {
+		return tags, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.SelectContext(ctx, &tags, PUBLISHED); err != nil {
+		return tags, errors.Wrap(err, "Could not fetch tags")
+	}
+
+	return tags, nil
+}
+
+func (db *DB) FindTagBySlug(ctx context.Context, slug string) (Tag, error) {
+	var t Tag
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM tags WHERE slug=?")
+
+	if err != nil {
+		return t, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, slug).StructScan(&t); err != nil {
+		return t, errors.Wrap(err, "Tag does not exist")
+	}
+
+	return t, nil
+}
+
+func (db *DB) FindTagsByPost(ctx context.Context, postID int) ([]Tag, error) {
+	var tags []Tag
+
+	stmt, err := db.PreparexContext(ctx, `SELECT tags.* FROM tags INNER JOIN post_tags ON post_tags.tag_id=tags.id
+WHERE post_tags.post_id=? ORDER BY tags.name`)
+
+	if err != nil {
+		return tags, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.SelectContext(ctx, &tags, postID); err != nil {
+		return tags, errors.Wrap(err, "Could not fetch the post's tags")
+	}
+
+	return tags, nil
+}
+
+//RenameTag saves the tag's new name and slug
+func (db *DB) RenameTag(ctx context.Context, t *Tag) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE tags SET name=?, slug=? WHERE id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, t.Name, t.Slug, t.ID)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrTagExists
+		}
+
+		return errors.Wrap(err, "Could not rename tag")
+	}
+
+	if r, err := res.RowsAffected(); err != nil || r != 1 {
+		return errors.New("Could not rename tag")
+	}
+
+	return nil
+}
+
+//MergeTags moves every post tagged with from over to into, then deletes from.
+//Either both happen or neither does.
+func (db *DB) MergeTags(ctx context.Context, from, into Tag) error {
+	return db.WithTx(ctx, func(tx DataStore) error {
+
+		stmt, err := tx.(*DB).PreparexContext(ctx, "INSERT INTO post_tags(post_id, tag_id) SELECT post_id, CAST(? AS INTEGER) FROM post_tags WHERE tag_id=? ON CONFLICT DO NOTHING")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		if _, err = stmt.ExecContext(ctx, into.ID, from.ID); err != nil {
+			return errors.Wrap(err, "Could not merge tags")
+		}
+
+		return tx.DeleteTag(ctx, from)
+	})
+}
+
+//DeleteTag deletes a tag and removes it from every post
+func (db *DB) DeleteTag(ctx context.Context, t Tag) error {
+	return db.WithTx(ctx, func(tx DataStore) error {
+		return tx.(*DB).deleteTag(ctx, t)
+	})
+}
+
+func (db *DB) deleteTag(ctx context.Context, t Tag) error {
+
+	stmt, err := db.PreparexContext(ctx, "DELETE FROM post_tags WHERE tag_id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, t.ID); err != nil {
+		return errors.Wrap(err, "Could not remove the tag from its posts")
+	}
+
+	stmt, err = db.PreparexContext(ctx, "DELETE FROM tags WHERE id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, t.ID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not delete tag")
+	}
+
+	if r, err := res.RowsAffected(); err != nil || r != 1 {
+		return errors.New("Tag does not exist")
+	}
+
+	return nil
+}
+
+//setPostTags replaces the tags of a post.
+//Tags are matched by slug and created if they don't exist yet.
+func (db *DB) setPostTags(ctx context.Context, postID int, tags []Tag) error {
+
+	stmt, err := db.PreparexContext(ctx, "DELETE FROM post_tags WHERE post_id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, postID); err != nil {
+		return errors.Wrap(err, "Could not remove the post's tags")
+	}
+
+	for _, t := range tags {
+
+		stmt, err = db.PreparexContext(ctx, "INSERT INTO tags(name, slug) VALUES(?,?) ON CONFLICT DO NOTHING")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		if _, err = stmt.ExecContext(ctx, t.Name, t.Slug); err != nil {
+			return errors.Wrap(err, "Could not create tag")
+		}
+
+		stmt, err = db.PreparexContext(ctx, "INSERT INTO post_tags(post_id, tag_id) SELECT CAST(? AS INTEGER), id FROM tags WHERE slug=? ON CONFLICT DO NOTHING")
+
+		if err != nil {
+			return errors.Wrap(err, "Could not prepare statement")
+		}
+
+		if _, err = stmt.ExecContext(ctx, postID, t.Slug); err != nil {
+			return errors.Wrap(err, "Could not tag the post")
+		}
+	}
+
+	return nil
+}
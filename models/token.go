@@ -0,0 +1,236 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/adelowo/reblog/utils"
+	"github.com/pkg/errors"
+	"time"
+)
+
+//How long a refresh token can be used for
+const REFRESH_TOKEN_TTL = 30 * 24 * time.Hour
+
+//ErrRefreshTokenRevoked is returned when a refresh token that was already used or revoked is used again
+var ErrRefreshTokenRevoked = errors.New("The refresh token has been revoked")
+
+type TokenStore interface {
+	CreateRefreshToken(ctx context.Context, userID int, family string) (string, error)
+	FindRefreshToken(ctx context.Context, token string) (RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, t RefreshToken) error
+	RevokeTokenFamily(ctx context.Context, family string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	RevokeRoleRefreshTokens(ctx context.Context, role string) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+//RefreshToken is an opaque token used to get new access tokens, only its hash is stored.
+//Using one revokes it and issues a new one in the same family, so every login gets its own family.
+type RefreshToken struct {
+	ID        int        `db:"id"`
+	UserID    int        `db:"user_id"`
+	Family    string     `db:"family"`
+	TokenHash string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+func (t RefreshToken) Expired(now time.Time) bool {
+	return !now.Before(t.ExpiresAt)
+}
+
+//HashToken is what is stored in place of the random tokens handed to users.
+//Tokens are random, so a plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+//HashRefreshToken is what is stored in place of a refresh token
+func HashRefreshToken(token string) string {
+	return HashToken(token)
+}
+
+//NewRefreshToken generates a refresh token along with its family if it is the first of one
+func NewRefreshToken(family string) (token string, newFamily string, err error) {
+
+	if token, err = utils.NewTokenGenerator().Generate(); err != nil {
+		return "", "", errors.Wrap(err, "Could not generate the refresh token")
+	}
+
+	if family != "" {
+		return token, family, nil
+	}
+
+	if family, err = utils.NewTokenGenerator().Generate(); err != nil {
+		return "", "", errors.Wrap(err, "Could not generate the refresh token's family")
+	}
+
+	return token, family, nil
+}
+
+//CreateRefreshToken issues a refresh token to the user in the given family, or in a new one if it is empty
+func (db *DB) CreateRefreshToken(ctx context.Context, userID int, family string) (string, error) {
+
+	token, family, err := NewRefreshToken(family)
+
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	stmt, err := db.PreparexContext(ctx, `INSERT INTO refresh_tokens(user_id, family, token_hash, expires_at, created_at)
+VALUES(?,?,?,?,?)`)
+
+	if err != nil {
+		return "", errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, userID, family, HashRefreshToken(token), now.Add(REFRESH_TOKEN_TTL).UTC(), now.UTC()); err != nil {
+		return "", errors.Wrap(err, "Could not save the refresh token")
+	}
+
+	return token, nil
+}
+
+func (db *DB) FindRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	var t RefreshToken
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM refresh_tokens WHERE token_hash=?")
+
+	if err != nil {
+		return t, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, HashRefreshToken(token)).StructScan(&t); err != nil {
+		return t, errors.Wrap(err, "Refresh token does not exist")
+	}
+
+	return t, nil
+}
+
+//RevokeRefreshToken revokes a token that is still valid.
+//It fails with ErrRefreshTokenRevoked if the token was revoked in the meantime, so a token can only be used once.
+func (db *DB) RevokeRefreshToken(ctx context.Context, t RefreshToken) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE refresh_tokens SET revoked_at=? WHERE id=? AND revoked_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, time.Now().UTC(), t.ID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not revoke the refresh token")
+	}
+
+	if r, err := res.RowsAffected(); err != nil || r != 1 {
+		return ErrRefreshTokenRevoked
+	}
+
+	return nil
+}
+
+//RevokeTokenFamily revokes every token of the family that is still valid
+func (db *DB) RevokeTokenFamily(ctx context.Context, family string) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE refresh_tokens SET revoked_at=? WHERE family=? AND revoked_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, time.Now().UTC(), family); err != nil {
+		return errors.Wrap(err, "Could not revoke the refresh tokens")
+	}
+
+	return nil
+}
+
+//RevokeUserRefreshTokens revokes every refresh token of the user that is still valid, whatever login it came from
+func (db *DB) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE refresh_tokens SET revoked_at=? WHERE user_id=? AND revoked_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, time.Now().UTC(), userID); err != nil {
+		return errors.Wrap(err, "Could not revoke the user's refresh tokens")
+	}
+
+	return nil
+}
+
+//RevokeRoleRefreshTokens revokes every refresh token that is still valid of the users holding the role
+func (db *DB) RevokeRoleRefreshTokens(ctx context.Context, role string) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE refresh_tokens SET revoked_at=? WHERE user_id IN (SELECT id FROM users WHERE role=?) AND revoked_at IS NULL")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, time.Now().UTC(), role); err != nil {
+		return errors.Wrap(err, "Could not revoke the refresh tokens of the role's users")
+	}
+
+	return nil
+}
+
+//RevokeAccessToken denies the access token with the given jti until it expires.
+//Denied tokens that have expired since are forgotten on the way.
+func (db *DB) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+
+	now := time.Now().UTC()
+
+	stmt, err := db.PreparexContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at<?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, now); err != nil {
+		return errors.Wrap(err, "Could not forget expired access tokens")
+	}
+
+	if !expiresAt.After(now) {
+		return nil
+	}
+
+	stmt, err = db.PreparexContext(ctx, "INSERT INTO revoked_tokens(jti, expires_at) VALUES(?,?) ON CONFLICT DO NOTHING")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if _, err = stmt.ExecContext(ctx, jti, expiresAt.UTC()); err != nil {
+		return errors.Wrap(err, "Could not revoke the access token")
+	}
+
+	return nil
+}
+
+func (db *DB) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+
+	stmt, err := db.PreparexContext(ctx, "SELECT COUNT(*) FROM revoked_tokens WHERE jti=?")
+
+	if err != nil {
+		return false, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	var count int
+
+	if err = stmt.GetContext(ctx, &count, jti); err != nil {
+		return false, errors.Wrap(err, "Could not check whether the access token was revoked")
+	}
+
+	return count != 0, nil
+}
@@ -1,12 +1,32 @@
 package models
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+)
 
 type DataStore interface {
 	UserStore
 	PostStore
+	RevisionStore
+	TagStore
+	CategoryStore
+	TokenStore
+	PasswordResetStore
+	OutboxStore
+	RoleStore
+	EmailChangeStore
+
+	//WithTx runs fn in a transaction, committing it if fn returns nil and rolling it back if fn fails or panics.
+	//fn must only use the tx it is given. Calling WithTx on tx runs in the same transaction.
+	WithTx(ctx context.Context, fn func(tx DataStore) error) error
 }
 
 type DB struct {
 	*sqlx.DB
+
+	//Set on the copy of the DB handed to WithTx's function, every statement then runs in that transaction
+	tx *sqlx.Tx
+
+	stmts *statements
 }
@@ -1,54 +1,197 @@
 package models
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
 	"github.com/adelowo/gotils/hasher"
 	"github.com/adelowo/reblog/utils"
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type UserStore interface {
-	FindByEmail(email string) (User, error)
-	DeleteUser(u User) error
-	DoesUserExist(email, moniker string) bool
-	FindByMoniker(moniker string) (User, error)
-	CreateUser(u *User) error
-	CreateCollaborator(email string) error
-	FindCollaboratorByToken(token string) (Collaborator, error)
-	DeleteCollaborator(c Collaborator) error
+	FindByEmail(ctx context.Context, email string) (User, error)
+	DeleteUser(ctx context.Context, u User) error
+	DoesUserExist(ctx context.Context, email, moniker string) bool
+	FindByMoniker(ctx context.Context, moniker string) (User, error)
+	FindByID(ctx context.Context, id int) (User, error)
+	CreateUser(ctx context.Context, u *User) error
+	UpdatePassword(ctx context.Context, userID int, password string) error
+	UpdateProfile(ctx context.Context, u User) error
+	UpdateEmail(ctx context.Context, userID int, email string) error
+	FindUsers(ctx context.Context, f UserFilter) ([]User, error)
+	CountUsers(ctx context.Context, f UserFilter) (int, error)
+	UpdateUserRole(ctx context.Context, userID int, role string) error
+	SuspendUser(ctx context.Context, userID int, at time.Time) error
+	ReactivateUser(ctx context.Context, userID int) error
+	IsUserSuspended(ctx context.Context, subject string) (bool, error)
+	ArePermissionsOutdated(ctx context.Context, subject string, version int) (bool, error)
+	CreateCollaborator(ctx context.Context, email, role string, expiresAt time.Time) (Collaborator, error)
+	FindCollaborators(ctx context.Context) ([]Collaborator, error)
+	FindCollaboratorByID(ctx context.Context, id int) (Collaborator, error)
+	FindCollaboratorByToken(ctx context.Context, token string) (Collaborator, error)
+	RotateCollaboratorToken(ctx context.Context, id int, expiresAt time.Time) (Collaborator, error)
+	DeleteCollaborator(ctx context.Context, c Collaborator) error
 }
 
+//User is an account on the blog. Password is the bcrypt hash of the user's password and is never encoded to JSON.
+//SuspendedAt is nil unless the user has been suspended.
+//PermissionsVersion changes every time the user's permissions do, access tokens carry the version they were issued with.
 type User struct {
-	ID        int       `db:"id"`
-	Moniker   string    `db:"moniker"`
-	Type      int       `db:"type"`
-	Name      string    `db:"full_name"`
-	About     string    `db:"about"`
-	Email     string    `db:"email"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
-	Password  string    `db:"password"`
+	ID                 int        `db:"id"`
+	Moniker            string     `db:"moniker"`
+	Role               string     `db:"role"`
+	Name               string     `db:"full_name"`
+	About              string     `db:"about"`
+	AvatarURL          string     `db:"avatar_url"`
+	Links              Links      `db:"links"`
+	Email              string     `db:"email"`
+	SuspendedAt        *time.Time `db:"suspended_at"`
+	PermissionsVersion int        `db:"permissions_version"`
+	CreatedAt          time.Time  `db:"created_at"`
+	UpdatedAt          time.Time  `db:"updated_at"`
+	Password           string     `db:"password" json:"-"`
+}
+
+//Suspended tells if the user is kept from logging in
+func (u User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+const (
+	USER_ACTIVE    = "active"
+	USER_SUSPENDED = "suspended"
+)
+
+//UserFilter describes which users should be listed.
+//Zero values are ignored, so an empty filter lists every user, oldest first.
+//Status is either USER_ACTIVE or USER_SUSPENDED and Query matches part of the moniker, name or email, whatever the case.
+type UserFilter struct {
+	Page    int
+	PerPage int
+	Role    string
+	Status  string
+	Query   string
+}
+
+//Offset returns the number of rows to skip for the requested page
+func (f UserFilter) Offset() int {
+	if f.Page < 1 {
+		return 0
+	}
+
+	return (f.Page - 1) * f.Limit()
+}
+
+func (f UserFilter) Limit() int {
+	if f.PerPage < 1 {
+		return DEFAULT_PER_PAGE
+	}
+
+	return f.PerPage
 }
 
+func (f UserFilter) where() (string, []interface{}) {
+	conditions := []string{"1=1"}
+	var args []interface{}
+
+	if f.Role != "" {
+		conditions = append(conditions, "role=?")
+		args = append(args, f.Role)
+	}
+
+	switch f.Status {
+	case USER_ACTIVE:
+		conditions = append(conditions, "suspended_at IS NULL")
+	case USER_SUSPENDED:
+		conditions = append(conditions, "suspended_at IS NOT NULL")
+	}
+
+	if f.Query != "" {
+		//% and _ are matched literally
+		q := "%" + likeEscaper.Replace(strings.ToLower(f.Query)) + "%"
+
+		conditions = append(conditions, `(LOWER(moniker) LIKE ? ESCAPE '\' OR LOWER(full_name) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\')`)
+		args = append(args, q, q, q)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+//Links are the user's social links, e.g twitter or github, keyed by the site's name.
+//They are stored as a JSON object.
+type Links map[string]string
+
+func (l Links) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(map[string]string(l))
+
+	return string(b), err
+}
+
+func (l *Links) Scan(src interface{}) error {
+
+	var b []byte
+
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*l = Links{}
+		return nil
+	default:
+		return errors.Errorf("Cannot scan %T into links", src)
+	}
+
+	links := Links{}
+
+	if err := json.Unmarshal(b, &links); err != nil {
+		return errors.Wrap(err, "Could not decode the links")
+	}
+
+	*l = links
+
+	return nil
+}
+
+//Collaborator is a pending invitation to sign up.
+//Role is the role the collaborator has once they sign up.
 type Collaborator struct {
 	ID        int       `db:"id"`
 	Token     string    `db:"token"`
 	Email     string    `db:"email"`
+	Role      string    `db:"role"`
+	ExpiresAt time.Time `db:"expires_at"`
 	CreatedAt time.Time `db:"created_at"`
 }
 
-func (db *DB) FindByEmail(email string) (User, error) {
+//Expired tells if the invitation can no longer be used to sign up at now
+func (c Collaborator) Expired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+func (db *DB) FindByEmail(ctx context.Context, email string) (User, error) {
 
 	var u User
 
-	stmt, err := db.Preparex("SELECT * FROM users WHERE email=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM users WHERE email=?")
 
 	if err != nil {
 		return User{}, errors.Wrap(err, "An error occurred while we tried preparing this statement")
 	}
 
-	row := stmt.QueryRowx(email)
+	row := stmt.QueryRowxContext(ctx, email)
 
 	err = row.StructScan(&u)
 
@@ -59,17 +202,17 @@ func (db *DB) FindByEmail(email string) (User, error) {
 	return u, nil
 }
 
-func (db *DB) FindByMoniker(moniker string) (User, error) {
+func (db *DB) FindByMoniker(ctx context.Context, moniker string) (User, error) {
 
 	var u User
 
-	stmt, err := db.Preparex("SELECT * FROM users WHERE moniker=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM users WHERE moniker=?")
 
 	if err != nil {
 		return User{}, errors.Wrap(err, "An error occurred while we tried preparing this statement")
 	}
 
-	row := stmt.QueryRowx(moniker)
+	row := stmt.QueryRowxContext(ctx, moniker)
 
 	err = row.StructScan(&u)
 
@@ -80,14 +223,31 @@ func (db *DB) FindByMoniker(moniker string) (User, error) {
 	return u, nil
 }
 
-func (db *DB) DoesUserExist(email, moniker string) bool {
+func (db *DB) FindByID(ctx context.Context, id int) (User, error) {
+
+	var u User
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM users WHERE id=?")
+
+	if err != nil {
+		return User{}, errors.Wrap(err, "An error occurred while we tried preparing this statement")
+	}
+
+	if err = stmt.QueryRowxContext(ctx, id).StructScan(&u); err != nil {
+		return User{}, errors.Wrap(err, "Could not find a user with the specified id")
+	}
+
+	return u, nil
+}
+
+func (db *DB) DoesUserExist(ctx context.Context, email, moniker string) bool {
 	//_, err1 := db.FindByEmail(email)
 	//_, err2 := db.FindByMoniker(moniker)
 	//
 	//return err1 == nil && err2 == nil
 
 	var u User
-	stmt, err := db.Preparex("SELECT * FROM users WHERE email=? OR moniker=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM users WHERE email=? OR moniker=?")
 
 	//Just silence the error
 	//All we want is a bool
@@ -95,9 +255,9 @@ func (db *DB) DoesUserExist(email, moniker string) bool {
 		return false
 	}
 
-	rows := stmt.QueryRowx(email, moniker)
+	rows := stmt.QueryRowxContext(ctx, email, moniker)
 
-	err = rows.Scan(&u)
+	err = rows.StructScan(&u)
 
 	if err != nil {
 		return false
@@ -106,23 +266,28 @@ func (db *DB) DoesUserExist(email, moniker string) bool {
 	return true
 }
 
-func (db *DB) CreateUser(u *User) error {
+func (db *DB) CreateUser(ctx context.Context, u *User) error {
 
 	hashed, err := hasher.NewBcryptHasher(bcrypt.DefaultCost).Hash(u.Password)
 
 	if err != nil {
-		return nil
+		return errors.Wrap(err, "Could not hash the user's password")
+	}
+
+	//Users sign up as contributors unless they were given another role
+	if u.Role == "" {
+		u.Role = CONTRIBUTOR
 	}
 
 	now := time.Now()
 
-	stmt, err := db.Preparex("INSERT INTO users(moniker,full_name,password,email,created_at,updated_at) VALUES(?,?,?,?,?,?)")
+	stmt, err := db.PreparexContext(ctx, "INSERT INTO users(moniker,role,full_name,password,email,created_at,updated_at) VALUES(?,?,?,?,?,?,?)")
 
 	if err != nil {
 		return errors.Wrap(err, "Could not prepare the insert statement")
 	}
 
-	count, err := stmt.MustExec(u.Moniker, u.Name, hashed, u.Email, now, now).
+	count, err := stmt.MustExecContext(ctx, u.Moniker, u.Role, u.Name, hashed, u.Email, now, now).
 		RowsAffected()
 
 	if err == nil && count == 1 {
@@ -132,66 +297,219 @@ func (db *DB) CreateUser(u *User) error {
 	return errors.Wrap(err, "Could not create user")
 }
 
-func (db *DB) CreateCollaborator(email string) error {
+//UpdatePassword hashes and saves the user's new password
+func (db *DB) UpdatePassword(ctx context.Context, userID int, password string) error {
+
+	hashed, err := hasher.NewBcryptHasher(bcrypt.DefaultCost).Hash(password)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not hash the user's password")
+	}
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE users SET password=?, updated_at=? WHERE id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, hashed, time.Now(), userID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not update the user's password")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("Could not find a user with the specified id")
+	}
+
+	return nil
+}
+
+//UpdateProfile saves the user's name, about, avatar and links
+func (db *DB) UpdateProfile(ctx context.Context, u User) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE users SET full_name=?, about=?, avatar_url=?, links=?, updated_at=? WHERE id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, u.Name, u.About, u.AvatarURL, u.Links, time.Now(), u.ID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not update the user's profile")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("Could not find a user with the specified id")
+	}
+
+	return nil
+}
+
+//UpdateEmail changes the user's email address, which has been verified already
+func (db *DB) UpdateEmail(ctx context.Context, userID int, email string) error {
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE users SET email=?, updated_at=? WHERE id=?")
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, email, time.Now(), userID)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not update the user's email")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("Could not find a user with the specified id")
+	}
+
+	return nil
+}
+
+//CreateCollaborator adds a collaborator with a fresh token, or replaces the token, role and expiry of one with the same email
+func (db *DB) CreateCollaborator(ctx context.Context, email, role string, expiresAt time.Time) (Collaborator, error) {
+	var c Collaborator
+
+	err := db.WithTx(ctx, func(tx DataStore) error {
+		var err error
+		c, err = tx.(*DB).createCollaborator(ctx, email, role, expiresAt)
+		return err
+	})
+
+	return c, err
+}
+
+func (db *DB) createCollaborator(ctx context.Context, email, role string, expiresAt time.Time) (Collaborator, error) {
 
 	token, err := utils.NewTokenGenerator().Generate()
 
 	if err != nil {
-		return errors.Wrap(err, "Could not generate token for collaborator")
+		return Collaborator{}, errors.Wrap(err, "Could not generate token for collaborator")
 	}
 
 	var u Collaborator
 
-	stmt, err := db.Preparex("SELECT * FROM collaborator_tokens WHERE email=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM collaborator_tokens WHERE email=?")
 
 	if err != nil {
-		return errors.Wrap(err, "Could not prepare statement")
+		return Collaborator{}, errors.Wrap(err, "Could not prepare statement")
 	}
 
-	err = stmt.QueryRowx(email).StructScan(&u)
+	err = stmt.QueryRowxContext(ctx, email).StructScan(&u)
 
 	createdAt := time.Now()
 
 	if err != nil {
 		//The user does not exist, we can add the collaborator
 
-		stmt, err = db.Preparex("INSERT INTO collaborator_tokens(email,token,created_at) VALUES(?,?,?)")
-		if err == nil {
+		id, err := db.insert(ctx, "INSERT INTO collaborator_tokens(email,token,role,expires_at,created_at) VALUES(?,?,?,?,?)",
+			email, token, role, expiresAt, createdAt)
 
-			if id, err := stmt.MustExec(email, token, createdAt).LastInsertId(); err == nil && id == 1 {
-				return nil
-			}
+		if err != nil {
+			return Collaborator{}, errors.Wrap(err, "Could not add the collaborator")
 		}
 
-		return errors.Wrap(err, "An error occured while preparing the insert statement")
+		return Collaborator{ID: id, Email: email, Token: token, Role: role, ExpiresAt: expiresAt, CreatedAt: createdAt}, nil
 	}
 
 	//THe user def exists, so we update here
-	stmt, err = db.Preparex("UPDATE collaborator_tokens SET token=?,created_at=? WHERE email=?")
+	stmt, err = db.PreparexContext(ctx, "UPDATE collaborator_tokens SET token=?,role=?,expires_at=?,created_at=? WHERE email=?")
 
 	if err != nil {
-		return errors.Wrap(err, "An error occured while preparing the update statement")
+		return Collaborator{}, errors.Wrap(err, "An error occured while preparing the update statement")
 	}
 
-	if id, err := stmt.MustExec(token, createdAt, email).LastInsertId(); err == nil && id == 1 {
-		return nil
+	if r, err := stmt.MustExecContext(ctx, token, role, expiresAt, createdAt, email).RowsAffected(); err != nil || r != 1 {
+		return Collaborator{}, errors.New("An error occured while trying to update the collaborator's row")
+	}
+
+	u.Token = token
+	u.Role = role
+	u.ExpiresAt = expiresAt
+	u.CreatedAt = createdAt
+
+	return u, nil
+
+}
+
+//FindCollaborators returns every pending invitation, expired ones included, newest first
+func (db *DB) FindCollaborators(ctx context.Context) ([]Collaborator, error) {
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM collaborator_tokens ORDER BY created_at DESC, id DESC")
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not prepare statement")
 	}
 
-	return errors.Wrap(err, "An error occured while trying to update the collaborator's row")
+	collaborators := []Collaborator{}
+
+	if err = stmt.SelectContext(ctx, &collaborators); err != nil {
+		return nil, errors.Wrap(err, "Could not fetch the collaborators")
+	}
 
+	return collaborators, nil
 }
 
-func (db *DB) FindCollaboratorByToken(token string) (Collaborator, error) {
+func (db *DB) FindCollaboratorByID(ctx context.Context, id int) (Collaborator, error) {
 
 	var c Collaborator
 
-	stmt, err := db.Preparex("SELECT * FROM collaborator_tokens WHERE token=?")
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM collaborator_tokens WHERE id=?")
 
 	if err != nil {
 		return Collaborator{}, errors.Wrap(err, "Failed to prepare statement")
 	}
 
-	err = stmt.QueryRowx(token).
+	if err = stmt.QueryRowxContext(ctx, id).StructScan(&c); err != nil {
+		return Collaborator{}, errors.Wrap(err, "Collaborator not found")
+	}
+
+	return c, nil
+}
+
+//RotateCollaboratorToken gives the invitation a fresh token valid until expiresAt.
+//The previous token can't be used to sign up anymore.
+func (db *DB) RotateCollaboratorToken(ctx context.Context, id int, expiresAt time.Time) (Collaborator, error) {
+
+	token, err := utils.NewTokenGenerator().Generate()
+
+	if err != nil {
+		return Collaborator{}, errors.Wrap(err, "Could not generate token for collaborator")
+	}
+
+	stmt, err := db.PreparexContext(ctx, "UPDATE collaborator_tokens SET token=?,expires_at=?,created_at=? WHERE id=?")
+
+	if err != nil {
+		return Collaborator{}, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, token, expiresAt, time.Now(), id)
+
+	if err != nil {
+		return Collaborator{}, errors.Wrap(err, "Could not rotate the collaborator's token")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return Collaborator{}, errors.New("Collaborator not found")
+	}
+
+	return db.FindCollaboratorByID(ctx, id)
+}
+
+func (db *DB) FindCollaboratorByToken(ctx context.Context, token string) (Collaborator, error) {
+
+	var c Collaborator
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM collaborator_tokens WHERE token=?")
+
+	if err != nil {
+		return Collaborator{}, errors.Wrap(err, "Failed to prepare statement")
+	}
+
+	err = stmt.QueryRowxContext(ctx, token).
 		StructScan(&c)
 
 	if err != nil {
@@ -201,32 +519,164 @@ func (db *DB) FindCollaboratorByToken(token string) (Collaborator, error) {
 	return c, nil
 }
 
-func (db *DB) DeleteCollaborator(c Collaborator) error {
-	stmt, err := db.Preparex("DELETE FROM collaborator_tokens WHERE email=?")
+func (db *DB) DeleteCollaborator(ctx context.Context, c Collaborator) error {
+	stmt, err := db.PreparexContext(ctx, "DELETE FROM collaborator_tokens WHERE email=?")
 
 	if err != nil {
 		return errors.Wrap(err, "Could not prepare statement")
 	}
 
-	if x, _ := stmt.MustExec(c.Email).RowsAffected(); x == 1 {
+	if x, _ := stmt.MustExecContext(ctx, c.Email).RowsAffected(); x == 1 {
 		return nil
 	}
 
 	return errors.New("An error occured while we tried deleting the collaborator")
 }
 
-func (db *DB) DeleteUser(u User) error {
+//DeleteUser removes the user's account. Their posts have to be reassigned or deleted beforehand
+func (db *DB) DeleteUser(ctx context.Context, u User) error {
 
-	stmt, err := db.Preparex("DELETE FROM user WHERE email=?")
+	stmt, err := db.PreparexContext(ctx, "DELETE FROM users WHERE id=?")
 
 	if err != nil {
 		return errors.Wrap(err, "Could not prepare statement")
 	}
 
-	if x, _ := stmt.MustExec(u.Email).RowsAffected(); x == 1 {
-		return nil
+	res, err := stmt.ExecContext(ctx, u.ID)
+
+	if err != nil {
+		return errors.Wrap(err, "An error occured while we tried deleting the user")
 	}
 
-	return errors.New("An error occured while we tried deleting the collaborator")
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("Could not find a user with the specified id")
+	}
+
+	return nil
+}
+
+//FindUsers lists the users matching the filter, oldest first
+func (db *DB) FindUsers(ctx context.Context, f UserFilter) ([]User, error) {
+	users := []User{}
+
+	where, args := f.where()
+
+	stmt, err := db.PreparexContext(ctx, "SELECT * FROM users WHERE "+where+" ORDER BY id ASC LIMIT ? OFFSET ?")
+
+	if err != nil {
+		return users, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	args = append(args, f.Limit(), f.Offset())
+
+	if err = stmt.SelectContext(ctx, &users, args...); err != nil {
+		return users, errors.Wrap(err, "Could not fetch the users")
+	}
+
+	return users, nil
+}
+
+func (db *DB) CountUsers(ctx context.Context, f UserFilter) (int, error) {
+	var count int
+
+	where, args := f.where()
+
+	stmt, err := db.PreparexContext(ctx, "SELECT COUNT(*) FROM users WHERE "+where)
+
+	if err != nil {
+		return count, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	if err = stmt.GetContext(ctx, &count, args...); err != nil {
+		return count, errors.Wrap(err, "Could not count the users")
+	}
+
+	return count, nil
+}
+
+//UpdateUserRole gives the user another role. The access tokens they were issued before are outdated
+func (db *DB) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	return db.updateUser(ctx, "UPDATE users SET role=?, permissions_version=permissions_version+1, updated_at=? WHERE id=?", role, time.Now(), userID)
+}
+
+//SuspendUser keeps the user from logging in from at onwards. Suspending a suspended user keeps the first date
+func (db *DB) SuspendUser(ctx context.Context, userID int, at time.Time) error {
+	return db.updateUser(ctx, "UPDATE users SET suspended_at=COALESCE(suspended_at, ?), updated_at=? WHERE id=?", at.UTC(), time.Now(), userID)
+}
+
+//ReactivateUser lifts the user's suspension
+func (db *DB) ReactivateUser(ctx context.Context, userID int) error {
+	return db.updateUser(ctx, "UPDATE users SET suspended_at=NULL, updated_at=? WHERE id=?", time.Now(), userID)
+}
+
+//updateUser runs an update of a single user, the last argument being their id
+func (db *DB) updateUser(ctx context.Context, query string, args ...interface{}) error {
+
+	stmt, err := db.PreparexContext(ctx, query)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not prepare statement")
+	}
+
+	res, err := stmt.ExecContext(ctx, args...)
+
+	if err != nil {
+		return errors.Wrap(err, "Could not update the user")
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("Could not find a user with the specified id")
+	}
+
+	return nil
+}
+
+//IsUserSuspended tells if the user whose id is subject, the sub of their access tokens, is suspended.
+//Users that no longer exist are reported as suspended too, so their tokens are refused.
+func (db *DB) IsUserSuspended(ctx context.Context, subject string) (bool, error) {
+
+	id, err := strconv.Atoi(subject)
+
+	if err != nil {
+		return true, nil
+	}
+
+	stmt, err := db.PreparexContext(ctx, "SELECT COUNT(*) FROM users WHERE id=? AND suspended_at IS NULL")
+
+	if err != nil {
+		return false, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	var count int
+
+	if err = stmt.GetContext(ctx, &count, id); err != nil {
+		return false, errors.Wrap(err, "Could not check whether the user is suspended")
+	}
+
+	return count == 0, nil
+}
+
+//ArePermissionsOutdated tells if the permissions of the user whose id is subject have changed since
+//an access token carrying version was issued to them. Users that no longer exist are reported as outdated too.
+func (db *DB) ArePermissionsOutdated(ctx context.Context, subject string, version int) (bool, error) {
+
+	id, err := strconv.Atoi(subject)
+
+	if err != nil {
+		return true, nil
+	}
+
+	stmt, err := db.PreparexContext(ctx, "SELECT COUNT(*) FROM users WHERE id=? AND permissions_version=?")
+
+	if err != nil {
+		return false, errors.Wrap(err, "Could not prepare statement")
+	}
+
+	var count int
+
+	if err = stmt.GetContext(ctx, &count, id, version); err != nil {
+		return false, errors.Wrap(err, "Could not check whether the user's permissions changed")
+	}
 
+	return count == 0, nil
 }
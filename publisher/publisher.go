@@ -0,0 +1,91 @@
+package publisher
+
+import (
+	"context"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/utils"
+	"log"
+	"sync"
+	"time"
+)
+
+//Publisher periodically publishes scheduled posts whose publishing date has passed.
+//
+//Several publishers (one per server instance) can safely run against the same database.
+//Every post is moved from scheduled to published with models.PostStore.TransitionPost,
+//which only succeeds if the post is still scheduled, so a post is never published twice.
+type Publisher struct {
+	db       models.PostStore
+	clock    utils.Clock
+	interval time.Duration
+
+	once sync.Once
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(db models.PostStore, clock utils.Clock, interval time.Duration) *Publisher {
+	return &Publisher{db: db, clock: clock, interval: interval, done: make(chan struct{})}
+}
+
+//Start runs the publisher in the background until Stop is called
+func (p *Publisher) Start() {
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.PublishDuePosts(context.Background()); err != nil {
+				log.Println(err)
+			}
+
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+//Stop waits for the current run, if any, to finish and stops the publisher
+func (p *Publisher) Stop() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+
+	p.wg.Wait()
+}
+
+//PublishDuePosts publishes every scheduled post that is due and reports how many were published.
+//Posts published by another instance in the meantime are skipped.
+func (p *Publisher) PublishDuePosts(ctx context.Context) (int, error) {
+
+	posts, err := p.db.FindDuePosts(ctx, p.clock.Now())
+
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+
+	for i := range posts {
+		err := p.db.TransitionPost(ctx, &posts[i], models.PUBLISHED, "")
+
+		if err == models.ErrInvalidTransition {
+			continue
+		}
+
+		if err != nil {
+			return published, err
+		}
+
+		published++
+	}
+
+	return published, nil
+}
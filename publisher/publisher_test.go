@@ -0,0 +1,110 @@
+package publisher
+
+import (
+	"context"
+	"github.com/adelowo/reblog/models"
+	"github.com/adelowo/reblog/models/mocks"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+var now = time.Date(2017, time.February, 1, 9, 0, 0, 0, time.UTC)
+
+func TestDuePostsArePublished(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	publishAt := now.Add(-time.Minute)
+
+	first := models.Post{ID: 1, Status: models.SCHEDULED, PublishAt: &publishAt}
+	second := models.Post{ID: 2, Status: models.SCHEDULED, PublishAt: &publishAt}
+
+	db.On("FindDuePosts", mock.Anything, now).Once().Return([]models.Post{first, second}, nil)
+	db.On("TransitionPost", mock.Anything, &first, models.PUBLISHED, "").Once().Return(nil)
+	db.On("TransitionPost", mock.Anything, &second, models.PUBLISHED, "").Once().Return(nil)
+
+	count, err := New(db, fixedClock{now}, time.Minute).PublishDuePosts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	db.AssertExpectations(t)
+}
+
+func TestPostsPublishedByAnotherInstanceAreSkipped(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	first := models.Post{ID: 1, Status: models.SCHEDULED}
+	second := models.Post{ID: 2, Status: models.SCHEDULED}
+
+	db.On("FindDuePosts", mock.Anything, now).Once().Return([]models.Post{first, second}, nil)
+	db.On("TransitionPost", mock.Anything, &first, models.PUBLISHED, "").Once().Return(models.ErrInvalidTransition)
+	db.On("TransitionPost", mock.Anything, &second, models.PUBLISHED, "").Once().Return(nil)
+
+	count, err := New(db, fixedClock{now}, time.Minute).PublishDuePosts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestPublishingStopsOnError(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	db.On("FindDuePosts", mock.Anything, now).Once().Return(nil, errors.New("Could not fetch due posts"))
+
+	count, err := New(db, fixedClock{now}, time.Minute).PublishDuePosts(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestThePublisherStopsCleanly(t *testing.T) {
+
+	db := new(mocks.DataStore)
+
+	ran := make(chan struct{}, 1)
+
+	db.On("FindDuePosts", mock.Anything, now).Return([]models.Post{}, nil).Run(func(args mock.Arguments) {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+	})
+
+	p := New(db, fixedClock{now}, time.Millisecond)
+
+	p.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("The publisher never ran")
+	}
+
+	stopped := make(chan struct{})
+
+	go func() {
+		p.Stop()
+		p.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("The publisher did not stop")
+	}
+}
@@ -0,0 +1,19 @@
+package utils
+
+import "time"
+
+//Clock tells the time.
+//Code that acts on time should depend on a Clock so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct{}
+
+func (c SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func NewSystemClock() SystemClock {
+	return SystemClock{}
+}
@@ -1,46 +1,258 @@
 package utils
 
 import (
-	"github.com/goware/jwtauth"
+	"context"
+	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
-	"os"
+	"net/http"
+	"strings"
 	"time"
 )
 
+//ErrNoToken is the request's jwt.err when it doesn't carry an access token
+var ErrNoToken = errors.New("jwtauth: no token found")
+
+//ErrTokenInvalid is the request's jwt.err when its access token can't be verified or has expired
+var ErrTokenInvalid = errors.New("jwtauth: invalid token")
+
+//ErrTokenRevoked is the request's jwt.err when its access token has been revoked
+var ErrTokenRevoked = errors.New("jwtauth: revoked token")
+
+//ErrUserSuspended is the request's jwt.err when the user its access token was issued to is suspended or no longer exists
+var ErrUserSuspended = errors.New("jwtauth: suspended user")
+
+//ErrPermissionsChanged is the request's jwt.err when the permissions of the user its access token was issued to have changed since
+var ErrPermissionsChanged = errors.New("jwtauth: permissions changed")
+
+//ErrTokenAudience is the request's jwt.err when its access token was issued by or for someone else
+var ErrTokenAudience = errors.New("jwtauth: token issued for another audience")
+
+//Denylist holds the access tokens revoked before they expired, by jti,
+//the users who can't use theirs anymore, by sub,
+//and the users whose permissions changed since their tokens were issued, by sub and pv
+type Denylist interface {
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	IsUserSuspended(ctx context.Context, subject string) (bool, error)
+	ArePermissionsOutdated(ctx context.Context, subject string, version int) (bool, error)
+}
+
+//JWTTokenGenerator signs and verifies access tokens.
+//It holds no per token state, so a single generator can be shared by every request.
 type JWTTokenGenerator struct {
-	*jwtauth.JwtAuth
-	claims jwtauth.Claims
+	//Issuer and Audience are set as the iss and aud of every token.
+	//The verifier refuses tokens that don't carry the same values
+	Issuer   string
+	Audience string
+
+	//TTL is how long a token is valid for from the moment it is generated
+	TTL time.Duration
+
+	Clock Clock
+
+	keys     []SigningKey
+	denylist Denylist
 }
 
-func NewJWTGenerator() *JWTTokenGenerator {
-	c := make(jwtauth.Claims, 10)
+//NewJWTGenerator signs tokens with keys, see SigningKey for how they are rotated.
+//It fails if no key is given, or if one of them can't be used.
+func NewJWTGenerator(keys ...SigningKey) (*JWTTokenGenerator, error) {
 
-	c.SetExpiryIn(timeFrame())
+	keys, err := sortKeys(keys)
 
-	return &JWTTokenGenerator{jwtauth.New("HS256", []byte(os.Getenv("JWT")), nil), c}
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTTokenGenerator{
+		Issuer:   "reblog",
+		Audience: "reblog",
+		TTL:      timeFrame(),
+		Clock:    NewSystemClock(),
+		keys:     keys,
+	}, nil
 }
 
-func (j *JWTTokenGenerator) Claims(c map[string]interface{}) {
-	for k, v := range c {
-		j.claims.Set(k, v)
+func MustNewJWTGenerator(keys ...SigningKey) *JWTTokenGenerator {
+	j, err := NewJWTGenerator(keys...)
+
+	if err != nil {
+		panic(err)
 	}
+
+	return j
+}
+
+//UseDenylist makes the verifier reject the access tokens held by d, those of the users it reports as suspended
+//and those carrying permissions it reports as outdated
+func (j *JWTTokenGenerator) UseDenylist(d Denylist) {
+	j.denylist = d
 }
 
-func (j *JWTTokenGenerator) Generate() (string, error) {
+//Generate signs a token for subject carrying claims.
+//iat, nbf and exp are computed on every call and sub, iss, aud and jti are set by the generator,
+//overriding any value claims holds for them.
+//Every token gets its own jti, so it can be revoked on its own.
+func (j *JWTTokenGenerator) Generate(subject string, claims map[string]interface{}) (string, error) {
+
+	if subject == "" {
+		return "", errors.New("Jwt subject not set")
+	}
+
+	now := j.Clock.Now()
+
+	key, ok := j.signingKey(now)
+
+	if !ok {
+		return "", errors.New("None of the signing keys is active yet")
+	}
+
+	jti, err := NewTokenGenerator().Generate()
 
-	if len(j.claims) == 0 {
-		return "", errors.New("Jwt claims not set yet")
+	if err != nil {
+		return "", errors.Wrap(err, "Could not generate the token's id")
 	}
 
-	_, token, err := j.Encode(j.claims)
+	token := jwt.New(key.Method)
+
+	token.Header["kid"] = key.ID
+
+	for k, v := range claims {
+		token.Claims[k] = v
+	}
+
+	token.Claims["sub"] = subject
+	token.Claims["iss"] = j.Issuer
+	token.Claims["aud"] = j.Audience
+	token.Claims["iat"] = now.Unix()
+	token.Claims["nbf"] = now.Unix()
+	token.Claims["exp"] = now.Add(j.TTL).Unix()
+	token.Claims["jti"] = jti
+
+	signed, err := token.SignedString(key.Key)
 
 	if err != nil {
 		return "", errors.Wrap(err, "Could not generate JWT token")
 	}
 
+	return signed, nil
+}
+
+//Decode parses a token and verifies its signature against the key named by its kid.
+//Keys that have been retired, or that use another algorithm than the token claims, are never used.
+func (j *JWTTokenGenerator) Decode(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+
+		kid, _ := t.Header["kid"].(string)
+
+		key, ok := j.verifyingKey(kid, j.Clock.Now())
+
+		if !ok {
+			return nil, errors.Errorf("Unknown signing key %q", kid)
+		}
+
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, errors.Errorf("Key %q does not sign with %s", kid, t.Method.Alg())
+		}
+
+		return key.verificationKey(), nil
+	})
+}
+
+//Verifier looks for an access token in the request's jwt query parameter, Authorization header and jwt cookie, in that order.
+//The token, or nil if there isn't a valid one, is added to the request's context as jwt, along with the reason it was refused as jwt.err.
+//Tokens issued by or for someone else, found in the denylist, issued to a suspended user, carrying outdated permissions,
+//or that can't be checked against the denylist, are refused.
+func (j *JWTTokenGenerator) Verifier(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		token, err := j.verify(r)
+
+		ctx := context.WithValue(r.Context(), "jwt", token)
+		ctx = context.WithValue(ctx, "jwt.err", err)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (j *JWTTokenGenerator) verify(r *http.Request) (*jwt.Token, error) {
+
+	raw := tokenFromRequest(r)
+
+	if raw == "" {
+		return nil, ErrNoToken
+	}
+
+	token, err := j.Decode(raw)
+
+	if err != nil || !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+
+	if err := j.check(r.Context(), token); err != nil {
+		return nil, err
+	}
+
 	return token, nil
 }
 
+func tokenFromRequest(r *http.Request) string {
+
+	if token := r.URL.Query().Get("jwt"); token != "" {
+		return token
+	}
+
+	if bearer := r.Header.Get("Authorization"); len(bearer) > 7 && strings.ToUpper(bearer[0:7]) == "BEARER " {
+		return bearer[7:]
+	}
+
+	if cookie, err := r.Cookie("jwt"); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+func (j *JWTTokenGenerator) check(ctx context.Context, token *jwt.Token) error {
+
+	iss, _ := token.Claims["iss"].(string)
+	aud, _ := token.Claims["aud"].(string)
+
+	if iss != j.Issuer || aud != j.Audience {
+		return ErrTokenAudience
+	}
+
+	if j.denylist == nil {
+		return nil
+	}
+
+	jti, _ := token.Claims["jti"].(string)
+
+	revoked, err := j.denylist.IsAccessTokenRevoked(ctx, jti)
+
+	if err != nil || revoked {
+		return ErrTokenRevoked
+	}
+
+	sub, _ := token.Claims["sub"].(string)
+
+	suspended, err := j.denylist.IsUserSuspended(ctx, sub)
+
+	if err != nil || suspended {
+		return ErrUserSuspended
+	}
+
+	//Numbers are decoded as float64. Tokens issued without a version carry the first one
+	version, _ := token.Claims["pv"].(float64)
+
+	outdated, err := j.denylist.ArePermissionsOutdated(ctx, sub, int(version))
+
+	if err != nil || outdated {
+		return ErrPermissionsChanged
+	}
+
+	return nil
+}
+
 func timeFrame() time.Duration {
 	return time.Second * 60 * 5
 }
@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func newTestGenerator() *JWTTokenGenerator {
+	return MustNewJWTGenerator(SigningKey{ID: "test", Method: jwt.SigningMethodHS256, Key: []byte("secret")})
+}
+
+func TestGenerateSetsTheRegisteredClaims(t *testing.T) {
+
+	JWT := newTestGenerator()
+
+	now := time.Now().Truncate(time.Second)
+
+	JWT.Clock = fixedClock{now}
+
+	//Registered claims can't be overridden
+	token, err := JWT.Generate("7", map[string]interface{}{"userID": 7, "exp": 1, "sub": "1"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := JWT.Decode(token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := decoded.Claims
+
+	assert.Equal(t, "7", claims["sub"])
+	assert.Equal(t, "reblog", claims["iss"])
+	assert.Equal(t, "reblog", claims["aud"])
+	assert.Equal(t, float64(now.Unix()), claims["iat"])
+	assert.Equal(t, float64(now.Unix()), claims["nbf"])
+	assert.Equal(t, float64(now.Add(5*time.Minute).Unix()), claims["exp"])
+	assert.NotEmpty(t, claims["jti"])
+	assert.Equal(t, "test", decoded.Header["kid"])
+}
+
+func TestGenerateComputesTheExpiryOnEveryCall(t *testing.T) {
+
+	JWT := newTestGenerator()
+
+	JWT.TTL = 3 * time.Hour
+
+	now := time.Now().Truncate(time.Second)
+
+	var expiries []interface{}
+
+	for i := 0; i < 2; i++ {
+		JWT.Clock = fixedClock{now.Add(time.Duration(i-1) * time.Hour)}
+
+		token, err := JWT.Generate("7", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := JWT.Decode(token)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expiries = append(expiries, decoded.Claims["exp"])
+	}
+
+	assert.Equal(t, float64(now.Add(2*time.Hour).Unix()), expiries[0])
+	assert.Equal(t, float64(now.Add(3*time.Hour).Unix()), expiries[1])
+}
+
+func TestGenerateRequiresASubject(t *testing.T) {
+
+	_, err := newTestGenerator().Generate("", map[string]interface{}{"userID": 7})
+
+	assert.Error(t, err)
+}
+
+func TestVerifierRefusesTokensIssuedForAnotherAudience(t *testing.T) {
+
+	other := newTestGenerator()
+	other.Audience = "another-service"
+
+	token, err := other.Generate("7", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "/reblog/ping", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", "BEARER "+token)
+
+	var verr interface{}
+
+	newTestGenerator().Verifier(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verr = r.Context().Value("jwt.err")
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, ErrTokenAudience, verr)
+}
+
+type stubDenylist struct {
+	suspended map[string]bool
+}
+
+//The permissions of every user are at their second version
+const STUB_PERMISSIONS_VERSION = 2
+
+func (d stubDenylist) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return false, nil
+}
+
+func (d stubDenylist) IsUserSuspended(ctx context.Context, subject string) (bool, error) {
+	return d.suspended[subject], nil
+}
+
+func (d stubDenylist) ArePermissionsOutdated(ctx context.Context, subject string, version int) (bool, error) {
+	return version != STUB_PERMISSIONS_VERSION, nil
+}
+
+func TestVerifierRefusesTokensOfSuspendedUsers(t *testing.T) {
+
+	JWT := newTestGenerator()
+	JWT.UseDenylist(stubDenylist{map[string]bool{"7": true}})
+
+	verify := func(subject string) (interface{}, interface{}) {
+		token, err := JWT.Generate(subject, map[string]interface{}{"pv": STUB_PERMISSIONS_VERSION})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("GET", "/reblog/ping", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "BEARER "+token)
+
+		var verified, verr interface{}
+
+		JWT.Verifier(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verified = r.Context().Value("jwt")
+			verr = r.Context().Value("jwt.err")
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		return verified, verr
+	}
+
+	token, verr := verify("7")
+
+	assert.Equal(t, ErrUserSuspended, verr)
+	assert.Nil(t, token.(*jwt.Token))
+
+	token, verr = verify("8")
+
+	assert.Nil(t, verr)
+	assert.NotNil(t, token.(*jwt.Token))
+}
+
+func TestVerifierRefusesTokensCarryingOutdatedPermissions(t *testing.T) {
+
+	JWT := newTestGenerator()
+	JWT.UseDenylist(stubDenylist{})
+
+	for version, expected := range map[int]error{1: ErrPermissionsChanged, STUB_PERMISSIONS_VERSION: nil} {
+		token, err := JWT.Generate("7", map[string]interface{}{"pv": version})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest("GET", "/reblog/ping", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", "BEARER "+token)
+
+		var verr interface{}
+
+		JWT.Verifier(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verr = r.Context().Value("jwt.err")
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		if expected == nil {
+			assert.Nil(t, verr)
+			continue
+		}
+
+		assert.Equal(t, expected, verr)
+	}
+}
+
+//Run with -race
+func TestGenerateIsSafeForConcurrentUse(t *testing.T) {
+
+	JWT := newTestGenerator()
+
+	var wg sync.WaitGroup
+
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+
+		go func(userID int) {
+			defer wg.Done()
+
+			claims := map[string]interface{}{
+				"userID":  userID,
+				"moniker": fmt.Sprintf("user-%d", userID),
+				"type":    userID % 2,
+			}
+
+			token, err := JWT.Generate(strconv.Itoa(userID), claims)
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			decoded, err := JWT.Decode(token)
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			assert.Equal(t, strconv.Itoa(userID), decoded.Claims["sub"])
+			assert.Equal(t, float64(userID), decoded.Claims["userID"])
+			assert.Equal(t, fmt.Sprintf("user-%d", userID), decoded.Claims["moniker"])
+			assert.Equal(t, float64(userID%2), decoded.Claims["type"])
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,277 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+//SigningKey is a key access tokens are signed with, identified in their header by its ID (kid).
+//Key is a []byte for HS256, a *rsa.PrivateKey for RS256 or a *ecdsa.PrivateKey for ES256.
+//
+//Keys are rotated by scheduling a new one with a later ActiveAt.
+//The key with the latest ActiveAt that has been reached signs new tokens,
+//the key it replaced keeps verifying tokens until the last one it signed has expired.
+type SigningKey struct {
+	ID       string
+	Method   jwt.SigningMethod
+	Key      interface{}
+	ActiveAt time.Time
+}
+
+func (k SigningKey) validate() error {
+
+	if k.ID == "" {
+		return errors.New("Signing keys must have an id")
+	}
+
+	var ok bool
+
+	switch k.Method {
+	case jwt.SigningMethodHS256:
+		var secret []byte
+		secret, ok = k.Key.([]byte)
+		ok = ok && len(secret) != 0
+	case jwt.SigningMethodRS256:
+		_, ok = k.Key.(*rsa.PrivateKey)
+	case jwt.SigningMethodES256:
+		var key *ecdsa.PrivateKey
+		key, ok = k.Key.(*ecdsa.PrivateKey)
+		ok = ok && key.Curve.Params().BitSize == 256
+	default:
+		return errors.Errorf("Signing key %q: only HS256, RS256 and ES256 are supported", k.ID)
+	}
+
+	if !ok {
+		return errors.Errorf("Signing key %q is not a valid %s key", k.ID, k.Method.Alg())
+	}
+
+	return nil
+}
+
+func (k SigningKey) verificationKey() interface{} {
+	switch key := k.Key.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey
+	}
+
+	return k.Key
+}
+
+//sortKeys validates keys and orders them by the time they start signing
+func sortKeys(keys []SigningKey) ([]SigningKey, error) {
+
+	if len(keys) == 0 {
+		return nil, errors.New("No signing key was provided")
+	}
+
+	ids := make(map[string]bool, len(keys))
+
+	for _, k := range keys {
+		if err := k.validate(); err != nil {
+			return nil, err
+		}
+
+		if ids[k.ID] {
+			return nil, errors.Errorf("Signing key %q is used more than once", k.ID)
+		}
+
+		ids[k.ID] = true
+	}
+
+	sorted := make([]SigningKey, len(keys))
+	copy(sorted, keys)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveAt.Before(sorted[j].ActiveAt)
+	})
+
+	return sorted, nil
+}
+
+//signingKey is the most recently activated key
+func (j *JWTTokenGenerator) signingKey(now time.Time) (SigningKey, bool) {
+
+	for i := len(j.keys) - 1; i >= 0; i-- {
+		if !j.keys[i].ActiveAt.After(now) {
+			return j.keys[i], true
+		}
+	}
+
+	return SigningKey{}, false
+}
+
+//verifyingKeys are the keys tokens can still be verified with.
+//That is every key but the ones replaced more than a token's lifetime ago.
+//Keys scheduled to start signing later are included, so other services can pick them up in time.
+func (j *JWTTokenGenerator) verifyingKeys(now time.Time) []SigningKey {
+
+	var keys []SigningKey
+
+	for i, k := range j.keys {
+
+		retired := false
+
+		for _, next := range j.keys[i+1:] {
+			if next.ActiveAt.After(k.ActiveAt) && !next.ActiveAt.After(now) && now.After(next.ActiveAt.Add(j.TTL)) {
+				retired = true
+				break
+			}
+		}
+
+		if !retired {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+func (j *JWTTokenGenerator) verifyingKey(id string, now time.Time) (SigningKey, bool) {
+
+	for _, k := range j.verifyingKeys(now) {
+		if k.ID == id {
+			return k, true
+		}
+	}
+
+	return SigningKey{}, false
+}
+
+//JWK is the public half of a signing key, as described in RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+//JWKS lists the public keys tokens can currently be verified with.
+//HS256 keys are secrets and are left out.
+func (j *JWTTokenGenerator) JWKS() []JWK {
+
+	keys := []JWK{}
+
+	for _, k := range j.verifyingKeys(j.Clock.Now()) {
+
+		switch key := k.Key.(type) {
+		case *rsa.PrivateKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: k.ID,
+				Use: "sig",
+				Alg: k.Method.Alg(),
+				N:   encodeJWKInt(key.N, 0),
+				E:   encodeJWKInt(big.NewInt(int64(key.E)), 0),
+			})
+		case *ecdsa.PrivateKey:
+			size := (key.Curve.Params().BitSize + 7) / 8
+
+			keys = append(keys, JWK{
+				Kty: "EC",
+				Kid: k.ID,
+				Use: "sig",
+				Alg: k.Method.Alg(),
+				Crv: key.Curve.Params().Name,
+				X:   encodeJWKInt(key.X, size),
+				Y:   encodeJWKInt(key.Y, size),
+			})
+		}
+	}
+
+	return keys
+}
+
+//encodeJWKInt encodes i as unpadded base64url, left padding it with zeroes to size bytes
+func encodeJWKInt(i *big.Int, size int) string {
+	b := i.Bytes()
+
+	if len(b) < size {
+		b = append(make([]byte, size-len(b)), b...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+//LoadSigningKeys reads the signing keys listed in a JSON file like
+//
+//	{"keys" : [{"kid" : "2017-03", "alg" : "RS256", "file" : "2017-03.pem", "active_at" : "2017-03-01T00:00:00Z"}]}
+//
+//RS256 and ES256 keys are PEM encoded private keys, a HS256 key file holds the secret itself.
+//Relative paths are resolved from the directory the JSON file is in.
+func LoadSigningKeys(path string) ([]SigningKey, error) {
+
+	contents, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read the signing keys")
+	}
+
+	var config struct {
+		Keys []struct {
+			ID       string    `json:"kid"`
+			Alg      string    `json:"alg"`
+			File     string    `json:"file"`
+			ActiveAt time.Time `json:"active_at"`
+		} `json:"keys"`
+	}
+
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return nil, errors.Wrap(err, "Could not read the signing keys")
+	}
+
+	keys := make([]SigningKey, 0, len(config.Keys))
+
+	for _, c := range config.Keys {
+
+		file := c.File
+
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+
+		material, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not read signing key %q", c.ID)
+		}
+
+		k := SigningKey{ID: c.ID, ActiveAt: c.ActiveAt}
+
+		switch c.Alg {
+		case "HS256":
+			k.Method, k.Key = jwt.SigningMethodHS256, material
+		case "RS256":
+			k.Method = jwt.SigningMethodRS256
+			k.Key, err = jwt.ParseRSAPrivateKeyFromPEM(material)
+		case "ES256":
+			k.Method = jwt.SigningMethodES256
+			k.Key, err = jwt.ParseECPrivateKeyFromPEM(material)
+		default:
+			return nil, errors.Errorf("Signing key %q: only HS256, RS256 and ES256 are supported", c.ID)
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not parse signing key %q", c.ID)
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,310 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func ecKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestNewJWTGeneratorRefusesUnusableKeys(t *testing.T) {
+
+	_, err := NewJWTGenerator()
+	assert.Error(t, err, "No key at all")
+
+	_, err = NewJWTGenerator(SigningKey{ID: "empty", Method: jwt.SigningMethodHS256, Key: []byte("")})
+	assert.Error(t, err, "Empty secret")
+
+	_, err = NewJWTGenerator(SigningKey{Method: jwt.SigningMethodHS256, Key: []byte("secret")})
+	assert.Error(t, err, "No kid")
+
+	_, err = NewJWTGenerator(SigningKey{ID: "rsa", Method: jwt.SigningMethodRS256, Key: []byte("secret")})
+	assert.Error(t, err, "Wrong key type")
+
+	_, err = NewJWTGenerator(
+		SigningKey{ID: "same", Method: jwt.SigningMethodHS256, Key: []byte("secret")},
+		SigningKey{ID: "same", Method: jwt.SigningMethodHS256, Key: []byte("another secret")})
+	assert.Error(t, err, "Duplicate kid")
+}
+
+func TestTokensCanBeSignedWithRS256AndES256(t *testing.T) {
+
+	keys := []SigningKey{
+		{ID: "rsa", Method: jwt.SigningMethodRS256, Key: rsaKey(t)},
+		{ID: "ec", Method: jwt.SigningMethodES256, Key: ecKey(t)},
+	}
+
+	for _, k := range keys {
+		JWT := MustNewJWTGenerator(k)
+
+		token, err := JWT.Generate("7", nil)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := JWT.Decode(token)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.True(t, decoded.Valid)
+		assert.Equal(t, k.Method.Alg(), decoded.Header["alg"])
+		assert.Equal(t, k.ID, decoded.Header["kid"])
+	}
+}
+
+func TestTokensSignedWithAnotherAlgorithmAreRefused(t *testing.T) {
+
+	key := rsaKey(t)
+
+	JWT := MustNewJWTGenerator(SigningKey{ID: "rsa", Method: jwt.SigningMethodRS256, Key: key})
+
+	//The public key is no secret, it can't be accepted as a HS256 one
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := jwt.New(jwt.SigningMethodHS256)
+	forged.Header["kid"] = "rsa"
+	forged.Claims["sub"] = "1"
+
+	token, err := forged.SignedString(public)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = JWT.Decode(token)
+
+	assert.Error(t, err)
+}
+
+func TestKeysAreRotated(t *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	JWT := MustNewJWTGenerator(
+		SigningKey{ID: "old", Method: jwt.SigningMethodHS256, Key: []byte("old secret"), ActiveAt: now.Add(-time.Hour)},
+		SigningKey{ID: "new", Method: jwt.SigningMethodHS256, Key: []byte("new secret"), ActiveAt: now.Add(-time.Minute)},
+		SigningKey{ID: "next", Method: jwt.SigningMethodHS256, Key: []byte("next secret"), ActiveAt: now.Add(time.Hour)})
+
+	JWT.Clock = fixedClock{now.Add(-2 * time.Minute)}
+
+	old, err := JWT.Generate("7", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	JWT.Clock = fixedClock{now}
+
+	current, err := JWT.Generate("7", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := JWT.Decode(current)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "new", decoded.Header["kid"])
+
+	//The old key keeps verifying the tokens it signed until they expire
+	decoded, err = JWT.Decode(old)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "old", decoded.Header["kid"])
+
+	//Then it is retired
+	JWT.Clock = fixedClock{now.Add(5 * time.Minute)}
+
+	_, err = JWT.Decode(old)
+
+	assert.Error(t, err)
+
+	_, err = JWT.Decode(current)
+
+	assert.NoError(t, err)
+}
+
+func TestNoTokenIsSignedBeforeAKeyIsActive(t *testing.T) {
+
+	JWT := MustNewJWTGenerator(SigningKey{ID: "later", Method: jwt.SigningMethodHS256, Key: []byte("secret"), ActiveAt: time.Now().Add(time.Hour)})
+
+	_, err := JWT.Generate("7", nil)
+
+	assert.Error(t, err)
+}
+
+func TestJWKSListsThePublicKeys(t *testing.T) {
+
+	rsaPrivate, ecPrivate := rsaKey(t), ecKey(t)
+
+	now := time.Now()
+
+	JWT := MustNewJWTGenerator(
+		SigningKey{ID: "retired", Method: jwt.SigningMethodRS256, Key: rsaKey(t), ActiveAt: now.Add(-2 * time.Hour)},
+		SigningKey{ID: "rsa", Method: jwt.SigningMethodRS256, Key: rsaPrivate, ActiveAt: now.Add(-time.Hour)},
+		SigningKey{ID: "hmac", Method: jwt.SigningMethodHS256, Key: []byte("secret"), ActiveAt: now.Add(-time.Minute)},
+		SigningKey{ID: "ec", Method: jwt.SigningMethodES256, Key: ecPrivate, ActiveAt: now.Add(time.Hour)})
+
+	keys := JWT.JWKS()
+
+	if len(keys) != 2 {
+		t.Fatalf("Expected 2 keys. Got %d", len(keys))
+	}
+
+	decode := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return new(big.Int).SetBytes(b)
+	}
+
+	assert.Equal(t, "RSA", keys[0].Kty)
+	assert.Equal(t, "rsa", keys[0].Kid)
+	assert.Equal(t, "RS256", keys[0].Alg)
+	assert.Equal(t, "sig", keys[0].Use)
+	assert.Equal(t, 0, rsaPrivate.N.Cmp(decode(keys[0].N)))
+	assert.Equal(t, int64(rsaPrivate.E), decode(keys[0].E).Int64())
+
+	assert.Equal(t, "EC", keys[1].Kty)
+	assert.Equal(t, "ec", keys[1].Kid)
+	assert.Equal(t, "ES256", keys[1].Alg)
+	assert.Equal(t, "P-256", keys[1].Crv)
+	assert.Equal(t, 0, ecPrivate.X.Cmp(decode(keys[1].X)))
+	assert.Equal(t, 0, ecPrivate.Y.Cmp(decode(keys[1].Y)))
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reblog-keys")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ecPrivate := ecKey(t)
+
+	ecBytes, err := x509.MarshalECPrivateKey(ecPrivate)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"rsa.pem":  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey(t))}),
+		"ec.pem":   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecBytes}),
+		"hmac.key": []byte("secret"),
+		"keys.json": []byte(`{"keys" : [
+			{"kid" : "rsa", "alg" : "RS256", "file" : "rsa.pem", "active_at" : "2017-03-01T00:00:00Z"},
+			{"kid" : "ec", "alg" : "ES256", "file" : "ec.pem", "active_at" : "2017-04-01T00:00:00Z"},
+			{"kid" : "hmac", "alg" : "HS256", "file" : "hmac.key", "active_at" : "2017-02-01T00:00:00Z"}
+		]}`),
+	}
+
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := LoadSigningKeys(filepath.Join(dir, "keys.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 3 {
+		t.Fatalf("Expected 3 keys. Got %d", len(keys))
+	}
+
+	assert.IsType(t, &rsa.PrivateKey{}, keys[0].Key)
+	assert.Equal(t, time.Date(2017, time.March, 1, 0, 0, 0, 0, time.UTC), keys[0].ActiveAt)
+	assert.Equal(t, 0, ecPrivate.D.Cmp(keys[1].Key.(*ecdsa.PrivateKey).D))
+	assert.Equal(t, []byte("secret"), keys[2].Key)
+
+	JWT, err := NewJWTGenerator(keys...)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := JWT.Generate("7", nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := JWT.Decode(token)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "ec", decoded.Header["kid"])
+}
+
+func TestLoadSigningKeysRefusesUnknownAlgorithms(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "reblog-keys")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(filepath.Join(dir, "hmac.key"), []byte("secret"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, "keys.json"), []byte(`{"keys" : [{"kid" : "hmac", "alg" : "HS512", "file" : "hmac.key"}]}`), 0600)
+
+	_, err = LoadSigningKeys(filepath.Join(dir, "keys.json"))
+
+	assert.Error(t, err)
+}
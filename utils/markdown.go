@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+//Average number of words read per minute, used for the reading time estimate
+const WORDS_PER_MINUTE = 200
+
+//Excerpts longer than this are cut at the last full word
+const EXCERPT_LENGTH = 300
+
+//Raw HTML is let through by the renderer since the sanitizer is what decides what is safe.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.Table, extension.Footnote, extension.Strikethrough),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+//Collaborators are semi-trusted, so scripts, event handlers and javascript: urls never make it into the output
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+
+	//Keep the language of fenced code blocks around for syntax highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+
+	return p
+}
+
+//RenderedContent is the result of rendering a post written in Markdown
+type RenderedContent struct {
+	HTML string
+	//Plain text of the first paragraph
+	Excerpt string
+	//Estimated reading time in minutes
+	ReadingTime int
+}
+
+//Markdown renders CommonMark, with tables, fenced code and footnotes, to sanitized HTML.
+//The zero value is ready to use.
+type Markdown struct{}
+
+func NewMarkdownRenderer() Markdown {
+	return Markdown{}
+}
+
+func (m Markdown) Render(source string) (RenderedContent, error) {
+	var rendered RenderedContent
+
+	src := []byte(source)
+
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	var buf bytes.Buffer
+
+	if err := markdown.Renderer().Render(&buf, src, doc); err != nil {
+		return rendered, err
+	}
+
+	rendered.HTML = sanitizer.Sanitize(buf.String())
+
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		if n.Kind() == ast.KindParagraph {
+			rendered.Excerpt = truncate(plainText(n, src), EXCERPT_LENGTH)
+			break
+		}
+	}
+
+	if words := len(strings.Fields(plainText(doc, src))); words > 0 {
+		rendered.ReadingTime = (words + WORDS_PER_MINUTE - 1) / WORDS_PER_MINUTE
+	}
+
+	return rendered, nil
+}
+
+//plainText collects the text of a node and its children, leaving out markup and raw HTML
+func plainText(n ast.Node, src []byte) string {
+	var buf bytes.Buffer
+
+	ast.Walk(n, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if node.Type() == ast.TypeBlock {
+				buf.WriteByte(' ')
+			}
+
+			return ast.WalkContinue, nil
+		}
+
+		switch v := node.(type) {
+		case *ast.Text:
+			buf.Write(v.Segment.Value(src))
+
+			if v.SoftLineBreak() || v.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(v.Value)
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := node.Lines()
+
+			for i := 0; i < lines.Len(); i++ {
+				segment := lines.At(i)
+				buf.Write(segment.Value(src))
+			}
+
+			return ast.WalkSkipChildren, nil
+		case *ast.RawHTML, *ast.HTMLBlock, *east.FootnoteLink:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	cut := string([]rune(s)[:max])
+
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+
+	return cut + "…"
+}